@@ -12,10 +12,12 @@ import (
 	"github.com/adonmo/goroom/logger"
 	"github.com/adonmo/goroom/orm"
 	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/room/roomtest"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 
 	"github.com/adonmo/goroom/util/adapter"
+	"github.com/adonmo/goroom/util/verify"
 )
 
 /* A sample to demonstrate the usage of go room
@@ -38,15 +40,11 @@ It is purely a utility that serves the minimal purpose of carrying out migration
 A lot of power is still in the developers hands as they have the freedom to execute any operations on the DB themselves.
 Although doing stuff like deleting/updating Room's metadata tables is a big No-No :). Plz...
 */
-func TestIntegrationWithGORM(t *testing.T) {
+const schemaSnapshotDir = "schemas"
 
-	//A Data Store is represented by the tables(entities) it houses. Below we will define a snapshot each of a DB in various versions.
+func TestIntegrationWithGORM(t *testing.T) {
 
-	entitiesForVersionsArr := [][]interface{}{}
-	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{old.User{}})                      //First Data Store Version with just User Table
-	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{old.User{}, old.Profile{}})       //Profile Table Added
-	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{latest.User{}, old.Profile{}})    //User Table upgraded to have a new column for Credits
-	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{latest.User{}, latest.Profile{}}) //Profile Table upgraded to have foreign key relationship with User
+	entitiesForVersionsArr := getEntitiesForVersions()
 
 	if !verifyThatEntityHashesForAllVersionsAreDifferent(entitiesForVersionsArr) {
 		t.Errorf("Hash Uniqueness check failed")
@@ -57,6 +55,721 @@ func TestIntegrationWithGORM(t *testing.T) {
 	}
 }
 
+//TestSchemaSnapshots Every version must have an exported schema snapshot(see schemas folder) matching its entities
+func TestSchemaSnapshots(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	defer db.Close()
+
+	for idx, entities := range getEntitiesForVersions() {
+		appDB, errList := room.New(entities, gormAdapter, orm.VersionNumber(idx+1), migrations.GetMigrations(), new(adapter.EntityHashConstructor))
+		if len(errList) > 0 {
+			panic(errList)
+		}
+
+		roomtest.AssertSchemaSnapshot(t, appDB, schemaSnapshotDir)
+	}
+}
+
+//TestMigrationHarness Upgrades a seeded database from every version with a snapshot to the latest version
+func TestMigrationHarness(t *testing.T) {
+
+	entitiesForVersionsArr := getEntitiesForVersions()
+	latestVersion := len(entitiesForVersionsArr)
+
+	seedUser := func(db *gorm.DB) error {
+		return db.Create(&old.User{Name: "Alice"}).Error
+	}
+	seedUserAndProfile := func(db *gorm.DB) error {
+		if err := seedUser(db); err != nil {
+			return err
+		}
+		return db.Create(&old.Profile{UserID: 1, Name: "Alice's Profile"}).Error
+	}
+	verifyUser := func(db *gorm.DB) error {
+		var user latest.User
+		if err := db.First(&user, 1).Error; err != nil {
+			return err
+		}
+		if user.Name != "Alice" {
+			return fmt.Errorf("Expected user Alice. Got %v", user.Name)
+		}
+		return nil
+	}
+	verifyUserAndProfile := func(db *gorm.DB) error {
+		if err := verifyUser(db); err != nil {
+			return err
+		}
+		var profile latest.Profile
+		return db.Where("user_id = ?", 1).First(&profile).Error
+	}
+
+	harness := &roomtest.MigrationHarness{
+		SnapshotDir: schemaSnapshotDir,
+		History: []roomtest.VersionFixture{
+			{Version: 1, Entities: entitiesForVersionsArr[0], Seed: seedUser, Verify: verifyUser},
+			{Version: 2, Entities: entitiesForVersionsArr[1], Seed: seedUserAndProfile, Verify: verifyUserAndProfile},
+			{Version: 3, Entities: entitiesForVersionsArr[2], Seed: seedUserAndProfile, Verify: verifyUserAndProfile},
+		},
+		Entities:   entitiesForVersionsArr[latestVersion-1],
+		Version:    orm.VersionNumber(latestVersion),
+		Migrations: migrations.GetMigrations(),
+	}
+
+	harness.Run(t)
+}
+
+//Invoice Entity of a billing module sharing the DB with the user module
+type Invoice struct {
+	gorm.Model
+	Amount int
+}
+
+//TestNamespacesWithGORM Two modules version their tables independently in one DB while a third one can not take over their tables
+func TestNamespacesWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+
+	userDB, errList := room.NewWithNamespace("users", entitiesForVersionsArr[3], gormAdapter, 4, migrations.GetMigrations(), identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	billingDB, errList := room.NewWithNamespace("billing", []interface{}{Invoice{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	if _, err := groom.InitializeRoom(userDB, false); err != nil {
+		t.Errorf("Unable to initialize users namespace. %v", err)
+	}
+	if _, err := groom.InitializeRoom(billingDB, false); err != nil {
+		t.Errorf("Unable to initialize billing namespace. %v", err)
+	}
+	if !db.HasTable("users_go_room_schema_masters") || !db.HasTable("billing_go_room_schema_masters") {
+		t.Errorf("Each namespace should have a schema master of its own")
+	}
+
+	auditDB, errList := room.NewWithNamespace("audit", []interface{}{latest.User{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(auditDB, true); err == nil {
+		t.Errorf("Expected a table name collision for the audit namespace")
+	}
+	if !db.HasTable(latest.User{}) {
+		t.Errorf("Colliding namespace must not drop tables of another namespace")
+	}
+
+	defaultDB, errList := room.New([]interface{}{Invoice{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(defaultDB, false); err == nil {
+		t.Errorf("Expected a table name collision for the default namespace")
+	}
+
+	//Billing stops managing invoices which another namespace can then take over
+	billingDB, errList = room.NewWithNamespace("billing", []interface{}{Receipt{}}, gormAdapter, 2, []orm.Migration{
+		&migrations.UserDBMigration{
+			BaseVersion:   1,
+			TargetVersion: 2,
+			MigrationFunc: func(db interface{}) error {
+				return db.(*gorm.DB).DropTable(Invoice{}).Error
+			},
+		},
+	}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(billingDB, false); err != nil {
+		t.Errorf("Unable to migrate billing namespace. %v", err)
+	}
+	var claims []room.GoRoomNamespaceTable
+	db.Where("namespace = ?", "billing").Find(&claims)
+	if len(claims) != 1 || claims[0].EntityTable != "receipts" {
+		t.Errorf("Expected billing to only claim receipts. Got %+v", claims)
+	}
+
+	archiveDB, errList := room.NewWithNamespace("archive", []interface{}{Invoice{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(archiveDB, false); err != nil {
+		t.Errorf("Released table should be claimable by another namespace. %v", err)
+	}
+}
+
+//Receipt Entity replacing invoices of the billing module
+type Receipt struct {
+	gorm.Model
+	Total int
+}
+
+//TestSingularSchemaMasterWithGORM Schema master follows the table naming of GORM when no namespace or table name is configured
+func TestSingularSchemaMasterWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	db.SingularTable(true)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	for version := orm.VersionNumber(1); version <= 2; version++ {
+		appDB, errList := room.New([]interface{}{latest.User{}}, gormAdapter, version, []orm.Migration{
+			&migrations.UserDBMigration{BaseVersion: 1, TargetVersion: 2, MigrationFunc: func(db interface{}) error { return nil }},
+		}, identityCalculator)
+		if len(errList) > 0 {
+			panic(errList)
+		}
+		result, err := groom.InitializeRoom(appDB, false)
+		if err != nil {
+			t.Fatalf("Unable to initialize version %v. %v", version, err)
+		}
+		if version == 2 && result.Scenario != room.ScenarioMigration {
+			t.Errorf("Expected the existing schema master to be found and migrated. Got %+v", result)
+		}
+	}
+
+	if !db.HasTable("go_room_schema_master") || db.HasTable("go_room_schema_masters") {
+		t.Errorf("Expected the schema master in go_room_schema_master as named by GORM")
+	}
+}
+
+//profileMigration Migration declaring that it only touches the profiles table
+type profileMigration struct {
+	migrations.UserDBMigration
+}
+
+//GetAffectedTables ...
+func (m *profileMigration) GetAffectedTables() []string {
+	return []string{"profiles"}
+}
+
+//TestSelectiveDestructiveFallbackWithGORM A failing migration only resets the tables it touches while other tables keep their data
+func TestSelectiveDestructiveFallbackWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+
+	baseDB, errList := room.New(entitiesForVersionsArr[2], gormAdapter, 3, migrations.GetMigrations(), identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(baseDB, false); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+	db.Create(&latest.User{Name: "Alice", Credits: 10})
+	db.Create(&old.Profile{Name: "Alice's Profile"})
+
+	failingMigration := &profileMigration{migrations.UserDBMigration{
+		BaseVersion:   3,
+		TargetVersion: 4,
+		MigrationFunc: func(db interface{}) error {
+			return fmt.Errorf("Unable to link profiles to users")
+		},
+	}}
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            entitiesForVersionsArr[3],
+		DBA:                 gormAdapter,
+		Version:             4,
+		Migrations:          []orm.Migration{failingMigration},
+		IdentityCalculator:  identityCalculator,
+		DestructiveFallback: room.SelectiveDestructiveFallback,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	result, err := groom.Initialize(appDB)
+	if err != nil {
+		t.Fatalf("Selective destructive fallback should have recovered the DB. %v", err)
+	}
+	if !result.Destroyed || result.PreviousVersion != 3 || result.Version != 4 || len(result.MigrationsApplied) != 0 {
+		t.Errorf("Expected a destructive recovery from version 3 to 4. Got %+v", result)
+	}
+	if len(result.TablesDropped) != 1 || result.TablesDropped[0] != "profiles" {
+		t.Errorf("Only profiles should have been dropped. Got %v", result.TablesDropped)
+	}
+
+	if tables := appDB.GetLastResetTables(); len(tables) != 1 || tables[0] != "profiles" {
+		t.Errorf("Only profiles should have been reset. Got %v", tables)
+	}
+
+	var userCount, profileCount int
+	db.Model(&latest.User{}).Count(&userCount)
+	db.Model(&latest.Profile{}).Count(&profileCount)
+	if userCount != 1 || profileCount != 0 {
+		t.Errorf("Expected users to be retained and profiles to be reset. Got %v users and %v profiles", userCount, profileCount)
+	}
+
+	_, version, err := gormAdapter.GetLatestSchemaIdentityHashAndVersion()
+	if err != nil || version != 4 {
+		t.Errorf("Expected DB to be at version 4. Got %v %v", version, err)
+	}
+}
+
+//TestOrphanedTablesWithGORM Table of an entity removed in a later version is archived during migration
+func TestOrphanedTablesWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	removeInvoicesMigration := &migrations.UserDBMigration{
+		BaseVersion:   1,
+		TargetVersion: 2,
+		MigrationFunc: func(db interface{}) error {
+			return nil
+		},
+	}
+
+	baseDB, errList := room.New([]interface{}{latest.User{}, Invoice{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(baseDB, false); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+	db.Create(&Invoice{Amount: 100})
+
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:           []interface{}{latest.User{}},
+		DBA:                gormAdapter,
+		Version:            2,
+		Migrations:         []orm.Migration{removeInvoicesMigration},
+		IdentityCalculator: identityCalculator,
+		OrphanedTables:     room.ArchiveOrphanedTables,
+		SchemaVerifier:     verify.NewSQLiteSchemaVerifier(),
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	result, err := groom.Initialize(appDB)
+	if err != nil {
+		t.Fatalf("Unable to migrate. %v", err)
+	}
+	if result.Scenario != room.ScenarioMigration || result.Destroyed || len(result.MigrationsApplied) != 1 || result.MigrationsApplied[0].To != 2 {
+		t.Errorf("Expected a migration from version 1 to 2. Got %+v", result)
+	}
+
+	if tables := appDB.GetOrphanedTables(); len(tables) != 1 || tables[0] != "invoices" {
+		t.Errorf("Expected invoices to be orphaned. Got %v", tables)
+	}
+	var archivedCount int
+	db.Table("invoices_archived_v1").Count(&archivedCount)
+	if db.HasTable(Invoice{}) || archivedCount != 1 {
+		t.Errorf("Expected invoices to be archived along with their data. Got %v archived rows", archivedCount)
+	}
+}
+
+//TestReadOnlyWithGORM A process opening the DB read only verifies it without ever migrating it
+func TestReadOnlyWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+	newReadOnlyRoom := func(version orm.VersionNumber) *room.Room {
+		readOnlyDB, errList := room.NewFromConfig(room.Config{
+			Entities:            entitiesForVersionsArr[version-1],
+			DBA:                 gormAdapter,
+			Version:             version,
+			Migrations:          migrations.GetMigrations(),
+			IdentityCalculator:  identityCalculator,
+			DestructiveFallback: room.DestructiveFallbackToCleanDB,
+			ReadOnly:            true,
+		})
+		if len(errList) > 0 {
+			panic(errList)
+		}
+		return readOnlyDB
+	}
+
+	baseDB, errList := room.New(entitiesForVersionsArr[1], gormAdapter, 2, migrations.GetMigrations(), identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(baseDB, false); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+
+	if _, err := groom.Initialize(newReadOnlyRoom(2)); err != nil {
+		t.Errorf("Read only Room at the same version should be able to use the DB. %v", err)
+	}
+
+	readOnlyDB := newReadOnlyRoom(3)
+	identityHash, _ := readOnlyDB.CalculateIdentityHash()
+	if result, err := readOnlyDB.CheckCompatibility(identityHash); err != nil || result.Compatibility != room.NeedsMigration {
+		t.Errorf("Expected DB to need migration. Got %v %v", result, err)
+	}
+	if _, err := groom.Initialize(readOnlyDB); err == nil {
+		t.Errorf("Read only Room of a newer version should not be able to use the DB")
+	}
+
+	_, version, err := gormAdapter.GetLatestSchemaIdentityHashAndVersion()
+	if err != nil || version != 2 || !db.HasTable(old.Profile{}) {
+		t.Errorf("Expected DB to be left at version 2. Got %v %v", version, err)
+	}
+}
+
+//TestRollbackToCompatibleVersionWithGORM App rolled back to a version declared compatible keeps using the newer DB without losing data
+func TestRollbackToCompatibleVersionWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+	newRoom := func(version orm.VersionNumber, minCompatibleVersion orm.VersionNumber) *room.Room {
+		appDB, errList := room.NewFromConfig(room.Config{
+			Entities:             entitiesForVersionsArr[version-1],
+			DBA:                  gormAdapter,
+			Version:              version,
+			Migrations:           migrations.GetMigrations(),
+			IdentityCalculator:   identityCalculator,
+			DestructiveFallback:  room.DestructiveFallbackToCleanDB,
+			MinCompatibleVersion: minCompatibleVersion,
+		})
+		if len(errList) > 0 {
+			panic(errList)
+		}
+		return appDB
+	}
+
+	if _, err := groom.Initialize(newRoom(3, 0)); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+	db.Create(&latest.User{Name: "Alice", Credits: 10})
+
+	//Version 4 only adds a relationship between existing tables which version 3 can live with
+	if _, err := groom.Initialize(newRoom(4, 3)); err != nil {
+		t.Fatalf("Unable to migrate to version 4. %v", err)
+	}
+
+	result, err := groom.Initialize(newRoom(3, 0))
+	if err != nil {
+		t.Fatalf("Rolled back app should be able to use the DB. %v", err)
+	}
+	if result.Scenario != room.ScenarioNewerCompatible || result.Destroyed || result.Version != 4 {
+		t.Errorf("Expected DB at version 4 to be used as is. Got %+v", result)
+	}
+
+	var userCount int
+	db.Model(&latest.User{}).Count(&userCount)
+	if userCount != 1 {
+		t.Errorf("Expected users to be retained. Got %v users", userCount)
+	}
+
+	//Version 2 is not declared compatible by version 4 hence rolling back further destroys the DB as before
+	if result, err = groom.Initialize(newRoom(2, 0)); err != nil || !result.Destroyed {
+		t.Errorf("Expected destructive fallback for an incompatible newer DB. Got %+v %v", result, err)
+	}
+	if result.Scenario != room.ScenarioCreate || result.PreviousVersion != 4 || result.Version != 2 {
+		t.Errorf("Expected the destroyed DB at version 4 to be reported. Got %+v", result)
+	}
+}
+
+//TestSchemaMasterIntegrityWithGORM Modifications of the schema master outside Room are detected and repaired
+func TestSchemaMasterIntegrityWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	entitiesForVersionsArr := getEntitiesForVersions()
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            entitiesForVersionsArr[1],
+		DBA:                 gormAdapter,
+		Version:             2,
+		Migrations:          migrations.GetMigrations(),
+		IdentityCalculator:  new(adapter.EntityHashConstructor),
+		DestructiveFallback: room.DestructiveFallbackToCleanDB,
+		Integrity:           room.StrictIntegrity,
+		IntegrityKey:        []byte("device secret"),
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.Initialize(appDB); err != nil {
+		t.Fatalf("Unable to create DB. %v", err)
+	}
+	db.Create(&old.User{Name: "Alice"})
+
+	db.Exec("INSERT INTO go_room_schema_masters(version, identity_hash) VALUES (1, 'forged')")
+	_, err := groom.Initialize(appDB)
+	if integrityErr, ok := err.(*room.SchemaMasterIntegrityError); !ok || integrityErr.Violation != room.ConflictingRows {
+		t.Errorf("Expected conflicting rows to be detected. Got %v", err)
+	}
+
+	if err = appDB.RepairSchemaMaster(); err != nil {
+		t.Fatalf("Unable to repair Schema Master. %v", err)
+	}
+	if _, err = groom.Initialize(appDB); err != nil {
+		t.Errorf("Repaired Schema Master should be usable. %v", err)
+	}
+
+	db.Exec("UPDATE go_room_schema_masters SET min_compatible_version = 1")
+	_, err = groom.Initialize(appDB)
+	if integrityErr, ok := err.(*room.SchemaMasterIntegrityError); !ok || integrityErr.Violation != room.ChecksumMismatch {
+		t.Errorf("Expected modified row to be detected. Got %v", err)
+	}
+
+	var userCount int
+	db.Model(&old.User{}).Count(&userCount)
+	if userCount != 1 {
+		t.Errorf("Expected users to be retained. Got %v users", userCount)
+	}
+}
+
+//TestMissingSchemaMasterWithGORM Version of tables which lost their schema master is detected from the schema snapshots and migrated from
+func TestMissingSchemaMasterWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+
+	baseDB, errList := room.New(entitiesForVersionsArr[1], gormAdapter, 2, migrations.GetMigrations(), identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(baseDB, false); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+	db.Create(&old.User{Name: "Alice"})
+	db.DropTable(room.GoRoomSchemaMaster{})
+
+	snapshots, err := room.LoadSchemaSnapshots(schemaSnapshotDir)
+	if err != nil {
+		t.Fatalf("Unable to load schema snapshots. %v", err)
+	}
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            entitiesForVersionsArr[3],
+		DBA:                 gormAdapter,
+		Version:             4,
+		Migrations:          migrations.GetMigrations(),
+		IdentityCalculator:  identityCalculator,
+		MissingSchemaMaster: room.DetectVersionFromSnapshots,
+		SchemaSnapshots:     snapshots,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	result, err := groom.Initialize(appDB)
+	if err != nil {
+		t.Fatalf("Expected the detected version to be migrated. %v", err)
+	}
+	if result.PreviousVersion != 2 || result.Version != 4 || len(result.MigrationsApplied) != 2 || result.MigrationsApplied[0].From != 2 {
+		t.Errorf("Expected a migration from detected version 2 to 4. Got %+v", result)
+	}
+
+	var userCount int
+	db.Model(&latest.User{}).Count(&userCount)
+	if userCount != 1 {
+		t.Errorf("Expected users to be retained. Got %v users", userCount)
+	}
+}
+
+//TestMissingSchemaMasterOfVersionsSharingColumnsWithGORM Version 3 and 4 have the same columns. Version 3 is told apart by its foreign key
+//instead of being taken for version 4 and skipping the migration to it
+func TestMissingSchemaMasterOfVersionsSharingColumnsWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	identityCalculator := new(adapter.EntityHashConstructor)
+	entitiesForVersionsArr := getEntitiesForVersions()
+
+	baseDB, errList := room.New(entitiesForVersionsArr[2], gormAdapter, 3, migrations.GetMigrations(), identityCalculator)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	if _, err := groom.InitializeRoom(baseDB, false); err != nil {
+		t.Fatalf("Unable to create base DB. %v", err)
+	}
+	db.DropTable(room.GoRoomSchemaMaster{})
+
+	snapshots, err := room.LoadSchemaSnapshots(schemaSnapshotDir)
+	if err != nil {
+		t.Fatalf("Unable to load schema snapshots. %v", err)
+	}
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            entitiesForVersionsArr[3],
+		DBA:                 gormAdapter,
+		Version:             4,
+		Migrations:          migrations.GetMigrations(),
+		IdentityCalculator:  identityCalculator,
+		MissingSchemaMaster: room.DetectVersionFromSnapshots,
+		SchemaSnapshots:     snapshots,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	result, err := groom.Initialize(appDB)
+	if err != nil {
+		t.Fatalf("Expected the detected version to be migrated. %v", err)
+	}
+	if result.PreviousVersion != 3 || len(result.MigrationsApplied) != 1 || result.MigrationsApplied[0].From != 3 {
+		t.Errorf("Expected a migration from detected version 3 to 4. Got %+v", result)
+	}
+}
+
+//TestBaselineWithGORM Database created without Room is baselined at the version it matches and migrated by later boots
+func TestBaselineWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	//Tables created by an app before it adopted Room
+	db.CreateTable(old.User{}, old.Profile{})
+	db.Create(&old.User{Name: "Alice"})
+
+	snapshots, err := room.LoadSchemaSnapshots(schemaSnapshotDir)
+	if err != nil {
+		t.Fatalf("Unable to load schema snapshots. %v", err)
+	}
+	entitiesForVersionsArr := getEntitiesForVersions()
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:           entitiesForVersionsArr[3],
+		DBA:                gormAdapter,
+		Version:            4,
+		Migrations:         migrations.GetMigrations(),
+		IdentityCalculator: new(adapter.EntityHashConstructor),
+		SchemaSnapshots:    snapshots,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	if err = appDB.Baseline(3, true); err == nil {
+		t.Errorf("Tables of version 2 should not pass verification against version 3")
+	}
+	if err = appDB.Baseline(2, true); err != nil {
+		t.Fatalf("Unable to baseline the DB. %v", err)
+	}
+
+	result, err := groom.Initialize(appDB)
+	if err != nil {
+		t.Fatalf("Expected the baselined DB to be migrated. %v", err)
+	}
+	if result.Scenario != room.ScenarioMigration || result.PreviousVersion != 2 {
+		t.Errorf("Expected a migration from the baselined version. Got %+v", result)
+	}
+
+	var userCount int
+	db.Model(&latest.User{}).Count(&userCount)
+	if userCount != 1 {
+		t.Errorf("Expected users to be retained. Got %v users", userCount)
+	}
+}
+
+//creditsMigration Repeatable migration granting every user the configured credits
+type creditsMigration struct {
+	credits int
+}
+
+//GetName ...
+func (m *creditsMigration) GetName() string {
+	return "default_credits"
+}
+
+//GetChecksum ...
+func (m *creditsMigration) GetChecksum() string {
+	return fmt.Sprint(m.credits)
+}
+
+//Apply ...
+func (m *creditsMigration) Apply(db interface{}) error {
+	return db.(*gorm.DB).Model(&latest.User{}).Update("credits", m.credits).Error
+}
+
+//TestRepeatableMigrationsAndSeedsWithGORM Seeds run only on first install while repeatable migrations run whenever their content changes
+func TestRepeatableMigrationsAndSeedsWithGORM(t *testing.T) {
+
+	db, gormAdapter := getDBAndGORMAdapter(":memory:")
+	db.DB().SetMaxOpenConns(1)
+	defer db.Close()
+
+	entitiesForVersionsArr := getEntitiesForVersions()
+	newRoom := func(credits int) *room.Room {
+		appDB, errList := room.NewFromConfig(room.Config{
+			Entities:             entitiesForVersionsArr[3],
+			DBA:                  gormAdapter,
+			Version:              4,
+			Migrations:           migrations.GetMigrations(),
+			IdentityCalculator:   new(adapter.EntityHashConstructor),
+			RepeatableMigrations: []orm.RepeatableMigration{&creditsMigration{credits: credits}},
+			Seeds: []func(db interface{}) error{
+				func(db interface{}) error {
+					return db.(*gorm.DB).Create(&latest.User{Name: "Admin"}).Error
+				},
+			},
+		})
+		if len(errList) > 0 {
+			panic(errList)
+		}
+		return appDB
+	}
+	verifyCredits := func(expected int) {
+		var users []latest.User
+		db.Find(&users)
+		if len(users) != 1 || users[0].Credits != expected {
+			t.Errorf("Expected a single seeded user with %v credits. Got %+v", expected, users)
+		}
+	}
+
+	result, err := groom.Initialize(newRoom(10))
+	if err != nil {
+		t.Fatalf("Unable to create the DB. %v", err)
+	}
+	if result.Scenario != room.ScenarioCreate || len(result.RepeatableMigrationsApplied) != 1 {
+		t.Errorf("Expected the repeatable migration to be applied after creation. Got %+v", result)
+	}
+	verifyCredits(10)
+
+	db.Model(&latest.User{}).Update("credits", 0)
+	if result, err = groom.Initialize(newRoom(10)); err != nil || len(result.RepeatableMigrationsApplied) != 0 {
+		t.Errorf("Unchanged repeatable migration should not be applied again. Got %+v, %v", result, err)
+	}
+	verifyCredits(0)
+
+	if result, err = groom.Initialize(newRoom(20)); err != nil || len(result.RepeatableMigrationsApplied) != 1 {
+		t.Errorf("Changed repeatable migration should be applied. Got %+v, %v", result, err)
+	}
+	verifyCredits(20)
+}
+
+func getEntitiesForVersions() [][]interface{} {
+
+	//A Data Store is represented by the tables(entities) it houses. Below we will define a snapshot each of a DB in various versions.
+
+	entitiesForVersionsArr := [][]interface{}{}
+	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{old.User{}})                      //First Data Store Version with just User Table
+	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{old.User{}, old.Profile{}})       //Profile Table Added
+	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{latest.User{}, old.Profile{}})    //User Table upgraded to have a new column for Credits
+	entitiesForVersionsArr = append(entitiesForVersionsArr, []interface{}{latest.User{}, latest.Profile{}}) //Profile Table upgraded to have foreign key relationship with User
+
+	return entitiesForVersionsArr
+}
+
 func verifyThatMigrationWorksForEachCombinationOfSourceAndTargetVersion(entitiesForVersionsArr [][]interface{}) bool {
 
 	dbFilePath := "test_goroom.db"
@@ -84,7 +797,7 @@ func verifyThatMigrationWorksForEachCombinationOfSourceAndTargetVersion(entities
 			logger.Infof("Testing Init for Version %v with base %v", currentVersionNumber, srcVersionNumber)
 
 			//Initialize Room
-			err := groom.InitializeRoom(appDB, false)
+			_, err := groom.InitializeRoom(appDB, false)
 			if err != nil {
 				panic(fmt.Errorf("Error while init for Version %v", currentVersionNumber))
 			}
@@ -125,7 +838,7 @@ func prepareDBForMigrationTesting(dbFilePath string, entities []interface{}, src
 	identityExpected, _ := appDB.CalculateIdentityHash()
 	logger.Infof("Identity Hash for version %v expected to be %v", srcVersionNumber, identityExpected)
 
-	err = groom.InitializeRoom(appDB, false)
+	_, err = groom.InitializeRoom(appDB, false)
 	identity, version, err := gormAdapter.GetLatestSchemaIdentityHashAndVersion()
 	if err != nil {
 		panic(err)
@@ -7,6 +7,7 @@ import (
 	"github.com/adonmo/goroom/example/models/old"
 	"github.com/adonmo/goroom/logger"
 	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/util/check"
 	"github.com/jinzhu/gorm"
 )
 
@@ -15,6 +16,7 @@ type UserDBMigration struct {
 	BaseVersion   orm.VersionNumber
 	TargetVersion orm.VersionNumber
 	MigrationFunc func(db interface{}) error
+	check.Checks  //Pre and post conditions on the data run by Room around Apply
 }
 
 //GetBaseVersion ...
@@ -60,6 +62,7 @@ func GetMigrations() (migrations []orm.Migration) {
 
 			return gormDB.AutoMigrate(latest.User{}).Error
 		},
+		Checks: check.Checks{check.RowCountPreserved("users")},
 	}
 
 	var migration34 = &UserDBMigration{
@@ -73,6 +76,7 @@ func GetMigrations() (migrations []orm.Migration) {
 
 			return gormDB.AutoMigrate(latest.Profile{}).Error
 		},
+		Checks: check.Checks{check.RowCountPreserved("profiles"), check.RowCountPreserved("users")},
 	}
 
 	migrations = append(migrations, migration12, migration23, migration34)
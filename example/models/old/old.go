@@ -8,10 +8,10 @@ type User struct {
 	Name string
 }
 
-//Profile `Profile` belongs to `User`, `UserID` is the foreign key
+//Profile `Profile` belongs to `User`, `UserID` is the foreign key which the constraint tag creates in the DB
 type Profile struct {
 	gorm.Model
 	UserID int
-	User   User
+	User   User `gorm:"constraint"`
 	Name   string
 }
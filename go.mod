@@ -1,10 +1,19 @@
 module github.com/adonmo/goroom
 
-go 1.14
+go 1.17
 
 require (
 	github.com/go-test/deep v1.0.6
 	github.com/golang/mock v1.4.3
 	github.com/jinzhu/gorm v1.9.12
+	github.com/lib/pq v1.1.1
+	github.com/mattn/go-sqlite3 v2.0.1+incompatible
 	github.com/stretchr/testify v1.5.1
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
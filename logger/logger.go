@@ -87,3 +87,32 @@ func Warnf(format string, v ...interface{}) {
 func Errorf(format string, v ...interface{}) {
 	logFormattedWithLabel(ERROR, format, v...)
 }
+
+//Logger Interface to plug in a custom logger in place of the standard one
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+type standardLogger struct{}
+
+//Standard Logger writing to the standard log package with level labels
+var Standard Logger = standardLogger{}
+
+func (standardLogger) Debugf(format string, v ...interface{}) {
+	Debugf(format, v...)
+}
+
+func (standardLogger) Infof(format string, v ...interface{}) {
+	Infof(format, v...)
+}
+
+func (standardLogger) Warnf(format string, v ...interface{}) {
+	Warnf(format, v...)
+}
+
+func (standardLogger) Errorf(format string, v ...interface{}) {
+	Errorf(format, v...)
+}
@@ -1,23 +1,47 @@
 package goroom
 
 import (
+	"time"
+
 	"github.com/adonmo/goroom/room"
 )
 
-//InitializeRoom Initialize Room
-func InitializeRoom(initializer room.Initializer, fallbackToDestructiveMigration bool) error {
+//InitializeRoom Initialize Room. The result describes what was done even when initialization fails
+func InitializeRoom(initializer room.Initializer, fallbackToDestructiveMigration bool) (result *room.InitResult, err error) {
+	start := time.Now()
+	result = &room.InitResult{}
+	defer func() {
+		result.Timings.Total = time.Since(start)
+	}()
 
 	identityHash, err := initializer.CalculateIdentityHash()
+	result.Timings.IdentityHash = time.Since(start)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	shouldRetryAfterDestruction, err := initializer.Init(identityHash)
+	addLastResult(result, initializer)
 	if err != nil && shouldRetryAfterDestruction && fallbackToDestructiveMigration {
-		if err = initializer.PerformDBCleanUp(); err == nil {
-			_, err = initializer.Init(identityHash)
+		err = initializer.PerformDBCleanUp()
+		addLastResult(result, initializer)
+		if err == nil {
+			shouldRetryAfterDestruction, err = initializer.Init(identityHash)
+			addLastResult(result, initializer)
 		}
 	}
 
-	return err
+	result.Unusable = err != nil && shouldRetryAfterDestruction
+	return result, err
+}
+
+//Initialize Initialize Room following the destructive fallback policy it was configured with
+func Initialize(initializer room.ConfiguredInitializer) (*room.InitResult, error) {
+	return InitializeRoom(initializer, initializer.GetDestructiveFallbackPolicy() != room.NoDestructiveFallback)
+}
+
+func addLastResult(result *room.InitResult, initializer room.Initializer) {
+	if provider, ok := initializer.(room.InitResultProvider); ok {
+		result.Add(provider.GetLastResult())
+	}
 }
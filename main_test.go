@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/adonmo/goroom/room"
 	"github.com/adonmo/goroom/room/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -28,9 +29,9 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithIdentityCalculationEr
 	s.Initializer.EXPECT().CalculateIdentityHash().Return("", expectedError).Times(2)
 
 	//With Fallback Enabled
-	assert.Equal(s.T(), expectedError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), expectedError, getError(InitializeRoom(s.Initializer, true)))
 	//Without Fallback Enabled
-	assert.Equal(s.T(), expectedError, InitializeRoom(s.Initializer, false))
+	assert.Equal(s.T(), expectedError, getError(InitializeRoom(s.Initializer, false)))
 }
 
 func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
@@ -44,7 +45,7 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 		s.Initializer.EXPECT().Init(identityHash).Return(false, initError),
 	)
 	//With Fallback Enabled
-	assert.Equal(s.T(), initError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), initError, getError(InitializeRoom(s.Initializer, true)))
 
 	//With Retry not Recommended
 	gomock.InOrder(
@@ -52,7 +53,7 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 		s.Initializer.EXPECT().Init(identityHash).Return(false, initError),
 	)
 	//With Fallback not Enabled
-	assert.Equal(s.T(), initError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), initError, getError(InitializeRoom(s.Initializer, true)))
 
 	//With Retry Recommended and Clean up success
 	gomock.InOrder(
@@ -63,7 +64,7 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 	)
 
 	//With Fallback Enabled
-	assert.Equal(s.T(), nil, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), nil, getError(InitializeRoom(s.Initializer, true)))
 
 	//With Retry Recommended and Clean up success
 	gomock.InOrder(
@@ -71,7 +72,7 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 		s.Initializer.EXPECT().Init(identityHash).Return(false, initError),
 	)
 	//With Fallback not Enabled
-	assert.Equal(s.T(), initError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), initError, getError(InitializeRoom(s.Initializer, true)))
 
 	dbCleanUpError := fmt.Errorf("Error in DB Cleanup")
 	//With Retry Recommended and Clean up error
@@ -82,7 +83,7 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 	)
 
 	//With Fallback Enabled
-	assert.Equal(s.T(), dbCleanUpError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), dbCleanUpError, getError(InitializeRoom(s.Initializer, true)))
 
 	//With Retry Recommended and Clean up error
 	gomock.InOrder(
@@ -90,10 +91,151 @@ func (s *RoomInitialzationTestSuite) TestInitializeRoomWithErrorOnFirstInit() {
 		s.Initializer.EXPECT().Init(identityHash).Return(false, initError),
 	)
 	//With Fallback not Enabled
-	assert.Equal(s.T(), initError, InitializeRoom(s.Initializer, true))
+	assert.Equal(s.T(), initError, getError(InitializeRoom(s.Initializer, true)))
 
 }
 
+func (s *RoomInitialzationTestSuite) TestInitializeRoomReportsUnusableDB() {
+
+	identityHash := "asasasawfw"
+	initError := fmt.Errorf("Error during initialization")
+
+	//Without Fallback Enabled
+	gomock.InOrder(
+		s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(true, initError),
+	)
+	result, err := InitializeRoom(s.Initializer, false)
+	assert.Equal(s.T(), initError, err)
+	assert.True(s.T(), result.Unusable)
+
+	//With Retry not Recommended
+	gomock.InOrder(
+		s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(false, initError),
+	)
+	result, _ = InitializeRoom(s.Initializer, false)
+	assert.False(s.T(), result.Unusable)
+
+	//With Fallback Enabled and Init succeeding after Clean up
+	gomock.InOrder(
+		s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(true, initError),
+		s.Initializer.EXPECT().PerformDBCleanUp().Return(nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(false, nil),
+	)
+	result, _ = InitializeRoom(s.Initializer, true)
+	assert.False(s.T(), result.Unusable)
+}
+
+func (s *RoomInitialzationTestSuite) TestInitializeWithConfiguredPolicy() {
+
+	identityHash := "asasasawfw"
+	initError := fmt.Errorf("Error during initialization")
+	initializer := mocks.NewMockConfiguredInitializer(s.MockCtrl)
+
+	//With Fallback Enabled by policy
+	gomock.InOrder(
+		initializer.EXPECT().GetDestructiveFallbackPolicy().Return(room.DestructiveFallbackToCleanDB),
+		initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		initializer.EXPECT().Init(identityHash).Return(true, initError),
+		initializer.EXPECT().PerformDBCleanUp().Return(nil),
+		initializer.EXPECT().Init(identityHash).Return(false, nil),
+	)
+	assert.Equal(s.T(), nil, getError(Initialize(initializer)))
+
+	//With Fallback Disabled by policy
+	gomock.InOrder(
+		initializer.EXPECT().GetDestructiveFallbackPolicy().Return(room.NoDestructiveFallback),
+		initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		initializer.EXPECT().Init(identityHash).Return(true, initError),
+	)
+	assert.Equal(s.T(), initError, getError(Initialize(initializer)))
+}
+
+func (s *RoomInitialzationTestSuite) TestInitializeRoomResult() {
+
+	identityHash := "asasasawfw"
+	initError := fmt.Errorf("Error during initialization")
+	initializer := &ResultProvidingInitializer{
+		MockInitializer: s.Initializer,
+		Results: []*room.InitResult{
+			{Scenario: room.ScenarioMigration, PreviousVersion: 2, Version: 2},
+			{Destroyed: true, TablesDropped: []string{"users"}},
+			{Scenario: room.ScenarioCreate, Version: 3, TablesCreated: []string{"users"}},
+		},
+	}
+
+	gomock.InOrder(
+		s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(true, initError),
+		s.Initializer.EXPECT().PerformDBCleanUp().Return(nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(false, nil),
+	)
+
+	result, err := InitializeRoom(initializer, true)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), room.ScenarioCreate, result.Scenario)
+	assert.Equal(s.T(), 2, int(result.PreviousVersion))
+	assert.Equal(s.T(), 3, int(result.Version))
+	assert.True(s.T(), result.Destroyed)
+	assert.Equal(s.T(), []string{"users"}, result.TablesDropped)
+	assert.Equal(s.T(), []string{"users"}, result.TablesCreated)
+	assert.True(s.T(), result.Timings.Total >= result.Timings.IdentityHash)
+
+	//Initializers which do not describe their results still give a result
+	s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil)
+	s.Initializer.EXPECT().Init(identityHash).Return(false, initError)
+	result, err = InitializeRoom(s.Initializer, true)
+	assert.Equal(s.T(), initError, err)
+	assert.NotNil(s.T(), result)
+	assert.Empty(s.T(), result.Scenario)
+}
+
+func (s *RoomInitialzationTestSuite) TestInitializeRoomResultAfterFailedDowngrade() {
+
+	identityHash := "asasasawfw"
+	initError := fmt.Errorf("Unable to generate path for migration from 5 to 3")
+	initializer := &ResultProvidingInitializer{
+		MockInitializer: s.Initializer,
+		Results: []*room.InitResult{
+			{PreviousVersion: 5, Version: 5},
+			{Destroyed: true, TablesDropped: []string{"users"}},
+			{Scenario: room.ScenarioCreate, Version: 3, TablesCreated: []string{"users"}},
+		},
+	}
+
+	gomock.InOrder(
+		s.Initializer.EXPECT().CalculateIdentityHash().Return(identityHash, nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(true, initError),
+		s.Initializer.EXPECT().PerformDBCleanUp().Return(nil),
+		s.Initializer.EXPECT().Init(identityHash).Return(false, nil),
+	)
+
+	result, err := InitializeRoom(initializer, true)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), room.ScenarioCreate, result.Scenario)
+	assert.Equal(s.T(), 5, int(result.PreviousVersion), "Version of the destroyed DB should be kept")
+	assert.Equal(s.T(), 3, int(result.Version))
+	assert.True(s.T(), result.Destroyed)
+}
+
+type ResultProvidingInitializer struct {
+	*mocks.MockInitializer
+	Results []*room.InitResult
+}
+
+func (initializer *ResultProvidingInitializer) GetLastResult() *room.InitResult {
+	result := initializer.Results[0]
+	initializer.Results = initializer.Results[1:]
+	return result
+}
+
+func getError(_ *room.InitResult, err error) error {
+	return err
+}
+
 func TestMain(t *testing.T) {
 	suite.Run(t, new(RoomInitialzationTestSuite))
+	suite.Run(t, new(TenantInitializationTestSuite))
 }
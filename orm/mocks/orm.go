@@ -169,6 +169,121 @@ func (mr *MockORMMockRecorder) DoInTransaction(fc interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoInTransaction", reflect.TypeOf((*MockORM)(nil).DoInTransaction), fc)
 }
 
+// MockRowLoader is a mock of RowLoader interface
+type MockRowLoader struct {
+	ctrl     *gomock.Controller
+	recorder *MockRowLoaderMockRecorder
+}
+
+// MockRowLoaderMockRecorder is the mock recorder for MockRowLoader
+type MockRowLoaderMockRecorder struct {
+	mock *MockRowLoader
+}
+
+// NewMockRowLoader creates a new mock instance
+func NewMockRowLoader(ctrl *gomock.Controller) *MockRowLoader {
+	mock := &MockRowLoader{ctrl: ctrl}
+	mock.recorder = &MockRowLoaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRowLoader) EXPECT() *MockRowLoaderMockRecorder {
+	return m.recorder
+}
+
+// FindAll mocks base method
+func (m *MockRowLoader) FindAll(entity, out interface{}) orm.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", entity, out)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// FindAll indicates an expected call of FindAll
+func (mr *MockRowLoaderMockRecorder) FindAll(entity, out interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockRowLoader)(nil).FindAll), entity, out)
+}
+
+// MockAutoMigrator is a mock of AutoMigrator interface
+type MockAutoMigrator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAutoMigratorMockRecorder
+}
+
+// MockAutoMigratorMockRecorder is the mock recorder for MockAutoMigrator
+type MockAutoMigratorMockRecorder struct {
+	mock *MockAutoMigrator
+}
+
+// NewMockAutoMigrator creates a new mock instance
+func NewMockAutoMigrator(ctrl *gomock.Controller) *MockAutoMigrator {
+	mock := &MockAutoMigrator{ctrl: ctrl}
+	mock.recorder = &MockAutoMigratorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAutoMigrator) EXPECT() *MockAutoMigratorMockRecorder {
+	return m.recorder
+}
+
+// AutoMigrate mocks base method
+func (m *MockAutoMigrator) AutoMigrate(entities ...interface{}) orm.Result {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range entities {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AutoMigrate", varargs...)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// AutoMigrate indicates an expected call of AutoMigrate
+func (mr *MockAutoMigratorMockRecorder) AutoMigrate(entities ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AutoMigrate", reflect.TypeOf((*MockAutoMigrator)(nil).AutoMigrate), entities...)
+}
+
+// MockTableRenamer is a mock of TableRenamer interface
+type MockTableRenamer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTableRenamerMockRecorder
+}
+
+// MockTableRenamerMockRecorder is the mock recorder for MockTableRenamer
+type MockTableRenamerMockRecorder struct {
+	mock *MockTableRenamer
+}
+
+// NewMockTableRenamer creates a new mock instance
+func NewMockTableRenamer(ctrl *gomock.Controller) *MockTableRenamer {
+	mock := &MockTableRenamer{ctrl: ctrl}
+	mock.recorder = &MockTableRenamerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTableRenamer) EXPECT() *MockTableRenamerMockRecorder {
+	return m.recorder
+}
+
+// RenameTable mocks base method
+func (m *MockTableRenamer) RenameTable(from, to string) orm.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameTable", from, to)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// RenameTable indicates an expected call of RenameTable
+func (mr *MockTableRenamerMockRecorder) RenameTable(from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameTable", reflect.TypeOf((*MockTableRenamer)(nil).RenameTable), from, to)
+}
+
 // MockIdentityHashCalculator is a mock of IdentityHashCalculator interface
 type MockIdentityHashCalculator struct {
 	ctrl     *gomock.Controller
@@ -207,67 +322,1189 @@ func (mr *MockIdentityHashCalculatorMockRecorder) ConstructHash(entityModel inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConstructHash", reflect.TypeOf((*MockIdentityHashCalculator)(nil).ConstructHash), entityModel)
 }
 
-// MockMigration is a mock of Migration interface
-type MockMigration struct {
+// MockLocker is a mock of Locker interface
+type MockLocker struct {
 	ctrl     *gomock.Controller
-	recorder *MockMigrationMockRecorder
+	recorder *MockLockerMockRecorder
 }
 
-// MockMigrationMockRecorder is the mock recorder for MockMigration
-type MockMigrationMockRecorder struct {
-	mock *MockMigration
+// MockLockerMockRecorder is the mock recorder for MockLocker
+type MockLockerMockRecorder struct {
+	mock *MockLocker
 }
 
-// NewMockMigration creates a new mock instance
-func NewMockMigration(ctrl *gomock.Controller) *MockMigration {
-	mock := &MockMigration{ctrl: ctrl}
-	mock.recorder = &MockMigrationMockRecorder{mock}
+// NewMockLocker creates a new mock instance
+func NewMockLocker(ctrl *gomock.Controller) *MockLocker {
+	mock := &MockLocker{ctrl: ctrl}
+	mock.recorder = &MockLockerMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockMigration) EXPECT() *MockMigrationMockRecorder {
+func (m *MockLocker) EXPECT() *MockLockerMockRecorder {
 	return m.recorder
 }
 
-// GetBaseVersion mocks base method
-func (m *MockMigration) GetBaseVersion() orm.VersionNumber {
+// Lock mocks base method
+func (m *MockLocker) Lock() error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBaseVersion")
-	ret0, _ := ret[0].(orm.VersionNumber)
+	ret := m.ctrl.Call(m, "Lock")
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GetBaseVersion indicates an expected call of GetBaseVersion
-func (mr *MockMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+// Lock indicates an expected call of Lock
+func (mr *MockLockerMockRecorder) Lock() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockMigration)(nil).GetBaseVersion))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLocker)(nil).Lock))
 }
 
-// GetTargetVersion mocks base method
-func (m *MockMigration) GetTargetVersion() orm.VersionNumber {
+// Unlock mocks base method
+func (m *MockLocker) Unlock() error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTargetVersion")
-	ret0, _ := ret[0].(orm.VersionNumber)
+	ret := m.ctrl.Call(m, "Unlock")
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GetTargetVersion indicates an expected call of GetTargetVersion
-func (mr *MockMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+// Unlock indicates an expected call of Unlock
+func (mr *MockLockerMockRecorder) Unlock() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockMigration)(nil).GetTargetVersion))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLocker)(nil).Unlock))
 }
 
-// Apply mocks base method
-func (m *MockMigration) Apply(db interface{}) error {
+// MockLeasedLocker is a mock of LeasedLocker interface
+type MockLeasedLocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeasedLockerMockRecorder
+}
+
+// MockLeasedLockerMockRecorder is the mock recorder for MockLeasedLocker
+type MockLeasedLockerMockRecorder struct {
+	mock *MockLeasedLocker
+}
+
+// NewMockLeasedLocker creates a new mock instance
+func NewMockLeasedLocker(ctrl *gomock.Controller) *MockLeasedLocker {
+	mock := &MockLeasedLocker{ctrl: ctrl}
+	mock.recorder = &MockLeasedLockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLeasedLocker) EXPECT() *MockLeasedLockerMockRecorder {
+	return m.recorder
+}
+
+// Lock mocks base method
+func (m *MockLeasedLocker) Lock() error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Apply", db)
+	ret := m.ctrl.Call(m, "Lock")
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Apply indicates an expected call of Apply
-func (mr *MockMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+// Lock indicates an expected call of Lock
+func (mr *MockLeasedLockerMockRecorder) Lock() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockMigration)(nil).Apply), db)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLeasedLocker)(nil).Lock))
+}
+
+// Unlock mocks base method
+func (m *MockLeasedLocker) Unlock() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock
+func (mr *MockLeasedLockerMockRecorder) Unlock() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLeasedLocker)(nil).Unlock))
+}
+
+// CheckHeld mocks base method
+func (m *MockLeasedLocker) CheckHeld() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckHeld")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckHeld indicates an expected call of CheckHeld
+func (mr *MockLeasedLockerMockRecorder) CheckHeld() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckHeld", reflect.TypeOf((*MockLeasedLocker)(nil).CheckHeld))
+}
+
+// MockSchemaVerifier is a mock of SchemaVerifier interface
+type MockSchemaVerifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchemaVerifierMockRecorder
+}
+
+// MockSchemaVerifierMockRecorder is the mock recorder for MockSchemaVerifier
+type MockSchemaVerifierMockRecorder struct {
+	mock *MockSchemaVerifier
+}
+
+// NewMockSchemaVerifier creates a new mock instance
+func NewMockSchemaVerifier(ctrl *gomock.Controller) *MockSchemaVerifier {
+	mock := &MockSchemaVerifier{ctrl: ctrl}
+	mock.recorder = &MockSchemaVerifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSchemaVerifier) EXPECT() *MockSchemaVerifierMockRecorder {
+	return m.recorder
+}
+
+// VerifySchema mocks base method
+func (m *MockSchemaVerifier) VerifySchema(db orm.ORM, entities []interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifySchema", db, entities)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifySchema indicates an expected call of VerifySchema
+func (mr *MockSchemaVerifierMockRecorder) VerifySchema(db, entities interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifySchema", reflect.TypeOf((*MockSchemaVerifier)(nil).VerifySchema), db, entities)
+}
+
+// MockSchemaIntrospector is a mock of SchemaIntrospector interface
+type MockSchemaIntrospector struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchemaIntrospectorMockRecorder
+}
+
+// MockSchemaIntrospectorMockRecorder is the mock recorder for MockSchemaIntrospector
+type MockSchemaIntrospectorMockRecorder struct {
+	mock *MockSchemaIntrospector
+}
+
+// NewMockSchemaIntrospector creates a new mock instance
+func NewMockSchemaIntrospector(ctrl *gomock.Controller) *MockSchemaIntrospector {
+	mock := &MockSchemaIntrospector{ctrl: ctrl}
+	mock.recorder = &MockSchemaIntrospectorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSchemaIntrospector) EXPECT() *MockSchemaIntrospectorMockRecorder {
+	return m.recorder
+}
+
+// GetTables mocks base method
+func (m *MockSchemaIntrospector) GetTables() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTables")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTables indicates an expected call of GetTables
+func (mr *MockSchemaIntrospectorMockRecorder) GetTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTables", reflect.TypeOf((*MockSchemaIntrospector)(nil).GetTables))
+}
+
+// GetColumns mocks base method
+func (m *MockSchemaIntrospector) GetColumns(tableName string) ([]orm.ColumnInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetColumns", tableName)
+	ret0, _ := ret[0].([]orm.ColumnInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetColumns indicates an expected call of GetColumns
+func (mr *MockSchemaIntrospectorMockRecorder) GetColumns(tableName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetColumns", reflect.TypeOf((*MockSchemaIntrospector)(nil).GetColumns), tableName)
+}
+
+// GetIndexes mocks base method
+func (m *MockSchemaIntrospector) GetIndexes(tableName string) ([]orm.IndexDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIndexes", tableName)
+	ret0, _ := ret[0].([]orm.IndexDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIndexes indicates an expected call of GetIndexes
+func (mr *MockSchemaIntrospectorMockRecorder) GetIndexes(tableName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIndexes", reflect.TypeOf((*MockSchemaIntrospector)(nil).GetIndexes), tableName)
+}
+
+// GetForeignKeys mocks base method
+func (m *MockSchemaIntrospector) GetForeignKeys(tableName string) ([]orm.ForeignKeyDefinition, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForeignKeys", tableName)
+	ret0, _ := ret[0].([]orm.ForeignKeyDefinition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForeignKeys indicates an expected call of GetForeignKeys
+func (mr *MockSchemaIntrospectorMockRecorder) GetForeignKeys(tableName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForeignKeys", reflect.TypeOf((*MockSchemaIntrospector)(nil).GetForeignKeys), tableName)
+}
+
+// MockConstraintCreator is a mock of ConstraintCreator interface
+type MockConstraintCreator struct {
+	ctrl     *gomock.Controller
+	recorder *MockConstraintCreatorMockRecorder
+}
+
+// MockConstraintCreatorMockRecorder is the mock recorder for MockConstraintCreator
+type MockConstraintCreatorMockRecorder struct {
+	mock *MockConstraintCreator
+}
+
+// NewMockConstraintCreator creates a new mock instance
+func NewMockConstraintCreator(ctrl *gomock.Controller) *MockConstraintCreator {
+	mock := &MockConstraintCreator{ctrl: ctrl}
+	mock.recorder = &MockConstraintCreatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockConstraintCreator) EXPECT() *MockConstraintCreatorMockRecorder {
+	return m.recorder
+}
+
+// HasIndex mocks base method
+func (m *MockConstraintCreator) HasIndex(entity interface{}, indexName string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasIndex", entity, indexName)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HasIndex indicates an expected call of HasIndex
+func (mr *MockConstraintCreatorMockRecorder) HasIndex(entity, indexName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasIndex", reflect.TypeOf((*MockConstraintCreator)(nil).HasIndex), entity, indexName)
+}
+
+// CreateIndex mocks base method
+func (m *MockConstraintCreator) CreateIndex(entity interface{}, index orm.IndexDefinition) orm.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIndex", entity, index)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// CreateIndex indicates an expected call of CreateIndex
+func (mr *MockConstraintCreatorMockRecorder) CreateIndex(entity, index interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIndex", reflect.TypeOf((*MockConstraintCreator)(nil).CreateIndex), entity, index)
+}
+
+// AddForeignKey mocks base method
+func (m *MockConstraintCreator) AddForeignKey(entity interface{}, foreignKey orm.ForeignKeyDefinition) orm.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddForeignKey", entity, foreignKey)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// AddForeignKey indicates an expected call of AddForeignKey
+func (mr *MockConstraintCreatorMockRecorder) AddForeignKey(entity, foreignKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddForeignKey", reflect.TypeOf((*MockConstraintCreator)(nil).AddForeignKey), entity, foreignKey)
+}
+
+// MockSchemaScoper is a mock of SchemaScoper interface
+type MockSchemaScoper struct {
+	ctrl     *gomock.Controller
+	recorder *MockSchemaScoperMockRecorder
+}
+
+// MockSchemaScoperMockRecorder is the mock recorder for MockSchemaScoper
+type MockSchemaScoperMockRecorder struct {
+	mock *MockSchemaScoper
+}
+
+// NewMockSchemaScoper creates a new mock instance
+func NewMockSchemaScoper(ctrl *gomock.Controller) *MockSchemaScoper {
+	mock := &MockSchemaScoper{ctrl: ctrl}
+	mock.recorder = &MockSchemaScoperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSchemaScoper) EXPECT() *MockSchemaScoperMockRecorder {
+	return m.recorder
+}
+
+// InSchema mocks base method
+func (m *MockSchemaScoper) InSchema(schema string) (orm.ORM, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InSchema", schema)
+	ret0, _ := ret[0].(orm.ORM)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InSchema indicates an expected call of InSchema
+func (mr *MockSchemaScoperMockRecorder) InSchema(schema interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InSchema", reflect.TypeOf((*MockSchemaScoper)(nil).InSchema), schema)
+}
+
+// CreateSchema mocks base method
+func (m *MockSchemaScoper) CreateSchema(schema string) orm.Result {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSchema", schema)
+	ret0, _ := ret[0].(orm.Result)
+	return ret0
+}
+
+// CreateSchema indicates an expected call of CreateSchema
+func (mr *MockSchemaScoperMockRecorder) CreateSchema(schema interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchema", reflect.TypeOf((*MockSchemaScoper)(nil).CreateSchema), schema)
+}
+
+// MockInstrumentation is a mock of Instrumentation interface
+type MockInstrumentation struct {
+	ctrl     *gomock.Controller
+	recorder *MockInstrumentationMockRecorder
+}
+
+// MockInstrumentationMockRecorder is the mock recorder for MockInstrumentation
+type MockInstrumentationMockRecorder struct {
+	mock *MockInstrumentation
+}
+
+// NewMockInstrumentation creates a new mock instance
+func NewMockInstrumentation(ctrl *gomock.Controller) *MockInstrumentation {
+	mock := &MockInstrumentation{ctrl: ctrl}
+	mock.recorder = &MockInstrumentationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockInstrumentation) EXPECT() *MockInstrumentationMockRecorder {
+	return m.recorder
+}
+
+// StartOperation mocks base method
+func (m *MockInstrumentation) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOperation", operation, labels)
+	ret0, _ := ret[0].(orm.OperationRecorder)
+	return ret0
+}
+
+// StartOperation indicates an expected call of StartOperation
+func (mr *MockInstrumentationMockRecorder) StartOperation(operation, labels interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOperation", reflect.TypeOf((*MockInstrumentation)(nil).StartOperation), operation, labels)
+}
+
+// MockOperationRecorder is a mock of OperationRecorder interface
+type MockOperationRecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockOperationRecorderMockRecorder
+}
+
+// MockOperationRecorderMockRecorder is the mock recorder for MockOperationRecorder
+type MockOperationRecorderMockRecorder struct {
+	mock *MockOperationRecorder
+}
+
+// NewMockOperationRecorder creates a new mock instance
+func NewMockOperationRecorder(ctrl *gomock.Controller) *MockOperationRecorder {
+	mock := &MockOperationRecorder{ctrl: ctrl}
+	mock.recorder = &MockOperationRecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOperationRecorder) EXPECT() *MockOperationRecorderMockRecorder {
+	return m.recorder
+}
+
+// SetLabel mocks base method
+func (m *MockOperationRecorder) SetLabel(key, value string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetLabel", key, value)
+}
+
+// SetLabel indicates an expected call of SetLabel
+func (mr *MockOperationRecorderMockRecorder) SetLabel(key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLabel", reflect.TypeOf((*MockOperationRecorder)(nil).SetLabel), key, value)
+}
+
+// End mocks base method
+func (m *MockOperationRecorder) End(err error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "End", err)
+}
+
+// End indicates an expected call of End
+func (mr *MockOperationRecorderMockRecorder) End(err interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "End", reflect.TypeOf((*MockOperationRecorder)(nil).End), err)
+}
+
+// MockMigration is a mock of Migration interface
+type MockMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockMigrationMockRecorder
+}
+
+// MockMigrationMockRecorder is the mock recorder for MockMigration
+type MockMigrationMockRecorder struct {
+	mock *MockMigration
+}
+
+// NewMockMigration creates a new mock instance
+func NewMockMigration(ctrl *gomock.Controller) *MockMigration {
+	mock := &MockMigration{ctrl: ctrl}
+	mock.recorder = &MockMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMigration) EXPECT() *MockMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockMigration)(nil).Apply), db)
+}
+
+// MockRepeatableMigration is a mock of RepeatableMigration interface
+type MockRepeatableMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepeatableMigrationMockRecorder
+}
+
+// MockRepeatableMigrationMockRecorder is the mock recorder for MockRepeatableMigration
+type MockRepeatableMigrationMockRecorder struct {
+	mock *MockRepeatableMigration
+}
+
+// NewMockRepeatableMigration creates a new mock instance
+func NewMockRepeatableMigration(ctrl *gomock.Controller) *MockRepeatableMigration {
+	mock := &MockRepeatableMigration{ctrl: ctrl}
+	mock.recorder = &MockRepeatableMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRepeatableMigration) EXPECT() *MockRepeatableMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetName mocks base method
+func (m *MockRepeatableMigration) GetName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetName indicates an expected call of GetName
+func (mr *MockRepeatableMigrationMockRecorder) GetName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetName", reflect.TypeOf((*MockRepeatableMigration)(nil).GetName))
+}
+
+// GetChecksum mocks base method
+func (m *MockRepeatableMigration) GetChecksum() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetChecksum")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetChecksum indicates an expected call of GetChecksum
+func (mr *MockRepeatableMigrationMockRecorder) GetChecksum() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChecksum", reflect.TypeOf((*MockRepeatableMigration)(nil).GetChecksum))
+}
+
+// Apply mocks base method
+func (m *MockRepeatableMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockRepeatableMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockRepeatableMigration)(nil).Apply), db)
+}
+
+// MockValidatedMigration is a mock of ValidatedMigration interface
+type MockValidatedMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockValidatedMigrationMockRecorder
+}
+
+// MockValidatedMigrationMockRecorder is the mock recorder for MockValidatedMigration
+type MockValidatedMigrationMockRecorder struct {
+	mock *MockValidatedMigration
+}
+
+// NewMockValidatedMigration creates a new mock instance
+func NewMockValidatedMigration(ctrl *gomock.Controller) *MockValidatedMigration {
+	mock := &MockValidatedMigration{ctrl: ctrl}
+	mock.recorder = &MockValidatedMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockValidatedMigration) EXPECT() *MockValidatedMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockValidatedMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockValidatedMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockValidatedMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockValidatedMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockValidatedMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockValidatedMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockValidatedMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockValidatedMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockValidatedMigration)(nil).Apply), db)
+}
+
+// Validate mocks base method
+func (m *MockValidatedMigration) Validate(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate
+func (mr *MockValidatedMigrationMockRecorder) Validate(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockValidatedMigration)(nil).Validate), db)
+}
+
+// MockPreValidatedMigration is a mock of PreValidatedMigration interface
+type MockPreValidatedMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockPreValidatedMigrationMockRecorder
+}
+
+// MockPreValidatedMigrationMockRecorder is the mock recorder for MockPreValidatedMigration
+type MockPreValidatedMigrationMockRecorder struct {
+	mock *MockPreValidatedMigration
+}
+
+// NewMockPreValidatedMigration creates a new mock instance
+func NewMockPreValidatedMigration(ctrl *gomock.Controller) *MockPreValidatedMigration {
+	mock := &MockPreValidatedMigration{ctrl: ctrl}
+	mock.recorder = &MockPreValidatedMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPreValidatedMigration) EXPECT() *MockPreValidatedMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockPreValidatedMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockPreValidatedMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockPreValidatedMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockPreValidatedMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockPreValidatedMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockPreValidatedMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockPreValidatedMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockPreValidatedMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockPreValidatedMigration)(nil).Apply), db)
+}
+
+// ValidateBefore mocks base method
+func (m *MockPreValidatedMigration) ValidateBefore(db interface{}) (interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateBefore", db)
+	ret0, _ := ret[0].(interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ValidateBefore indicates an expected call of ValidateBefore
+func (mr *MockPreValidatedMigrationMockRecorder) ValidateBefore(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateBefore", reflect.TypeOf((*MockPreValidatedMigration)(nil).ValidateBefore), db)
+}
+
+// ValidateAfter mocks base method
+func (m *MockPreValidatedMigration) ValidateAfter(db, before interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateAfter", db, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateAfter indicates an expected call of ValidateAfter
+func (mr *MockPreValidatedMigrationMockRecorder) ValidateAfter(db, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateAfter", reflect.TypeOf((*MockPreValidatedMigration)(nil).ValidateAfter), db, before)
+}
+
+// MockReportingMigration is a mock of ReportingMigration interface
+type MockReportingMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockReportingMigrationMockRecorder
+}
+
+// MockReportingMigrationMockRecorder is the mock recorder for MockReportingMigration
+type MockReportingMigrationMockRecorder struct {
+	mock *MockReportingMigration
+}
+
+// NewMockReportingMigration creates a new mock instance
+func NewMockReportingMigration(ctrl *gomock.Controller) *MockReportingMigration {
+	mock := &MockReportingMigration{ctrl: ctrl}
+	mock.recorder = &MockReportingMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockReportingMigration) EXPECT() *MockReportingMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockReportingMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockReportingMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockReportingMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockReportingMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockReportingMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockReportingMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockReportingMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockReportingMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockReportingMigration)(nil).Apply), db)
+}
+
+// ApplyWithProgress mocks base method
+func (m *MockReportingMigration) ApplyWithProgress(db interface{}, reportRowsProcessed func(int64)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyWithProgress", db, reportRowsProcessed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyWithProgress indicates an expected call of ApplyWithProgress
+func (mr *MockReportingMigrationMockRecorder) ApplyWithProgress(db, reportRowsProcessed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyWithProgress", reflect.TypeOf((*MockReportingMigration)(nil).ApplyWithProgress), db, reportRowsProcessed)
+}
+
+// MockNonTransactionalMigration is a mock of NonTransactionalMigration interface
+type MockNonTransactionalMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockNonTransactionalMigrationMockRecorder
+}
+
+// MockNonTransactionalMigrationMockRecorder is the mock recorder for MockNonTransactionalMigration
+type MockNonTransactionalMigrationMockRecorder struct {
+	mock *MockNonTransactionalMigration
+}
+
+// NewMockNonTransactionalMigration creates a new mock instance
+func NewMockNonTransactionalMigration(ctrl *gomock.Controller) *MockNonTransactionalMigration {
+	mock := &MockNonTransactionalMigration{ctrl: ctrl}
+	mock.recorder = &MockNonTransactionalMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockNonTransactionalMigration) EXPECT() *MockNonTransactionalMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockNonTransactionalMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockNonTransactionalMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockNonTransactionalMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockNonTransactionalMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockNonTransactionalMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockNonTransactionalMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockNonTransactionalMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockNonTransactionalMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockNonTransactionalMigration)(nil).Apply), db)
+}
+
+// RunsOutsideTransaction mocks base method
+func (m *MockNonTransactionalMigration) RunsOutsideTransaction() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunsOutsideTransaction")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// RunsOutsideTransaction indicates an expected call of RunsOutsideTransaction
+func (mr *MockNonTransactionalMigrationMockRecorder) RunsOutsideTransaction() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunsOutsideTransaction", reflect.TypeOf((*MockNonTransactionalMigration)(nil).RunsOutsideTransaction))
+}
+
+// MockTableScopedMigration is a mock of TableScopedMigration interface
+type MockTableScopedMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockTableScopedMigrationMockRecorder
+}
+
+// MockTableScopedMigrationMockRecorder is the mock recorder for MockTableScopedMigration
+type MockTableScopedMigrationMockRecorder struct {
+	mock *MockTableScopedMigration
+}
+
+// NewMockTableScopedMigration creates a new mock instance
+func NewMockTableScopedMigration(ctrl *gomock.Controller) *MockTableScopedMigration {
+	mock := &MockTableScopedMigration{ctrl: ctrl}
+	mock.recorder = &MockTableScopedMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTableScopedMigration) EXPECT() *MockTableScopedMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockTableScopedMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockTableScopedMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockTableScopedMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockTableScopedMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockTableScopedMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockTableScopedMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockTableScopedMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockTableScopedMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockTableScopedMigration)(nil).Apply), db)
+}
+
+// GetAffectedTables mocks base method
+func (m *MockTableScopedMigration) GetAffectedTables() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAffectedTables")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetAffectedTables indicates an expected call of GetAffectedTables
+func (mr *MockTableScopedMigrationMockRecorder) GetAffectedTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAffectedTables", reflect.TypeOf((*MockTableScopedMigration)(nil).GetAffectedTables))
+}
+
+// MockAuxiliaryTableMigration is a mock of AuxiliaryTableMigration interface
+type MockAuxiliaryTableMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuxiliaryTableMigrationMockRecorder
+}
+
+// MockAuxiliaryTableMigrationMockRecorder is the mock recorder for MockAuxiliaryTableMigration
+type MockAuxiliaryTableMigrationMockRecorder struct {
+	mock *MockAuxiliaryTableMigration
+}
+
+// NewMockAuxiliaryTableMigration creates a new mock instance
+func NewMockAuxiliaryTableMigration(ctrl *gomock.Controller) *MockAuxiliaryTableMigration {
+	mock := &MockAuxiliaryTableMigration{ctrl: ctrl}
+	mock.recorder = &MockAuxiliaryTableMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAuxiliaryTableMigration) EXPECT() *MockAuxiliaryTableMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockAuxiliaryTableMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockAuxiliaryTableMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockAuxiliaryTableMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockAuxiliaryTableMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockAuxiliaryTableMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockAuxiliaryTableMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockAuxiliaryTableMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockAuxiliaryTableMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockAuxiliaryTableMigration)(nil).Apply), db)
+}
+
+// GetAuxiliaryTables mocks base method
+func (m *MockAuxiliaryTableMigration) GetAuxiliaryTables() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuxiliaryTables")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetAuxiliaryTables indicates an expected call of GetAuxiliaryTables
+func (mr *MockAuxiliaryTableMigrationMockRecorder) GetAuxiliaryTables() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuxiliaryTables", reflect.TypeOf((*MockAuxiliaryTableMigration)(nil).GetAuxiliaryTables))
+}
+
+// MockSpaceEstimatingMigration is a mock of SpaceEstimatingMigration interface
+type MockSpaceEstimatingMigration struct {
+	ctrl     *gomock.Controller
+	recorder *MockSpaceEstimatingMigrationMockRecorder
+}
+
+// MockSpaceEstimatingMigrationMockRecorder is the mock recorder for MockSpaceEstimatingMigration
+type MockSpaceEstimatingMigrationMockRecorder struct {
+	mock *MockSpaceEstimatingMigration
+}
+
+// NewMockSpaceEstimatingMigration creates a new mock instance
+func NewMockSpaceEstimatingMigration(ctrl *gomock.Controller) *MockSpaceEstimatingMigration {
+	mock := &MockSpaceEstimatingMigration{ctrl: ctrl}
+	mock.recorder = &MockSpaceEstimatingMigrationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSpaceEstimatingMigration) EXPECT() *MockSpaceEstimatingMigrationMockRecorder {
+	return m.recorder
+}
+
+// GetBaseVersion mocks base method
+func (m *MockSpaceEstimatingMigration) GetBaseVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBaseVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetBaseVersion indicates an expected call of GetBaseVersion
+func (mr *MockSpaceEstimatingMigrationMockRecorder) GetBaseVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBaseVersion", reflect.TypeOf((*MockSpaceEstimatingMigration)(nil).GetBaseVersion))
+}
+
+// GetTargetVersion mocks base method
+func (m *MockSpaceEstimatingMigration) GetTargetVersion() orm.VersionNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTargetVersion")
+	ret0, _ := ret[0].(orm.VersionNumber)
+	return ret0
+}
+
+// GetTargetVersion indicates an expected call of GetTargetVersion
+func (mr *MockSpaceEstimatingMigrationMockRecorder) GetTargetVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTargetVersion", reflect.TypeOf((*MockSpaceEstimatingMigration)(nil).GetTargetVersion))
+}
+
+// Apply mocks base method
+func (m *MockSpaceEstimatingMigration) Apply(db interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", db)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply
+func (mr *MockSpaceEstimatingMigrationMockRecorder) Apply(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockSpaceEstimatingMigration)(nil).Apply), db)
+}
+
+// EstimateSpaceNeeded mocks base method
+func (m *MockSpaceEstimatingMigration) EstimateSpaceNeeded(db interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateSpaceNeeded", db)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateSpaceNeeded indicates an expected call of EstimateSpaceNeeded
+func (mr *MockSpaceEstimatingMigrationMockRecorder) EstimateSpaceNeeded(db interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateSpaceNeeded", reflect.TypeOf((*MockSpaceEstimatingMigration)(nil).EstimateSpaceNeeded), db)
+}
+
+// MockPreflightCheck is a mock of PreflightCheck interface
+type MockPreflightCheck struct {
+	ctrl     *gomock.Controller
+	recorder *MockPreflightCheckMockRecorder
+}
+
+// MockPreflightCheckMockRecorder is the mock recorder for MockPreflightCheck
+type MockPreflightCheckMockRecorder struct {
+	mock *MockPreflightCheck
+}
+
+// NewMockPreflightCheck creates a new mock instance
+func NewMockPreflightCheck(ctrl *gomock.Controller) *MockPreflightCheck {
+	mock := &MockPreflightCheck{ctrl: ctrl}
+	mock.recorder = &MockPreflightCheckMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPreflightCheck) EXPECT() *MockPreflightCheckMockRecorder {
+	return m.recorder
+}
+
+// GetName mocks base method
+func (m *MockPreflightCheck) GetName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetName indicates an expected call of GetName
+func (mr *MockPreflightCheckMockRecorder) GetName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetName", reflect.TypeOf((*MockPreflightCheck)(nil).GetName))
+}
+
+// Check mocks base method
+func (m *MockPreflightCheck) Check(db orm.ORM, from, to orm.VersionNumber, migrations []orm.Migration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", db, from, to, migrations)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Check indicates an expected call of Check
+func (mr *MockPreflightCheckMockRecorder) Check(db, from, to, migrations interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockPreflightCheck)(nil).Check), db, from, to, migrations)
 }
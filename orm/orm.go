@@ -16,10 +16,56 @@ type ORM interface {
 	DoInTransaction(fc func(tx ORM) error) (err error) //In the event of error returned by fc rollback should happen, nil return value should lead to commit
 }
 
+//RowLoader ORM able to read back all rows of a table. Room needs it for features reading more than the latest version from
+//the schema master e.g. namespaces, integrity checks, orphaned table policies and schema masters with a custom table name
+type RowLoader interface {
+	FindAll(entity interface{}, out interface{}) Result //Loads all rows of the table backing entity into out which is a pointer to a slice
+}
+
+//AutoMigrator ORM able to add missing columns to existing tables. Room upgrades the schema master of older DBs through it
+//before recording a new version. Without it the ORM is expected to keep the schema master up to date itself
+type AutoMigrator interface {
+	AutoMigrate(entities ...interface{}) Result //Adds missing columns of the entities to their tables
+}
+
+//TableRenamer ORM able to rename tables. Needed for archiving orphaned tables
+type TableRenamer interface {
+	RenameTable(from string, to string) Result
+}
+
 //ModelDefinition Interface to access Definition of ORM Entity Model
 type ModelDefinition struct {
 	TableName   string
 	EntityModel interface{}
+	Columns     []string          //Columns backing the entity as named in the DB
+	ColumnTypes map[string]string //Type of each column as the DB reports it once created. Empty if the ORM can not tell for the dialect
+	Indexes     []IndexDefinition
+	ForeignKeys []ForeignKeyDefinition
+}
+
+//ColumnInfo Column of a table as found in the DB
+type ColumnInfo struct {
+	Name         string
+	Type         string //As declared in the DB e.g. varchar(255)
+	Nullable     bool
+	DefaultValue *string //Nil if the column has no default
+	PrimaryKey   bool
+}
+
+//IndexDefinition Index declared by an entity or found in the DB
+type IndexDefinition struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+//ForeignKeyDefinition Foreign key declared by an entity on the table of another entity or found in the DB
+type ForeignKeyDefinition struct {
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+	OnDelete          string `json:",omitempty"` //Referential action e.g. CASCADE. Empty leaves it to the DB. Not reported by introspection
+	OnUpdate          string `json:",omitempty"`
 }
 
 //Result Result from DB operations
@@ -32,9 +78,144 @@ type IdentityHashCalculator interface {
 	ConstructHash(entityModel interface{}) (ans string, err error)
 }
 
+//Locker Lock used by Room to serialize initialization across processes sharing a database
+type Locker interface {
+	Lock() error //Should block till the lock is acquired or the configured wait timeout runs out
+	Unlock() error
+}
+
+//LeasedLocker Locker which can lose the lock while holding it e.g. when its lease could not be renewed.
+//Room checks it before committing changes made under the lock
+type LeasedLocker interface {
+	Locker
+	CheckHeld() error //Should fail if the lock is no longer held
+}
+
+//SchemaVerifier Verifies after migrations that the schema of entity tables matches a fresh install of the entities.
+//Implementations are specific to a dialect e.g. the SQLite verifier of util/verify
+type SchemaVerifier interface {
+	VerifySchema(db ORM, entities []interface{}) error //Returning an error rolls back the migration
+}
+
+//SchemaIntrospector ORM able to describe the schema present in the DB e.g. to detect the version of tables without a schema master
+type SchemaIntrospector interface {
+	GetTables() ([]string, error)                           //Tables present in the DB sorted by name
+	GetColumns(tableName string) ([]ColumnInfo, error)      //In the order of their definition. Empty if the table does not exist
+	GetIndexes(tableName string) ([]IndexDefinition, error) //Indexes created on a table. Ones backing its primary key or unique constraints are left out
+	GetForeignKeys(tableName string) ([]ForeignKeyDefinition, error)
+}
+
+//ConstraintCreator ORM whose CreateTable creates only the columns of a table. Room then adds the indexes and foreign keys declared by entities
+//through it. ORMs creating them along with the table, like the GORM adapter, should not implement it
+type ConstraintCreator interface {
+	HasIndex(entity interface{}, indexName string) bool
+	CreateIndex(entity interface{}, index IndexDefinition) Result
+	AddForeignKey(entity interface{}, foreignKey ForeignKeyDefinition) Result //Constrains columns of the table backing entity to reference another table
+}
+
+//SchemaScoper ORM able to confine its operations to a schema of the DB e.g. a Postgres schema
+type SchemaScoper interface {
+	InSchema(schema string) (ORM, error) //Operations of the ORM returned, including transactions, only see tables of the schema
+	CreateSchema(schema string) Result   //Should succeed if the schema exists already
+}
+
+//Instrumentation Records spans and metrics for operations performed by Room
+type Instrumentation interface {
+	StartOperation(operation string, labels map[string]string) OperationRecorder
+}
+
+//OperationRecorder Tracks an operation started through Instrumentation till it ends
+type OperationRecorder interface {
+	SetLabel(key string, value string)
+	End(err error) //A nil error marks the operation successful
+}
+
 //Migration Interface against users can define their migrations on the DB
 type Migration interface {
 	GetBaseVersion() VersionNumber
 	GetTargetVersion() VersionNumber
 	Apply(db interface{}) error
 }
+
+//RepeatableMigration Migration identified by name that is applied after versioned migrations whenever its checksum changes e.g. reference data
+type RepeatableMigration interface {
+	GetName() string
+	GetChecksum() string //Should change whenever the content applied by the migration changes
+	Apply(db interface{}) error
+}
+
+//ValidatedMigration Migration with post conditions on the data checked in the migration transaction once it is applied
+type ValidatedMigration interface {
+	Migration
+	Validate(db interface{}) error
+}
+
+//PreValidatedMigration Migration with conditions on the data checked in the migration transaction before and after it is applied.
+//What ValidateBefore returns is handed to ValidateAfter of the same run, so that the migration holds no state across runs
+type PreValidatedMigration interface {
+	Migration
+	ValidateBefore(db interface{}) (before interface{}, err error)
+	ValidateAfter(db interface{}, before interface{}) error
+}
+
+//ReportingMigration Migration reporting the rows it has processed while being applied. Useful for long running backfills
+type ReportingMigration interface {
+	Migration
+	ApplyWithProgress(db interface{}, reportRowsProcessed func(rowsProcessed int64)) error
+}
+
+//MigrationStage Stage of a migration within Init
+type MigrationStage int
+
+const (
+	//MigrationStarted Migration is about to be applied
+	MigrationStarted MigrationStage = iota
+	//MigrationRowsProcessed Migration reported the rows processed so far
+	MigrationRowsProcessed
+	//MigrationCompleted Migration was applied and its post conditions passed
+	MigrationCompleted
+)
+
+//MigrationProgress Progress event emitted by Room while performing migrations
+type MigrationProgress struct {
+	Stage         MigrationStage
+	Step          int //1 based index of the migration among the ones being performed
+	TotalSteps    int
+	Migration     Migration
+	RowsProcessed int64 //As last reported by a ReportingMigration. Zero otherwise
+}
+
+//ProgressReporter Receives progress of migrations. It is called within the migration transaction hence should return quickly
+type ProgressReporter func(progress MigrationProgress)
+
+//NonTransactionalMigration Migration that can be applied outside the schema transaction. Room commits the version reached
+//before and after it separately hence it should be able to resume after an interruption
+type NonTransactionalMigration interface {
+	Migration
+	RunsOutsideTransaction() bool
+}
+
+//TableScopedMigration Migration declaring the tables it touches. If it fails selective destructive fallback resets these tables too
+type TableScopedMigration interface {
+	Migration
+	GetAffectedTables() []string
+}
+
+//AuxiliaryTableMigration Migration keeping tables of its own while it is applied e.g. the cursor of a batched migration. Room registers them
+//with its namespace before migrating and drops them in a destructive clean up, as the progress they record is meaningless after it
+type AuxiliaryTableMigration interface {
+	Migration
+	GetAuxiliaryTables() []string //Should be dropped by the migration once it is done
+}
+
+//SpaceEstimatingMigration Migration estimating the additional storage in bytes it needs while being applied e.g. for rebuilding a table
+type SpaceEstimatingMigration interface {
+	Migration
+	EstimateSpaceNeeded(db interface{}) (int64, error)
+}
+
+//PreflightCheck Check of resources run before migrations are performed. Returning an error aborts Init before the DB is changed
+type PreflightCheck interface {
+	GetName() string
+	Check(db ORM, from VersionNumber, to VersionNumber, migrations []Migration) error
+}
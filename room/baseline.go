@@ -0,0 +1,97 @@
+package room
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//Baseline Brings an existing database created without Room under its management by recording the version its tables are at.
+//No table is created or modified. Versions other than the current one need their schema snapshot among the configured SchemaSnapshots.
+//Versions newer than the one of the Room are refused as Init would then find the DB ahead of the Room.
+//With verify the columns of the tables are compared with the snapshot of the version before it is recorded
+func (appDB *Room) Baseline(version orm.VersionNumber, verify bool) (err error) {
+	if appDB.readOnly {
+		return fmt.Errorf("Room opened read only. Baseline of the DB is not allowed")
+	}
+
+	if version > appDB.version {
+		return fmt.Errorf("Version %v to baseline is newer than version %v of the Room", version, appDB.version)
+	}
+
+	if err = appDB.acquireLock(); err != nil {
+		return err
+	}
+	defer appDB.releaseLock()
+
+	if appDB.isSchemaMasterPresent() {
+		return fmt.Errorf("Room Schema Master already exists. Only databases not managed by Room can be baselined")
+	}
+
+	snapshot, err := appDB.getSnapshotForVersion(version)
+	if err != nil {
+		return err
+	}
+
+	if verify {
+		if err = appDB.verifyTablesMatchSnapshot(snapshot); err != nil {
+			return err
+		}
+	}
+
+	if err = appDB.doInTransaction(appDB.getVersionAdoptionFunction(snapshot)); err != nil {
+		appDB.log().Errorf("Unable to baseline the DB at version %v. %v", version, err)
+		return err
+	}
+
+	appDB.log().Infof("Baselined the DB at version %v", version)
+	return nil
+}
+
+//getSnapshotForVersion Snapshot of current entities for current version. Configured snapshots otherwise
+func (appDB *Room) getSnapshotForVersion(version orm.VersionNumber) (*SchemaSnapshot, error) {
+	if version == appDB.version {
+		return appDB.GetSchemaSnapshot()
+	}
+
+	for _, snapshot := range appDB.schemaSnapshots {
+		if snapshot.Version == version {
+			return snapshot, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No schema snapshot found for version %v", version)
+}
+
+//verifyTablesMatchSnapshot Checks that every table of the snapshot exists with exactly the columns it declares. Other tables are ignored.
+//Unlike version detection, indexes and foreign keys are not compared as tables created without Room often lack them
+func (appDB *Room) verifyTablesMatchSnapshot(snapshot *SchemaSnapshot) error {
+	introspector, ok := appDB.dba.(orm.SchemaIntrospector)
+	if !ok {
+		return fmt.Errorf("ORM can not introspect the schema of existing entity tables to verify them")
+	}
+
+	var mismatchedTables []string
+	for _, entity := range snapshot.Entities {
+		if len(entity.Columns) < 1 {
+			return fmt.Errorf("Schema snapshot for version %v has no columns for table %v", snapshot.Version, entity.TableName)
+		}
+
+		table, err := getExistingTable(introspector, entity.TableName)
+		if err != nil {
+			return err
+		}
+		if !isSameSet(entity.Columns, table.getColumnNames()) {
+			mismatchedTables = append(mismatchedTables, entity.TableName)
+		}
+	}
+
+	if len(mismatchedTables) > 0 {
+		sort.Strings(mismatchedTables)
+		appDB.log().Errorf("Tables %v do not match the schema snapshot for version %v", mismatchedTables, snapshot.Version)
+		return fmt.Errorf("Tables %v do not match the schema of version %v", mismatchedTables, snapshot.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BaselineTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	Introspector *mocks.MockSchemaIntrospector
+	AppDB        *Room
+}
+
+func (s *BaselineTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Introspector = mocks.NewMockSchemaIntrospector(s.MockCtrl)
+	identityCalc := mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	s.AppDB = &Room{
+		entities:           []interface{}{DummyTable{}},
+		version:            3,
+		dba:                &IntrospectableORM{s.DBA, s.Introspector},
+		identityCalculator: identityCalc,
+		logger:             &RecordingLogger{},
+		schemaSnapshots: []*SchemaSnapshot{
+			{Version: 2, IdentityHash: "v2", Entities: []EntitySnapshot{
+				{TableName: "dummy_tables", IdentityHash: "d2", Columns: []string{"id"}},
+			}},
+		},
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName: "dummy_tables", EntityModel: "dummyModel", Columns: []string{"id", "value"},
+	}).AnyTimes()
+	identityCalc.EXPECT().ConstructHash(gomock.Any()).Return("v3", nil).AnyTimes()
+}
+
+func (s *BaselineTestSuite) expectSchemaMasterCreation(expected *GoRoomSchemaMaster) {
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.DBA)
+	})
+	s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{})
+	s.DBA.EXPECT().Create(expected).Return(orm.Result{})
+}
+
+func (s *BaselineTestSuite) TestBaselineCurrentVersion() {
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(false)
+	s.Introspector.EXPECT().GetColumns("dummy_tables").Return(getColumnInfos([]string{"value", "id"}), nil)
+	s.Introspector.EXPECT().GetIndexes("dummy_tables").Return(nil, nil).AnyTimes()
+	s.Introspector.EXPECT().GetForeignKeys("dummy_tables").Return(nil, nil).AnyTimes()
+	s.expectSchemaMasterCreation(&GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", EntityHashes: `{"dummy_tables":"v3"}`})
+
+	assert.Nil(s.T(), s.AppDB.Baseline(3, true))
+}
+
+func (s *BaselineTestSuite) TestBaselineEarlierVersionWithoutVerification() {
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(false)
+	s.expectSchemaMasterCreation(&GoRoomSchemaMaster{Version: 2, IdentityHash: "v2", EntityHashes: `{"dummy_tables":"d2"}`})
+
+	assert.Nil(s.T(), s.AppDB.Baseline(2, false))
+}
+
+func (s *BaselineTestSuite) TestBaselineWithMismatchingTables() {
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(false)
+	s.Introspector.EXPECT().GetColumns("dummy_tables").Return(getColumnInfos([]string{"id", "value"}), nil)
+	s.Introspector.EXPECT().GetIndexes("dummy_tables").Return(nil, nil).AnyTimes()
+	s.Introspector.EXPECT().GetForeignKeys("dummy_tables").Return(nil, nil).AnyTimes()
+
+	assert.Equal(s.T(), fmt.Errorf("Tables [dummy_tables] do not match the schema of version 2"), s.AppDB.Baseline(2, true))
+}
+
+func (s *BaselineTestSuite) TestBaselineWithUnknownVersion() {
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(false)
+
+	assert.Equal(s.T(), fmt.Errorf("No schema snapshot found for version 1"), s.AppDB.Baseline(1, false))
+}
+
+func (s *BaselineTestSuite) TestBaselineNewerVersion() {
+	s.AppDB.schemaSnapshots = append(s.AppDB.schemaSnapshots, &SchemaSnapshot{Version: 4, IdentityHash: "v4", Entities: []EntitySnapshot{
+		{TableName: "dummy_tables", IdentityHash: "d4", Columns: []string{"id", "value", "name"}},
+	}})
+
+	assert.Equal(s.T(), fmt.Errorf("Version 4 to baseline is newer than version 3 of the Room"), s.AppDB.Baseline(4, false))
+}
+
+func (s *BaselineTestSuite) TestBaselineManagedDB() {
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(true)
+
+	assert.Equal(s.T(), fmt.Errorf("Room Schema Master already exists. Only databases not managed by Room can be baselined"), s.AppDB.Baseline(3, false))
+}
+
+func (s *BaselineTestSuite) TestBaselineReadOnly() {
+	s.AppDB.readOnly = true
+
+	assert.Equal(s.T(), fmt.Errorf("Room opened read only. Baseline of the DB is not allowed"), s.AppDB.Baseline(3, false))
+}
@@ -0,0 +1,82 @@
+package room
+
+import (
+	"github.com/adonmo/goroom/logger"
+	"github.com/adonmo/goroom/orm"
+)
+
+//DestructiveFallbackPolicy Decides what happens to the DB when initialization fails
+type DestructiveFallbackPolicy int
+
+const (
+	//NoDestructiveFallback Initialization errors are returned as is and the DB is left untouched
+	NoDestructiveFallback DestructiveFallbackPolicy = iota
+	//DestructiveFallbackToCleanDB Schema Master and all known entities are dropped and initialization is retried
+	DestructiveFallbackToCleanDB
+	//SelectiveDestructiveFallback Only entities whose identity hash changed or which migrations from the recorded version declare are reset
+	SelectiveDestructiveFallback
+)
+
+//OrphanedTablePolicy Decides what happens on migration to tables managed by an earlier version that are no longer among the entities
+type OrphanedTablePolicy int
+
+const (
+	//IgnoreOrphanedTables Orphaned tables are left as is and forgotten
+	IgnoreOrphanedTables OrphanedTablePolicy = iota
+	//ReportOrphanedTables Orphaned tables are logged and tracked in the schema master till they are dropped
+	ReportOrphanedTables
+	//ArchiveOrphanedTables Orphaned tables are renamed to <table>_archived_v<version> where version is the one migrated from
+	ArchiveOrphanedTables
+	//DropOrphanedTables Orphaned tables are dropped
+	DropOrphanedTables
+)
+
+//MissingSchemaMasterPolicy Decides what happens when entity tables exist in the DB but the schema master does not
+type MissingSchemaMasterPolicy int
+
+const (
+	//StampCurrentVersion Existing entity tables are assumed to be at the current version
+	StampCurrentVersion MissingSchemaMasterPolicy = iota
+	//DetectVersionFromSnapshots Version whose schema snapshot matches the existing tables is adopted and migrated from. Init fails if none matches
+	DetectVersionFromSnapshots
+	//RefuseExistingTables Init fails without touching the DB
+	RefuseExistingTables
+)
+
+//Hooks Callbacks invoked by Room around initialization. Any of them can be left nil
+type Hooks struct {
+	OnCreate        func(version orm.VersionNumber)                          //After DB is created for the first time
+	BeforeMigration func(from orm.VersionNumber, to orm.VersionNumber) error //Returning an error aborts the migration
+	AfterMigration  func(from orm.VersionNumber, to orm.VersionNumber, err error)
+	BeforeCleanUp   func() error //Returning an error aborts the destructive clean up
+}
+
+//Config Configuration of a Room. Entities, DBA, Version and IdentityCalculator are mandatory
+type Config struct {
+	Entities           []interface{}
+	DBA                orm.ORM
+	Version            orm.VersionNumber
+	Migrations         []orm.Migration
+	IdentityCalculator orm.IdentityHashCalculator
+
+	Namespace             string //Rooms in different namespaces version their entities independently in the same DB
+	Schema                string //Schema of the DB holding the entity tables and schema master. Needs an ORM implementing orm.SchemaScoper
+	SchemaMasterTableName string //Overrides the table name of the schema master derived from the namespace
+	Logger                logger.Logger
+	Hooks                 Hooks
+	DestructiveFallback   DestructiveFallbackPolicy
+	OrphanedTables        OrphanedTablePolicy
+	SchemaVerifier        orm.SchemaVerifier   //Compares the migrated schema with a fresh install before the migration is committed
+	ProgressReporter      orm.ProgressReporter //Receives progress events while migrations are performed
+	Instrumentation       orm.Instrumentation  //Records spans and metrics of identity calculation, Init, migrations and clean up
+	Locker                orm.Locker           //Serializes Init and PerformDBCleanUp across processes
+	ReadOnly              bool                 //Init only verifies compatibility of the DB and PerformDBCleanUp is refused
+	MinCompatibleVersion  orm.VersionNumber    //Oldest app version which can keep using the DB at this version e.g. after a rollback
+	Integrity             IntegrityPolicy      //Signs rows of the schema master and verifies them on Init to detect modifications
+	IntegrityKey          []byte               //Key for HMAC-SHA256 checksums of the schema master. Without it plain SHA-256 only detects accidental corruption
+	MissingSchemaMaster   MissingSchemaMasterPolicy
+	SchemaSnapshots       []*SchemaSnapshot            //Snapshots of earlier versions which are matched against entity tables found without a schema master
+	RepeatableMigrations  []orm.RepeatableMigration    //Applied in the given order after every Init whenever their checksum changes
+	Seeds                 []func(db interface{}) error //Applied in the transaction creating the DB for the first time
+	PreflightChecks       []orm.PreflightCheck         //Run before migrations and their hooks. A failing check aborts Init with a *PreflightError
+}
@@ -0,0 +1,277 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecordingLogger struct {
+	Messages []string
+}
+
+func (l *RecordingLogger) Debugf(format string, v ...interface{}) {
+	l.Messages = append(l.Messages, "[DEBUG] "+fmt.Sprintf(format, v...))
+}
+
+func (l *RecordingLogger) Infof(format string, v ...interface{}) {
+	l.Messages = append(l.Messages, "[INFO] "+fmt.Sprintf(format, v...))
+}
+
+func (l *RecordingLogger) Warnf(format string, v ...interface{}) {
+	l.Messages = append(l.Messages, "[WARN] "+fmt.Sprintf(format, v...))
+}
+
+func (l *RecordingLogger) Errorf(format string, v ...interface{}) {
+	l.Messages = append(l.Messages, "[ERROR] "+fmt.Sprintf(format, v...))
+}
+
+type ConfigTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	Loader       *mocks.MockRowLoader
+	IdentityCalc *mocks.MockIdentityHashCalculator
+	Locker       *mocks.MockLocker
+	Logger       *RecordingLogger
+	Config       Config
+}
+
+func (s *ConfigTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.IdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	s.Locker = mocks.NewMockLocker(s.MockCtrl)
+	s.Logger = &RecordingLogger{}
+	s.Config = Config{
+		Entities:              []interface{}{DummyTable{}},
+		DBA:                   &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader},
+		Version:               3,
+		Migrations:            []orm.Migration{},
+		IdentityCalculator:    s.IdentityCalc,
+		SchemaMasterTableName: "app_metadata",
+		Logger:                s.Logger,
+		DestructiveFallback:   DestructiveFallbackToCleanDB,
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName:   "dummy_tables",
+		EntityModel: DummyTable{},
+	}).AnyTimes()
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false).AnyTimes()
+	s.IdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return("asasasa", nil).AnyTimes()
+}
+
+//expectLatestRecord Custom named schema master is read through orm.RowLoader
+func (s *ConfigTestSuite) expectLatestRecord(appDB *Room, identityHash string, version orm.VersionNumber) *gomock.Call {
+	return s.Loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{IdentityHash: identityHash, Version: version}}).Return(orm.Result{})
+}
+
+func (s *ConfigTestSuite) TestNewFromConfig() {
+	s.Config.Locker = s.Locker
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Empty(s.T(), errList)
+	assert.Equal(s.T(), "app_metadata", appDB.schemaMaster.TableName())
+	assert.Equal(s.T(), DestructiveFallbackToCleanDB, appDB.GetDestructiveFallbackPolicy())
+	assert.Equal(s.T(), s.Locker, appDB.locker)
+	assert.Equal(s.T(), s.Logger, appDB.log())
+}
+
+func (s *ConfigTestSuite) TestNewFromConfigWithNamespaceAndTableName() {
+	s.Config.Namespace = "billing"
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Empty(s.T(), errList)
+	assert.Equal(s.T(), "app_metadata", appDB.schemaMaster.TableName(), "Explicit table name takes precedence over the namespace")
+}
+
+func (s *ConfigTestSuite) TestNewFromConfigWithInvalidValues() {
+	appDB, errList := NewFromConfig(Config{SchemaMasterTableName: "app metadata"})
+
+	expectedErrors := []error{
+		fmt.Errorf("No entities provided for the database"),
+		fmt.Errorf("Need an ORM to work with"),
+		fmt.Errorf("Only non zero versions allowed"),
+		fmt.Errorf("Need an identity calculator"),
+		fmt.Errorf("Schema master table name %v is invalid. Only lower case letters, digits and underscores are allowed", "app metadata"),
+	}
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), expectedErrors, errList)
+}
+
+func (s *ConfigTestSuite) TestInitUsesConfiguredSchemaMasterLoggerAndCreateHook() {
+	var createdVersion orm.VersionNumber
+	s.Config.Hooks.OnCreate = func(version orm.VersionNumber) {
+		createdVersion = version
+	}
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(false)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).Return(nil)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), !shouldRetry && err == nil)
+	assert.Equal(s.T(), orm.VersionNumber(3), createdVersion)
+	assert.Equal(s.T(), []string{"[INFO] No Room Schema Master Detected in existing SQL DB. Creating now.."}, s.Logger.Messages)
+}
+
+func (s *ConfigTestSuite) TestInitWithMigrationHooks() {
+	var calls []string
+	s.Config.Hooks.BeforeMigration = func(from orm.VersionNumber, to orm.VersionNumber) error {
+		calls = append(calls, fmt.Sprintf("before %v=>%v", from, to))
+		return nil
+	}
+	s.Config.Hooks.AfterMigration = func(from orm.VersionNumber, to orm.VersionNumber, err error) {
+		calls = append(calls, fmt.Sprintf("after %v=>%v %v", from, to, err))
+	}
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	s.Config.Migrations = []orm.Migration{migration}
+	appDB, _ := NewFromConfig(s.Config)
+
+	migrationError := fmt.Errorf("Migration failed")
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.expectLatestRecord(appDB, "old", 2)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).Return(migrationError)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), shouldRetry && err == migrationError)
+	assert.Equal(s.T(), []string{"before 2=>3", "after 2=>3 Migration failed"}, calls)
+}
+
+func (s *ConfigTestSuite) TestInitWithMigrationVetoedByHook() {
+	hookError := fmt.Errorf("Battery too low for migration")
+	s.Config.Hooks.BeforeMigration = func(from orm.VersionNumber, to orm.VersionNumber) error {
+		return hookError
+	}
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	s.Config.Migrations = []orm.Migration{migration}
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.expectLatestRecord(appDB, "old", 2)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), !shouldRetry && err == hookError, "Vetoed migration must not lead to destruction")
+}
+
+func (s *ConfigTestSuite) TestPerformDBCleanUpVetoedByHook() {
+	hookError := fmt.Errorf("Data not yet uploaded")
+	s.Config.Hooks.BeforeCleanUp = func() error {
+		return hookError
+	}
+	appDB, _ := NewFromConfig(s.Config)
+
+	assert.Equal(s.T(), hookError, appDB.PerformDBCleanUp())
+}
+
+func (s *ConfigTestSuite) TestInitWithInstrumentation() {
+	instrumentation := mocks.NewMockInstrumentation(s.MockCtrl)
+	initRecorder := mocks.NewMockOperationRecorder(s.MockCtrl)
+	sanityRecorder := mocks.NewMockOperationRecorder(s.MockCtrl)
+	s.Config.Instrumentation = instrumentation
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.expectLatestRecord(appDB, "old", 3)
+	gomock.InOrder(
+		instrumentation.EXPECT().StartOperation(OperationInit, map[string]string{LabelToVersion: "3"}).Return(initRecorder),
+		initRecorder.EXPECT().SetLabel(LabelFromVersion, "3"),
+		initRecorder.EXPECT().SetLabel(LabelScenario, ScenarioSanityCheck),
+		instrumentation.EXPECT().StartOperation(OperationSanityCheck, map[string]string{LabelFromVersion: "3", LabelToVersion: "3"}).Return(sanityRecorder),
+		sanityRecorder.EXPECT().End(fmt.Errorf("Database signature mismatch. Version 3")),
+		initRecorder.EXPECT().End(fmt.Errorf("Database signature mismatch. Version 3")),
+	)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), shouldRetry && err != nil)
+}
+
+func (s *ConfigTestSuite) TestInitResultForMigration() {
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	s.Config.Migrations = []orm.Migration{migration}
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.expectLatestRecord(appDB, "old", 2)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).Return(nil)
+
+	_, err := appDB.Init("asasasa")
+	assert.Nil(s.T(), err)
+
+	result := appDB.GetLastResult()
+	assert.Equal(s.T(), ScenarioMigration, result.Scenario)
+	assert.Equal(s.T(), orm.VersionNumber(2), result.PreviousVersion)
+	assert.Equal(s.T(), orm.VersionNumber(3), result.Version)
+	assert.Equal(s.T(), []AppliedMigration{{From: 2, To: 3}}, result.MigrationsApplied)
+	assert.False(s.T(), result.Destroyed)
+}
+
+func (s *ConfigTestSuite) TestInitResultForCreateAndCleanUp() {
+	appDB, _ := NewFromConfig(s.Config)
+	runTransaction := func(fc func(orm.ORM) error) error {
+		return fc(s.DBA)
+	}
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(false)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(runTransaction)
+	s.DBA.EXPECT().CreateTable(appDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(false)
+	s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{})
+	s.DBA.EXPECT().Create(gomock.Any()).Return(orm.Result{})
+
+	_, err := appDB.Init("asasasa")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), &InitResult{Scenario: ScenarioCreate, Version: 3, TablesCreated: []string{"dummy_tables"}}, appDB.GetLastResult())
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(runTransaction)
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(true)
+	s.DBA.EXPECT().DropTable(DummyTable{}).Return(orm.Result{})
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.DBA.EXPECT().DropTable(appDB.schemaMaster).Return(orm.Result{})
+
+	assert.Nil(s.T(), appDB.PerformDBCleanUp())
+	result := appDB.GetLastResult()
+	assert.True(s.T(), result.Destroyed)
+	assert.Equal(s.T(), []string{"dummy_tables"}, result.TablesDropped, "Schema master is not among the dropped entity tables")
+}
+
+func (s *ConfigTestSuite) TestInitWithNewerCompatibleDB() {
+	s.Config.DestructiveFallback = NoDestructiveFallback
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true).Times(2)
+	gomock.InOrder(
+		s.expectLatestRecord(appDB, "newer", 5),
+		s.Loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{Version: 5, MinCompatibleVersion: 3}}).Return(orm.Result{}),
+		s.expectLatestRecord(appDB, "newer", 5),
+		s.Loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{Version: 5, MinCompatibleVersion: 4}}).Return(orm.Result{}),
+	)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), !shouldRetry && err == nil, "DB declaring this version compatible should be used as is")
+	assert.Equal(s.T(), &InitResult{Scenario: ScenarioNewerCompatible, PreviousVersion: 5, Version: 5}, appDB.GetLastResult())
+
+	shouldRetry, err = appDB.Init("asasasa")
+	assert.True(s.T(), shouldRetry && err != nil, "DB not declaring this version compatible needs a migration path")
+	assert.Equal(s.T(), orm.VersionNumber(5), appDB.GetLastResult().PreviousVersion, "Failed downgrade should still tell the version found")
+}
+
+func (s *ConfigTestSuite) TestNewFromConfigWithInvalidMinCompatibleVersion() {
+	s.Config.MinCompatibleVersion = 4
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), []error{fmt.Errorf("Minimum compatible version 4 can not be greater than version 3")}, errList)
+}
@@ -0,0 +1,122 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//GetCreationOrder Orders entities so that tables referenced by foreign keys are created before the tables referencing them.
+//Entities without a dependency between them keep their order in the list
+func GetCreationOrder(dba orm.ORM, entities []interface{}) ([]interface{}, error) {
+	tableNames := make(map[string]bool)
+	for _, entity := range entities {
+		tableNames[dba.GetModelDefinition(entity).TableName] = true
+	}
+
+	created := make(map[string]bool)
+	ordered := make([]interface{}, 0, len(entities))
+	pending := entities
+	for len(pending) > 0 {
+		var remaining []interface{}
+		for _, entity := range pending {
+			model := dba.GetModelDefinition(entity)
+			if hasPendingReferences(model, tableNames, created) {
+				remaining = append(remaining, entity)
+				continue
+			}
+			created[model.TableName] = true
+			ordered = append(ordered, entity)
+		}
+
+		if len(remaining) == len(pending) {
+			var cyclicTables []string
+			for _, entity := range remaining {
+				cyclicTables = append(cyclicTables, dba.GetModelDefinition(entity).TableName)
+			}
+			return nil, fmt.Errorf("Tables %v have cyclic foreign keys", cyclicTables)
+		}
+		pending = remaining
+	}
+
+	return ordered, nil
+}
+
+func hasPendingReferences(model orm.ModelDefinition, tableNames map[string]bool, created map[string]bool) bool {
+	for _, foreignKey := range model.ForeignKeys {
+		referenced := foreignKey.ReferencedTable
+		if referenced != model.TableName && tableNames[referenced] && !created[referenced] {
+			return true
+		}
+	}
+	return false
+}
+
+//createIndexes Creates indexes declared by an entity which its table does not have already. Only for ORMs whose CreateTable
+//creates just the columns. The GORM adapter creates indexes along with the table hence is not a ConstraintCreator
+func (appDB *Room) createIndexes(dba orm.ORM, creator orm.ConstraintCreator, entity interface{}) error {
+	for _, index := range dba.GetModelDefinition(entity).Indexes {
+		if creator.HasIndex(entity, index.Name) {
+			continue
+		}
+		if err := creator.CreateIndex(entity, index).Error; err != nil {
+			appDB.log().Errorf("Error while creating index %v of entity %T. %v", index.Name, entity, err)
+			return err
+		}
+	}
+	return nil
+}
+
+//addForeignKeys Adds foreign keys declared by an entity. Called once all the tables referenced could be created
+func (appDB *Room) addForeignKeys(dba orm.ORM, creator orm.ConstraintCreator, entity interface{}) error {
+	for _, foreignKey := range dba.GetModelDefinition(entity).ForeignKeys {
+		if err := creator.AddForeignKey(entity, foreignKey).Error; err != nil {
+			appDB.log().Errorf("Error while adding foreign key %v of entity %T to %v. %v", foreignKey.Columns, entity, foreignKey.ReferencedTable, err)
+			return err
+		}
+	}
+	return nil
+}
+
+//createEntityTables Creates tables of entities in the order of their foreign keys along with their indexes and constraints
+//if the ORM is a ConstraintCreator. Tables that exist already are passed to skipped and left untouched
+func (appDB *Room) createEntityTables(dba orm.ORM, entities []interface{}, recordCreated func(entity interface{}), skipped func(entity interface{})) error {
+	ordered, err := GetCreationOrder(dba, entities)
+	if err != nil {
+		return err
+	}
+	creator, canCreateConstraints := dba.(orm.ConstraintCreator)
+
+	var created []interface{}
+	for _, entity := range ordered {
+		if dba.HasTable(entity) {
+			if skipped != nil {
+				skipped(entity)
+			}
+			continue
+		}
+
+		if err := dba.CreateTable(entity).Error; err != nil {
+			return err
+		}
+		if canCreateConstraints {
+			if err := appDB.createIndexes(dba, creator, entity); err != nil {
+				return err
+			}
+		}
+		created = append(created, entity)
+		if recordCreated != nil {
+			recordCreated(entity)
+		}
+	}
+
+	for _, entity := range created {
+		if !canCreateConstraints {
+			break
+		}
+		if err := appDB.addForeignKeys(dba, creator, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
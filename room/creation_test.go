@@ -0,0 +1,141 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConstrainableORM struct {
+	*mocks.MockORM
+	*mocks.MockConstraintCreator
+}
+
+type CreationTestSuite struct {
+	suite.Suite
+	MockCtrl   *gomock.Controller
+	DBA        *mocks.MockORM
+	Creator    *mocks.MockConstraintCreator
+	ORM        orm.ORM
+	AppDB      *Room
+	ForeignKey orm.ForeignKeyDefinition
+	Index      orm.IndexDefinition
+}
+
+func (s *CreationTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Creator = mocks.NewMockConstraintCreator(s.MockCtrl)
+	s.ORM = &ConstrainableORM{s.DBA, s.Creator}
+	s.AppDB = &Room{
+		entities: []interface{}{DummyTable{}, AnotherDummyTable{}},
+		version:  2,
+		dba:      s.ORM,
+		logger:   &RecordingLogger{},
+	}
+	s.ForeignKey = orm.ForeignKeyDefinition{Columns: []string{"another_id"}, ReferencedTable: "another_dummy_tables", ReferencedColumns: []string{"id"}}
+	s.Index = orm.IndexDefinition{Name: "idx_dummy_tables_another_id", Columns: []string{"another_id"}}
+}
+
+func (s *CreationTestSuite) expectModelDefinitions(dummyForeignKeys []orm.ForeignKeyDefinition, anotherForeignKeys []orm.ForeignKeyDefinition) {
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName: "dummy_tables", Indexes: []orm.IndexDefinition{s.Index}, ForeignKeys: dummyForeignKeys,
+	}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{
+		TableName: "another_dummy_tables", ForeignKeys: anotherForeignKeys,
+	}).AnyTimes()
+}
+
+func (s *CreationTestSuite) TestReferencedTablesAreCreatedFirst() {
+	s.expectModelDefinitions([]orm.ForeignKeyDefinition{s.ForeignKey}, nil)
+	gomock.InOrder(
+		s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(AnotherDummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.Creator.EXPECT().HasIndex(DummyTable{}, s.Index.Name).Return(false),
+		s.Creator.EXPECT().CreateIndex(DummyTable{}, s.Index).Return(orm.Result{}),
+		s.Creator.EXPECT().AddForeignKey(DummyTable{}, s.ForeignKey).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomSchemaMaster{Version: 2, IdentityHash: "identity"}).Return(orm.Result{}),
+	)
+
+	var created []interface{}
+	err := s.AppDB.getFirstTimeDBCreationFunction("identity", nil, func(entity interface{}) {
+		created = append(created, entity)
+	})(s.ORM)
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []interface{}{AnotherDummyTable{}, DummyTable{}}, created)
+}
+
+func (s *CreationTestSuite) TestExistingIndexesAndTablesAreLeftAlone() {
+	s.expectModelDefinitions([]orm.ForeignKeyDefinition{s.ForeignKey}, nil)
+	gomock.InOrder(
+		s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(true),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.Creator.EXPECT().HasIndex(DummyTable{}, s.Index.Name).Return(true),
+		s.Creator.EXPECT().AddForeignKey(DummyTable{}, s.ForeignKey).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(gomock.Any()).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.getFirstTimeDBCreationFunction("identity", nil, nil)(s.ORM))
+}
+
+func (s *CreationTestSuite) TestConstraintsNeedConstraintCreator() {
+	s.expectModelDefinitions([]orm.ForeignKeyDefinition{s.ForeignKey}, nil)
+	gomock.InOrder(
+		s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(AnotherDummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(gomock.Any()).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.getFirstTimeDBCreationFunction("identity", nil, nil)(s.DBA), "Tables should be created in order by CreateTable alone")
+}
+
+func (s *CreationTestSuite) TestFailingForeignKey() {
+	s.expectModelDefinitions([]orm.ForeignKeyDefinition{s.ForeignKey}, nil)
+	expectedError := fmt.Errorf("DB mess in adding foreign key")
+	gomock.InOrder(
+		s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(AnotherDummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.Creator.EXPECT().HasIndex(DummyTable{}, s.Index.Name).Return(false),
+		s.Creator.EXPECT().CreateIndex(DummyTable{}, s.Index).Return(orm.Result{}),
+		s.Creator.EXPECT().AddForeignKey(DummyTable{}, s.ForeignKey).Return(orm.Result{Error: expectedError}),
+	)
+
+	assert.Equal(s.T(), expectedError, s.AppDB.getFirstTimeDBCreationFunction("identity", nil, nil)(s.ORM))
+}
+
+func (s *CreationTestSuite) TestCyclicForeignKeys() {
+	s.expectModelDefinitions(
+		[]orm.ForeignKeyDefinition{s.ForeignKey},
+		[]orm.ForeignKeyDefinition{{Columns: []string{"dummy_id"}, ReferencedTable: "dummy_tables", ReferencedColumns: []string{"id"}}},
+	)
+	s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{})
+
+	assert.Equal(s.T(), fmt.Errorf("Tables [dummy_tables another_dummy_tables] have cyclic foreign keys"),
+		s.AppDB.getFirstTimeDBCreationFunction("identity", nil, nil)(s.ORM))
+}
+
+func (s *CreationTestSuite) TestSelfReferencingForeignKey() {
+	selfReference := orm.ForeignKeyDefinition{Columns: []string{"parent_id"}, ReferencedTable: "dummy_tables", ReferencedColumns: []string{"id"}}
+	s.expectModelDefinitions([]orm.ForeignKeyDefinition{selfReference}, nil)
+
+	ordered, err := GetCreationOrder(s.DBA, s.AppDB.entities)
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []interface{}{DummyTable{}, AnotherDummyTable{}}, ordered)
+}
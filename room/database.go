@@ -2,36 +2,73 @@ package room
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
-	"github.com/adonmo/goroom/logger"
 	"github.com/adonmo/goroom/orm"
 )
 
-func getFirstTimeDBCreationFunction(identityHash string, version orm.VersionNumber, entitiesToCreate []interface{}) func(orm.ORM) error {
+//performFirstTimeCreation Creates Schema Master and entity tables which are not there already
+func (appDB *Room) performFirstTimeCreation(currentIdentityHash string) (shouldRetryAfterDestruction bool, err error) {
+	appDB.log().Infof("No Room Schema Master Detected in existing SQL DB. Creating now..")
+	appDB.lastResult.Scenario = ScenarioCreate
+
+	entityHashes, err := appDB.getEntityHashes()
+	if err != nil {
+		return false, err
+	}
+
+	var createdEntities []interface{}
+	dbCreationFunc := appDB.getFirstTimeDBCreationFunction(currentIdentityHash, entityHashes, func(entity interface{}) {
+		createdEntities = append(createdEntities, entity)
+	})
+	err = appDB.doInTransaction(dbCreationFunc)
+	if err != nil {
+		appDB.log().Errorf("Unable to Initialize Room. Unexpected Error. %v", err)
+		return true, err
+	}
+
+	appDB.lastResult.Version = appDB.version
+	appDB.lastResult.TablesCreated = appDB.getTableNames(createdEntities)
+	if appDB.hooks.OnCreate != nil {
+		appDB.hooks.OnCreate(appDB.version)
+	}
+	return false, nil
+}
+
+func (appDB *Room) getFirstTimeDBCreationFunction(identityHash string, entityHashes map[string]string, recordCreated func(entity interface{})) func(orm.ORM) error {
 
 	return func(dba orm.ORM) error {
 
 		//Explicit Create without existence check. This ensures failure if this is not really a first time DB Creation
-		if err := dba.CreateTable(GoRoomSchemaMaster{}).Error; err != nil {
+		if err := dba.CreateTable(appDB.schemaMaster).Error; err != nil {
 			return err
 		}
 
-		for _, entity := range entitiesToCreate {
-			if !dba.HasTable(entity) {
-				if err := dba.CreateTable(entity).Error; err != nil {
-					return err
-				}
+		err := appDB.createEntityTables(dba, appDB.entities, recordCreated, func(entity interface{}) {
+			if appDB.missingSchemaMaster == StampCurrentVersion {
+				appDB.log().Warnf("Table of entity %T already exists. Assuming it is at version %v", entity, appDB.version)
 			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := appDB.claimEntityTables(dba, nil); err != nil {
+			return err
 		}
 
-		metadata := GoRoomSchemaMaster{
-			Version:      version,
-			IdentityHash: identityHash,
+		for _, seed := range appDB.seeds {
+			if err := seed(dba.GetUnderlyingORM()); err != nil {
+				appDB.log().Errorf("Error while seeding the DB. %v", err)
+				return err
+			}
 		}
 
+		metadata := appDB.sign(appDB.schemaMaster.withRecord(appDB.version, identityHash).withEntityHashes(entityHashes).withMinCompatibleVersion(appDB.minCompatibleVersion))
 		dbExec := dba.Create(&metadata)
 		if dbExec.Error != nil {
-			logger.Errorf("Error while adding entity hash to Room Schema Master. %v", dbExec.Error)
+			appDB.log().Errorf("Error while adding entity hash to Room Schema Master. %v", dbExec.Error)
 			return dbExec.Error
 		}
 
@@ -41,6 +78,10 @@ func getFirstTimeDBCreationFunction(identityHash string, version orm.VersionNumb
 
 //GetDBCleanUpFunction Gives you a function that dictates the DB clean up transaction
 func GetDBCleanUpFunction(entities []interface{}) func(orm.ORM) error {
+	return getDBCleanUpFunction(entities, nil)
+}
+
+func getDBCleanUpFunction(entities []interface{}, recordDropped func(entity interface{})) func(orm.ORM) error {
 
 	return func(dba orm.ORM) error {
 		for _, entity := range entities {
@@ -48,6 +89,9 @@ func GetDBCleanUpFunction(entities []interface{}) func(orm.ORM) error {
 				if err := dba.DropTable(entity).Error; err != nil {
 					return err
 				}
+				if recordDropped != nil {
+					recordDropped(entity)
+				}
 			}
 		}
 
@@ -55,15 +99,219 @@ func GetDBCleanUpFunction(entities []interface{}) func(orm.ORM) error {
 	}
 }
 
-//PerformDBCleanUp Cleans up existing DB removing Room metadata and all known entities
-func (appDB *Room) PerformDBCleanUp() error {
-	dbCleanUpFunc := GetDBCleanUpFunction(append(appDB.entities, GoRoomSchemaMaster{}))
-	return appDB.dba.DoInTransaction(dbCleanUpFunc)
+//getAuxiliaryTablesCleanUpFunction Drops the tables kept by any of the migrations e.g. cursors of batched migrations interrupted before
+//the clean up. The clean up brings the DB to the version of the Room hence none of them is resumed
+func (appDB *Room) getAuxiliaryTablesCleanUpFunction() func(orm.ORM) error {
+	var auxiliaryTables []interface{}
+	for _, tableName := range getAuxiliaryTables(appDB.migrations) {
+		auxiliaryTables = append(auxiliaryTables, tableName)
+	}
+	return GetDBCleanUpFunction(auxiliaryTables)
+}
+
+//PerformDBCleanUp Cleans up existing DB removing Room metadata and all known entities of the namespace
+func (appDB *Room) PerformDBCleanUp() (err error) {
+	start := time.Now()
+	appDB.lastResult = &InitResult{}
+	defer func() {
+		appDB.lastResult.Timings.CleanUp = time.Since(start)
+	}()
+
+	operation := appDB.startOperation(OperationCleanUp, map[string]string{
+		LabelToVersion:   versionLabel(appDB.version),
+		LabelCleanUpMode: CleanUpModeFull,
+	})
+	defer func() {
+		operation.End(err)
+	}()
+
+	if appDB.readOnly {
+		return fmt.Errorf("Room opened read only. Clean up of the DB is not allowed")
+	}
+
+	if err = appDB.acquireLock(); err != nil {
+		return err
+	}
+	defer appDB.releaseLock()
+
+	//Selective fallback never escalates to a full clean up as that would drop the data it is meant to keep
+	var resetTables []string
+	if appDB.destructiveFallback == SelectiveDestructiveFallback {
+		if resetTables, err = appDB.getTablesToReset(); err != nil {
+			appDB.log().Errorf("Unable to determine tables to reset. DB is left as is. %v", err)
+			return fmt.Errorf("Selective destructive fallback is unable to determine the tables to reset. %v", err)
+		}
+	}
+
+	if appDB.hooks.BeforeCleanUp != nil {
+		if err = appDB.hooks.BeforeCleanUp(); err != nil {
+			appDB.log().Warnf("DB clean up aborted by hook. %v", err)
+			return err
+		}
+	}
+
+	if appDB.destructiveFallback == SelectiveDestructiveFallback {
+		operation.SetLabel(LabelCleanUpMode, CleanUpModeSelective)
+		return appDB.performSelectiveReset(resetTables)
+	}
+
+	var droppedEntities []interface{}
+	dbCleanUpFunc := getDBCleanUpFunction(append(appDB.entities, appDB.schemaMaster), func(entity interface{}) {
+		if _, isSchemaMaster := entity.(GoRoomSchemaMaster); !isSchemaMaster {
+			droppedEntities = append(droppedEntities, entity)
+		}
+	})
+	err = appDB.doInTransaction(func(dba orm.ORM) error {
+		if err := dbCleanUpFunc(dba); err != nil {
+			return err
+		}
+		if err := appDB.getAuxiliaryTablesCleanUpFunction()(dba); err != nil {
+			return err
+		}
+		return appDB.releaseEntityTables(dba)
+	})
+	if err != nil {
+		return err
+	}
+
+	appDB.lastResult.Destroyed = true
+	appDB.lastResult.TablesDropped = appDB.getTableNames(droppedEntities)
+	return nil
+}
+
+//GetLastResetTables Tables reset by the last selective destructive fallback of this Room
+func (appDB *Room) GetLastResetTables() []string {
+	return appDB.lastResetTables
 }
 
-func (appDB *Room) peformDatabaseSanityChecks(currentIdentityHash string, roomMetadata *GoRoomSchemaMaster) error {
+//getTablesToReset Tables whose identity hash differs from the one recorded in Schema Master along with the tables declared by
+//every migration on the path from the recorded version. Data only migrations change tables without changing their hash, so the
+//reset is refused if the path is missing or any of its migrations does not declare the tables it affects
+func (appDB *Room) getTablesToReset() ([]string, error) {
+	if !appDB.isSchemaMasterPresent() {
+		return nil, fmt.Errorf("Room Schema Master not found")
+	}
+
+	record, err := appDB.getLatestRoomRecordFromDB()
+	if err != nil {
+		return nil, err
+	}
+
+	storedHashes, err := record.GetEntityHashes()
+	if err != nil {
+		return nil, err
+	}
+	if len(storedHashes) < 1 {
+		return nil, fmt.Errorf("No entity hashes recorded for version %v", record.Version)
+	}
+
+	currentHashes, err := appDB.getEntityHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	resetSet := make(map[string]bool)
+	for tableName, hash := range currentHashes {
+		if storedHashes[tableName] != hash {
+			resetSet[tableName] = true
+		}
+	}
+
+	pendingMigrations, err := GetApplicableMigrations(appDB.migrations, record.Version, appDB.version)
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range pendingMigrations {
+		scopedMigration, ok := migration.(orm.TableScopedMigration)
+		if !ok {
+			return nil, fmt.Errorf("Migration from %v to %v does not declare the tables it affects", migration.GetBaseVersion(), migration.GetTargetVersion())
+		}
+		for _, tableName := range scopedMigration.GetAffectedTables() {
+			if _, known := currentHashes[tableName]; !known {
+				return nil, fmt.Errorf("Table %v affected by migration from %v to %v is not a known entity", tableName, migration.GetBaseVersion(), migration.GetTargetVersion())
+			}
+			resetSet[tableName] = true
+		}
+	}
+
+	resetTables := make([]string, 0, len(resetSet))
+	for tableName := range resetSet {
+		resetTables = append(resetTables, tableName)
+	}
+	sort.Strings(resetTables)
+
+	return resetTables, nil
+}
+
+func (appDB *Room) performSelectiveReset(resetTables []string) error {
+	identityHash, err := appDB.CalculateIdentityHash()
+	if err != nil {
+		return err
+	}
+	entityHashes, err := appDB.getEntityHashes()
+	if err != nil {
+		return err
+	}
+
+	entitiesByTableName := appDB.getEntitiesByTableName()
+	var entities []interface{}
+	for _, tableName := range resetTables {
+		entities = append(entities, entitiesByTableName[tableName])
+	}
+
+	err = appDB.doInTransaction(func(dba orm.ORM) error {
+		if err := GetDBCleanUpFunction(entities)(dba); err != nil {
+			return err
+		}
+
+		if err := appDB.createEntityTables(dba, entities, nil, nil); err != nil {
+			return err
+		}
+
+		if err := appDB.getAuxiliaryTablesCleanUpFunction()(dba); err != nil {
+			return err
+		}
+		if err := appDB.releaseTables(dba, getAuxiliaryTables(appDB.migrations)); err != nil {
+			return err
+		}
+
+		if err := autoMigrate(dba, appDB.schemaMaster); err != nil {
+			return err
+		}
+		if dbExec := dba.TruncateTable(appDB.schemaMaster); dbExec.Error != nil {
+			return dbExec.Error
+		}
+
+		metadata := appDB.schemaMaster.withRecord(appDB.version, identityHash).withEntityHashes(entityHashes).withMinCompatibleVersion(appDB.minCompatibleVersion)
+		metadata.ResetTables = encodeJSONColumn(resetTables)
+		metadata = appDB.sign(metadata)
+		return dba.Create(&metadata).Error
+	})
+	if err != nil {
+		appDB.log().Errorf("Selective reset of tables %v failed. %v", resetTables, err)
+		return err
+	}
+
+	appDB.lastResetTables = resetTables
+	appDB.lastResult.Version = appDB.version
+	appDB.lastResult.Destroyed = true
+	appDB.lastResult.TablesDropped = resetTables
+	appDB.lastResult.TablesCreated = resetTables
+	appDB.log().Warnf("Selective destructive fallback reset tables %v to reach version %v", resetTables, appDB.version)
+	return nil
+}
+
+func (appDB *Room) peformDatabaseSanityChecks(currentIdentityHash string, roomMetadata *GoRoomSchemaMaster) (err error) {
+	operation := appDB.startOperation(OperationSanityCheck, map[string]string{
+		LabelFromVersion: versionLabel(roomMetadata.Version),
+		LabelToVersion:   versionLabel(appDB.version),
+	})
+	defer func() {
+		operation.End(err)
+	}()
+
 	if currentIdentityHash != roomMetadata.IdentityHash {
-		logger.Error("Database Hash does not match. Looks like you changed entity definitions but forgot to upgrade version.")
+		appDB.log().Errorf("Database Hash does not match. Looks like you changed entity definitions but forgot to upgrade version.")
 		return fmt.Errorf("Database signature mismatch. Version %v", appDB.version)
 	}
 
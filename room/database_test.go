@@ -14,11 +14,22 @@ type DatabaseOperationsTestSuite struct {
 	suite.Suite
 	MockCtrl *gomock.Controller
 	DBA      *mocks.MockORM
+	Loader   *mocks.MockRowLoader
+	Migrator *mocks.MockAutoMigrator
+	ORM      *RowLoadingORM
 }
 
 func (s *DatabaseOperationsTestSuite) SetupTest() {
 	s.MockCtrl = gomock.NewController(s.T())
-	s.DBA = mocks.NewMockORM(s.MockCtrl)
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.Migrator = mocks.NewMockAutoMigrator(s.MockCtrl)
+	s.ORM = &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader, MockAutoMigrator: s.Migrator}
+}
+
+func (s *DatabaseOperationsTestSuite) expectModelDefinitions() {
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{TableName: "dummy_tables"}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{TableName: "another_dummy_tables"}).AnyTimes()
 }
 
 func (s *DatabaseOperationsTestSuite) TestGetFirstTimeDBCreationFunction() {
@@ -27,7 +38,8 @@ func (s *DatabaseOperationsTestSuite) TestGetFirstTimeDBCreationFunction() {
 	version := orm.VersionNumber(4)
 	entitiesToCreate := []interface{}{DummyTable{}, AnotherDummyTable{}}
 
-	creationFunc := getFirstTimeDBCreationFunction(identityHash, version, entitiesToCreate)
+	creationFunc := (&Room{version: version, entities: entitiesToCreate}).getFirstTimeDBCreationFunction(identityHash, nil, nil)
+	s.expectModelDefinitions()
 
 	gomock.InOrder(
 		s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{
@@ -55,7 +67,8 @@ func (s *DatabaseOperationsTestSuite) TestGetFirstTimeDBCreationFunctionWithErro
 	version := orm.VersionNumber(4)
 	entitiesToCreate := []interface{}{DummyTable{}, AnotherDummyTable{}}
 
-	creationFunc := getFirstTimeDBCreationFunction(identityHash, version, entitiesToCreate)
+	creationFunc := (&Room{version: version, entities: entitiesToCreate}).getFirstTimeDBCreationFunction(identityHash, nil, nil)
+	s.expectModelDefinitions()
 
 	expectedError := fmt.Errorf("DB mess in creating table")
 
@@ -88,7 +101,8 @@ func (s *DatabaseOperationsTestSuite) TestGetFirstTimeDBCreationFunctionWithErro
 	version := orm.VersionNumber(4)
 	entitiesToCreate := []interface{}{DummyTable{}, AnotherDummyTable{}}
 
-	creationFunc := getFirstTimeDBCreationFunction(identityHash, version, entitiesToCreate)
+	creationFunc := (&Room{version: version, entities: entitiesToCreate}).getFirstTimeDBCreationFunction(identityHash, nil, nil)
+	s.expectModelDefinitions()
 
 	expectedError := fmt.Errorf("DB mess in creating entry")
 
@@ -118,7 +132,7 @@ func (s *DatabaseOperationsTestSuite) TestGetFirstTimeDBCreationFunctionWithErro
 	version := orm.VersionNumber(4)
 	entitiesToCreate := []interface{}{DummyTable{}, AnotherDummyTable{}}
 
-	creationFunc := getFirstTimeDBCreationFunction(identityHash, version, entitiesToCreate)
+	creationFunc := (&Room{version: version, entities: entitiesToCreate}).getFirstTimeDBCreationFunction(identityHash, nil, nil)
 
 	expectedError := fmt.Errorf("DB mess in creating schema master")
 
@@ -198,3 +212,208 @@ func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithErrorInTransaction
 	}
 	assert.Equal(s.T(), expectedError, room.PerformDBCleanUp(), "Unexpected error output when transaction fails during DB deletion")
 }
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithLock() {
+
+	locker := mocks.NewMockLocker(s.MockCtrl)
+	room := &Room{
+		dba:    s.DBA,
+		locker: locker,
+	}
+
+	gomock.InOrder(
+		locker.EXPECT().Lock().Return(nil),
+		s.DBA.EXPECT().DoInTransaction(gomock.Any()).Return(nil),
+		locker.EXPECT().Unlock().Return(nil),
+	)
+	assert.Nil(s.T(), room.PerformDBCleanUp(), "No Error expected when DB cleanup goes in successfully under lock")
+
+	expectedError := fmt.Errorf("Timed out waiting for lock")
+	locker.EXPECT().Lock().Return(expectedError)
+	assert.Equal(s.T(), expectedError, room.PerformDBCleanUp(), "Lock error expected and no cleanup attempted")
+}
+
+func (s *DatabaseOperationsTestSuite) getAuxiliaryTableMigration(baseVersion orm.VersionNumber, targetVersion orm.VersionNumber, tables ...string) orm.Migration {
+	migration := mocks.NewMockAuxiliaryTableMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(baseVersion).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(targetVersion).AnyTimes()
+	migration.EXPECT().GetAuxiliaryTables().Return(tables).AnyTimes()
+	return migration
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupDropsAuxiliaryTables() {
+
+	room := &Room{
+		dba:        s.DBA,
+		migrations: []orm.Migration{s.getAuxiliaryTableMigration(1, 2, "go_room_batch_cursors")},
+	}
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.DBA)
+	})
+	gomock.InOrder(
+		s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(true),
+		s.DBA.EXPECT().DropTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable("go_room_batch_cursors").Return(true),
+		s.DBA.EXPECT().DropTable("go_room_batch_cursors").Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), room.PerformDBCleanUp())
+	assert.Empty(s.T(), room.GetLastResult().TablesDropped, "Only entity tables are reported")
+}
+
+func (s *DatabaseOperationsTestSuite) getRoomWithSelectiveFallback(storedEntityHashes string) *Room {
+
+	identityCalc := mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	room := &Room{
+		entities:            []interface{}{DummyTable{}, AnotherDummyTable{}},
+		version:             orm.VersionNumber(4),
+		dba:                 s.ORM,
+		identityCalculator:  identityCalc,
+		destructiveFallback: SelectiveDestructiveFallback,
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{TableName: "dummy_tables", EntityModel: "dummyModel"}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{TableName: "another_dummy_tables", EntityModel: "anotherModel"}).AnyTimes()
+	identityCalc.EXPECT().ConstructHash("dummyModel").Return("d2", nil).AnyTimes()
+	identityCalc.EXPECT().ConstructHash("anotherModel").Return("a1", nil).AnyTimes()
+	identityCalc.EXPECT().ConstructHash([]string{"a1", "d2"}).Return("identity", nil).AnyTimes()
+
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(true)
+	s.Loader.EXPECT().FindAll(GoRoomSchemaMaster{}, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{
+		{Version: 2, IdentityHash: "older"},
+		{Version: 3, IdentityHash: "old", EntityHashes: storedEntityHashes},
+	}).Return(orm.Result{})
+
+	return room
+}
+
+func (s *DatabaseOperationsTestSuite) getScopedMigration(baseVersion orm.VersionNumber, targetVersion orm.VersionNumber, tables ...string) orm.Migration {
+	migration := mocks.NewMockTableScopedMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(baseVersion).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(targetVersion).AnyTimes()
+	migration.EXPECT().GetAffectedTables().Return(tables).AnyTimes()
+	return migration
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallback() {
+
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d1"}`)
+	room.migrations = []orm.Migration{s.getScopedMigration(3, 4)}
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	gomock.InOrder(
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(true),
+		s.DBA.EXPECT().DropTable(DummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomSchemaMaster{
+			Version:      4,
+			IdentityHash: "identity",
+			EntityHashes: `{"another_dummy_tables":"a1","dummy_tables":"d2"}`,
+			ResetTables:  `["dummy_tables"]`,
+		}).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), room.PerformDBCleanUp(), "No Error expected when selective reset goes in successfully")
+	assert.Equal(s.T(), []string{"dummy_tables"}, room.GetLastResetTables())
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackDropsAuxiliaryTables() {
+
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d1"}`)
+	room.migrations = []orm.Migration{s.getScopedMigration(3, 4), s.getAuxiliaryTableMigration(1, 2, "go_room_batch_cursors")}
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	gomock.InOrder(
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(true),
+		s.DBA.EXPECT().DropTable(DummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable("go_room_batch_cursors").Return(true),
+		s.DBA.EXPECT().DropTable("go_room_batch_cursors").Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(true),
+		s.Loader.EXPECT().FindAll(GoRoomNamespaceTable{}, gomock.Any()).SetArg(1, []GoRoomNamespaceTable{
+			{EntityTable: "dummy_tables"}, {EntityTable: "go_room_batch_cursors"},
+		}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(true),
+		s.Loader.EXPECT().FindAll(GoRoomNamespaceTable{}, gomock.Any()).SetArg(1, []GoRoomNamespaceTable{
+			{EntityTable: "dummy_tables"}, {EntityTable: "go_room_batch_cursors"},
+		}).Return(orm.Result{}),
+		s.DBA.EXPECT().TruncateTable(GoRoomNamespaceTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables"}).Return(orm.Result{}),
+		s.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomSchemaMaster{
+			Version:      4,
+			IdentityHash: "identity",
+			EntityHashes: `{"another_dummy_tables":"a1","dummy_tables":"d2"}`,
+			ResetTables:  `["dummy_tables"]`,
+		}).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), room.PerformDBCleanUp())
+	assert.Equal(s.T(), []string{"dummy_tables"}, room.GetLastResetTables())
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackResetsTablesOfMigrations() {
+
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d2"}`)
+	room.migrations = []orm.Migration{s.getScopedMigration(3, 4, "another_dummy_tables")}
+
+	resetTables, err := room.getTablesToReset()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"another_dummy_tables"}, resetTables)
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackAfterDataOnlyHop() {
+
+	//Hashes are unchanged, yet the data only hop from 3 to 4 changed dummy_tables before the hop to 5 failed
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d2"}`)
+	room.version = 5
+	room.migrations = []orm.Migration{s.getScopedMigration(3, 4, "dummy_tables"), s.getScopedMigration(4, 5, "another_dummy_tables")}
+
+	resetTables, err := room.getTablesToReset()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"another_dummy_tables", "dummy_tables"}, resetTables)
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackWithoutMigrationPath() {
+
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d1"}`)
+
+	expectedError := fmt.Errorf("Selective destructive fallback is unable to determine the tables to reset. Unable to generate path for migration from 3 to 4")
+	assert.Equal(s.T(), expectedError, room.PerformDBCleanUp())
+	assert.Empty(s.T(), room.GetLastResetTables())
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackWithoutEntityHashes() {
+
+	room := s.getRoomWithSelectiveFallback("")
+
+	expectedError := fmt.Errorf("Selective destructive fallback is unable to determine the tables to reset. No entity hashes recorded for version 3")
+	assert.Equal(s.T(), expectedError, room.PerformDBCleanUp(), "Records without entity hashes must not lead to full clean up")
+	assert.Empty(s.T(), room.GetLastResetTables())
+}
+
+func (s *DatabaseOperationsTestSuite) TestPerformDBCleanupWithSelectiveFallbackAfterUndeclaredMigration() {
+
+	room := s.getRoomWithSelectiveFallback(`{"another_dummy_tables":"a1","dummy_tables":"d2"}`)
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(4)).AnyTimes()
+	room.migrations = []orm.Migration{migration}
+	room.hooks.BeforeCleanUp = func() error {
+		s.Fail("Hook must not be called when the DB is left as is")
+		return nil
+	}
+
+	expectedError := fmt.Errorf("Selective destructive fallback is unable to determine the tables to reset. Migration from 3 to 4 does not declare the tables it affects")
+	assert.Equal(s.T(), expectedError, room.PerformDBCleanUp())
+}
@@ -3,11 +3,30 @@ package room
 import (
 	"fmt"
 	"sort"
+
+	"github.com/adonmo/goroom/orm"
 )
 
 //CalculateIdentityHash Calculate the identity hash for current Room instance
-func (appDB *Room) CalculateIdentityHash() (string, error) {
-	var entityHashArr []string
+func (appDB *Room) CalculateIdentityHash() (identityHash string, err error) {
+	operation := appDB.startOperation(OperationCalculateIdentityHash, map[string]string{
+		LabelToVersion: versionLabel(appDB.version),
+	})
+	defer func() {
+		operation.End(err)
+	}()
+
+	models := appDB.getSortedModelDefinitions()
+
+	entityHashArr, err := appDB.calculateEntityHashes(models)
+	if err != nil {
+		return "", err
+	}
+
+	return appDB.calculateIdentityHashFromEntityHashes(entityHashArr)
+}
+
+func (appDB *Room) getSortedModelDefinitions() []orm.ModelDefinition {
 	sortedEntities := make([]interface{}, len(appDB.entities))
 	copy(sortedEntities, appDB.entities)
 
@@ -18,15 +37,28 @@ func (appDB *Room) CalculateIdentityHash() (string, error) {
 		return modelA.TableName < modelB.TableName
 	})
 
+	models := make([]orm.ModelDefinition, 0, len(sortedEntities))
 	for _, entity := range sortedEntities {
-		model := appDB.dba.GetModelDefinition(entity)
+		models = append(models, appDB.dba.GetModelDefinition(entity))
+	}
+
+	return models
+}
+
+func (appDB *Room) calculateEntityHashes(models []orm.ModelDefinition) ([]string, error) {
+	var entityHashArr []string
+	for _, model := range models {
 		sum, err := appDB.identityCalculator.ConstructHash(model.EntityModel)
 		if err != nil {
-			return "", fmt.Errorf("Error while calculating identity hash for Table %v", model.TableName)
+			return nil, fmt.Errorf("Error while calculating identity hash for Table %v", model.TableName)
 		}
 		entityHashArr = append(entityHashArr, sum)
 	}
 
+	return entityHashArr, nil
+}
+
+func (appDB *Room) calculateIdentityHashFromEntityHashes(entityHashArr []string) (string, error) {
 	identity, err := appDB.identityCalculator.ConstructHash(entityHashArr)
 	if err != nil {
 		return "", fmt.Errorf("Error while calculating schema identity %v", entityHashArr)
@@ -34,3 +66,28 @@ func (appDB *Room) CalculateIdentityHash() (string, error) {
 
 	return identity, nil
 }
+
+//getEntityHashes Identity hash of each entity keyed by its table name
+func (appDB *Room) getEntityHashes() (map[string]string, error) {
+	models := appDB.getSortedModelDefinitions()
+	entityHashArr, err := appDB.calculateEntityHashes(models)
+	if err != nil {
+		return nil, err
+	}
+
+	entityHashes := make(map[string]string)
+	for i, model := range models {
+		entityHashes[model.TableName] = entityHashArr[i]
+	}
+
+	return entityHashes, nil
+}
+
+//getEntitiesByTableName Entities of this Room keyed by their table name
+func (appDB *Room) getEntitiesByTableName() map[string]interface{} {
+	entities := make(map[string]interface{})
+	for _, entity := range appDB.entities {
+		entities[appDB.dba.GetModelDefinition(entity).TableName] = entity
+	}
+	return entities
+}
@@ -26,7 +26,7 @@ type EntityTestSuite struct {
 
 func (s *EntityTestSuite) SetupTest() {
 	s.MockCtrl = gomock.NewController(s.T())
-	s.DBA = mocks.NewMockORM(s.MockCtrl)
+	s.DBA = newMockORM(s.MockCtrl)
 	s.IdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockCtrl)
 	s.AppDB = &Room{
 		dba:                s.DBA,
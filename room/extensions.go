@@ -0,0 +1,34 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//findAll Loads all rows of the table backing entity through the ORM if it is an orm.RowLoader
+func findAll(dba orm.ORM, entity interface{}, out interface{}) error {
+	loader, ok := dba.(orm.RowLoader)
+	if !ok {
+		return fmt.Errorf("ORM %T can not read the rows of %T. It should implement orm.RowLoader", dba, entity)
+	}
+	return loader.FindAll(entity, out).Error
+}
+
+//autoMigrate Adds missing columns of the entities through the ORM if it is an orm.AutoMigrator. Other ORMs keep their tables up to date themselves
+func autoMigrate(dba orm.ORM, entities ...interface{}) error {
+	migrator, ok := dba.(orm.AutoMigrator)
+	if !ok {
+		return nil
+	}
+	return migrator.AutoMigrate(entities...).Error
+}
+
+//renameTable Renames a table through the ORM if it is an orm.TableRenamer
+func renameTable(dba orm.ORM, from string, to string) error {
+	renamer, ok := dba.(orm.TableRenamer)
+	if !ok {
+		return fmt.Errorf("ORM %T can not rename table %v. It should implement orm.TableRenamer", dba, from)
+	}
+	return renamer.RenameTable(from, to).Error
+}
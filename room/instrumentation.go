@@ -0,0 +1,66 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//Operations of Room reported to the configured instrumentation
+const (
+	OperationCalculateIdentityHash = "calculate_identity_hash"
+	OperationInit                  = "init"
+	OperationMigration             = "migration"
+	OperationSanityCheck           = "sanity_check"
+	OperationCleanUp               = "cleanup"
+)
+
+//Labels attached to the operations reported to the configured instrumentation
+const (
+	LabelFromVersion = "from_version"
+	LabelToVersion   = "to_version"
+	LabelScenario    = "scenario"
+	LabelCleanUpMode = "mode"
+)
+
+//Values of LabelScenario for the initialization scenarios
+const (
+	ScenarioCreate          = "create"
+	ScenarioSanityCheck     = "sanity_check"
+	ScenarioMigration       = "migration"
+	ScenarioReadOnly        = "read_only"
+	ScenarioNewerCompatible = "newer_compatible"
+)
+
+//Values of LabelCleanUpMode
+const (
+	CleanUpModeFull      = "full"
+	CleanUpModeSelective = "selective"
+)
+
+type noopOperationRecorder struct{}
+
+func (noopOperationRecorder) SetLabel(key string, value string) {}
+
+func (noopOperationRecorder) End(err error) {}
+
+func (appDB *Room) startOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	if appDB.instrumentation == nil {
+		return noopOperationRecorder{}
+	}
+	return appDB.instrumentation.StartOperation(operation, labels)
+}
+
+func (appDB *Room) startMigrationOperation(migration orm.Migration) orm.OperationRecorder {
+	if appDB.instrumentation == nil {
+		return noopOperationRecorder{}
+	}
+	return appDB.instrumentation.StartOperation(OperationMigration, map[string]string{
+		LabelFromVersion: versionLabel(migration.GetBaseVersion()),
+		LabelToVersion:   versionLabel(migration.GetTargetVersion()),
+	})
+}
+
+func versionLabel(version orm.VersionNumber) string {
+	return fmt.Sprint(version)
+}
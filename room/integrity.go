@@ -0,0 +1,165 @@
+package room
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//IntegrityPolicy Decides whether rows of the schema master are signed and verified to detect modifications made outside Room
+type IntegrityPolicy int
+
+const (
+	//NoIntegrityCheck Rows are neither signed nor verified
+	NoIntegrityCheck IntegrityPolicy = iota
+	//VerifyIntegrity Rows are signed and verified on every Init. Unsigned rows written before the policy was enabled are accepted
+	VerifyIntegrity
+	//StrictIntegrity Rows are signed and verified on every Init. Unsigned rows are treated as modified
+	StrictIntegrity
+)
+
+//IntegrityViolation Kind of modification detected in the schema master
+type IntegrityViolation int
+
+const (
+	//ChecksumMismatch Contents of a row do not match its checksum
+	ChecksumMismatch IntegrityViolation = iota
+	//MissingChecksum A row carries no checksum
+	MissingChecksum
+	//ConflictingRows More than one row found where Room keeps only one
+	ConflictingRows
+)
+
+func (violation IntegrityViolation) String() string {
+	switch violation {
+	case ChecksumMismatch:
+		return "checksum mismatch"
+	case MissingChecksum:
+		return "missing checksum"
+	case ConflictingRows:
+		return "conflicting rows"
+	default:
+		return fmt.Sprintf("integrity violation(%d)", int(violation))
+	}
+}
+
+//SchemaMasterIntegrityError Schema master was modified outside Room. Use RepairSchemaMaster or PerformDBCleanUp to recover
+type SchemaMasterIntegrityError struct {
+	Violation IntegrityViolation
+	Versions  []orm.VersionNumber //Versions of the offending rows
+}
+
+func (err *SchemaMasterIntegrityError) Error() string {
+	return fmt.Sprintf("Room Schema Master was modified outside Room. Found %v for versions %v", err.Violation, err.Versions)
+}
+
+//calculateChecksum Checksum over the contents of the row. HMAC-SHA256 if a key is given, SHA-256 otherwise.
+//Plain SHA-256 only detects accidental corruption as anyone editing the row can recompute it
+func (master GoRoomSchemaMaster) calculateChecksum(key []byte) string {
+	var mac hash.Hash
+	if len(key) > 0 {
+		mac = hmac.New(sha256.New, key)
+	} else {
+		mac = sha256.New()
+	}
+
+	fields := []string{
+		fmt.Sprint(master.Version),
+		master.IdentityHash,
+		master.EntityHashes,
+		master.ResetTables,
+		master.OrphanedTables,
+		fmt.Sprint(master.MinCompatibleVersion),
+	}
+	//Appended only when set so that rows signed without repeatable migrations keep their checksum
+	if master.RepeatableChecksums != "" {
+		fields = append(fields, master.RepeatableChecksums)
+	}
+
+	mac.Write([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//sign Sets the checksum of a row about to be written if integrity protection is enabled
+func (appDB *Room) sign(metadata GoRoomSchemaMaster) GoRoomSchemaMaster {
+	if appDB.integrity == NoIntegrityCheck {
+		return metadata
+	}
+
+	metadata.Checksum = metadata.calculateChecksum(appDB.integrityKey)
+	return metadata
+}
+
+//verifySchemaMasterIntegrity Checks the rows of the schema master against their checksums as per the integrity policy
+func (appDB *Room) verifySchemaMasterIntegrity() error {
+	if appDB.integrity == NoIntegrityCheck {
+		return nil
+	}
+
+	var records []GoRoomSchemaMaster
+	if err := findAll(appDB.dba, appDB.schemaMaster, &records); err != nil {
+		appDB.log().Errorf("Error while fetching room records from the DB. %v", err)
+		return err
+	}
+
+	if len(records) > 1 {
+		var versions []orm.VersionNumber
+		for _, record := range records {
+			versions = append(versions, record.Version)
+		}
+		return appDB.integrityViolated(ConflictingRows, versions)
+	}
+
+	for _, record := range records {
+		if record.Checksum == "" {
+			if appDB.integrity == StrictIntegrity {
+				return appDB.integrityViolated(MissingChecksum, []orm.VersionNumber{record.Version})
+			}
+			continue
+		}
+
+		expected := record.calculateChecksum(appDB.integrityKey)
+		if !hmac.Equal([]byte(expected), []byte(record.Checksum)) {
+			return appDB.integrityViolated(ChecksumMismatch, []orm.VersionNumber{record.Version})
+		}
+	}
+
+	return nil
+}
+
+func (appDB *Room) integrityViolated(violation IntegrityViolation, versions []orm.VersionNumber) error {
+	err := &SchemaMasterIntegrityError{
+		Violation: violation,
+		Versions:  versions,
+	}
+	appDB.log().Errorf("%v", err)
+	return err
+}
+
+//RepairSchemaMaster Accepts the row with the highest version as the truth, discarding other rows and signing it afresh.
+//Entity tables are not touched. Any mismatch with the entities is still caught by the usual checks of the next Init
+func (appDB *Room) RepairSchemaMaster() (err error) {
+	if appDB.readOnly {
+		return fmt.Errorf("Room opened read only. Repair of the Schema Master is not allowed")
+	}
+
+	if err = appDB.acquireLock(); err != nil {
+		return err
+	}
+	defer appDB.releaseLock()
+
+	return appDB.doInTransaction(func(dba orm.ORM) error {
+		latest, err := appDB.getLatestRoomRecord(dba)
+		if err != nil {
+			return err
+		}
+
+		appDB.log().Warnf("Repairing Room Schema Master with the record of version %v", latest.Version)
+		return appDB.replaceRoomRecord(dba, *latest)
+	})
+}
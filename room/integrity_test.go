@@ -0,0 +1,122 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type IntegrityTestSuite struct {
+	suite.Suite
+	MockCtrl *gomock.Controller
+	DBA      *mocks.MockORM
+	Loader   *mocks.MockRowLoader
+	Migrator *mocks.MockAutoMigrator
+	ORM      *RowLoadingORM
+	AppDB    *Room
+	Record   GoRoomSchemaMaster
+}
+
+func (s *IntegrityTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.Migrator = mocks.NewMockAutoMigrator(s.MockCtrl)
+	s.ORM = &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader, MockAutoMigrator: s.Migrator}
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false).AnyTimes()
+	s.AppDB = &Room{
+		version:      3,
+		dba:          s.ORM,
+		logger:       &RecordingLogger{},
+		integrity:    VerifyIntegrity,
+		integrityKey: []byte("secret"),
+	}
+	s.Record = s.AppDB.sign(GoRoomSchemaMaster{
+		Version:      3,
+		IdentityHash: "asasasa",
+		EntityHashes: `{"dummy_tables":"d1"}`,
+	})
+}
+
+func (s *IntegrityTestSuite) expectRecords(records ...GoRoomSchemaMaster) {
+	s.Loader.EXPECT().FindAll(GoRoomSchemaMaster{}, gomock.Any()).SetArg(1, records).Return(orm.Result{})
+}
+
+func (s *IntegrityTestSuite) TestCalculateChecksum() {
+	checksum := s.Record.calculateChecksum([]byte("secret"))
+	assert.Equal(s.T(), checksum, s.Record.Checksum)
+	assert.NotEqual(s.T(), checksum, s.Record.calculateChecksum(nil), "HMAC should differ from plain SHA-256")
+	assert.NotEqual(s.T(), checksum, s.Record.calculateChecksum([]byte("other")))
+
+	modified := s.Record
+	modified.OrphanedTables = `["dummy_tables"]`
+	assert.NotEqual(s.T(), checksum, modified.calculateChecksum([]byte("secret")))
+
+	assert.Empty(s.T(), (&Room{}).sign(GoRoomSchemaMaster{Version: 3}).Checksum, "Rows are not signed without an integrity policy")
+}
+
+func (s *IntegrityTestSuite) TestVerifySchemaMasterIntegrity() {
+	s.expectRecords(s.Record)
+	assert.Nil(s.T(), s.AppDB.verifySchemaMasterIntegrity())
+
+	tampered := s.Record
+	tampered.IdentityHash = "forged"
+	s.expectRecords(tampered)
+	assert.Equal(s.T(), &SchemaMasterIntegrityError{Violation: ChecksumMismatch, Versions: []orm.VersionNumber{3}}, s.AppDB.verifySchemaMasterIntegrity())
+
+	s.expectRecords(GoRoomSchemaMaster{Version: 2}, s.Record)
+	assert.Equal(s.T(), &SchemaMasterIntegrityError{Violation: ConflictingRows, Versions: []orm.VersionNumber{2, 3}}, s.AppDB.verifySchemaMasterIntegrity())
+
+	unsigned := s.Record
+	unsigned.Checksum = ""
+	s.expectRecords(unsigned)
+	assert.Nil(s.T(), s.AppDB.verifySchemaMasterIntegrity(), "Unsigned rows are accepted unless integrity is strict")
+
+	s.AppDB.integrity = StrictIntegrity
+	s.expectRecords(unsigned)
+	assert.Equal(s.T(), &SchemaMasterIntegrityError{Violation: MissingChecksum, Versions: []orm.VersionNumber{3}}, s.AppDB.verifySchemaMasterIntegrity())
+
+	s.AppDB.integrity = NoIntegrityCheck
+	assert.Nil(s.T(), s.AppDB.verifySchemaMasterIntegrity())
+}
+
+func (s *IntegrityTestSuite) TestInitWithModifiedSchemaMaster() {
+	tampered := s.Record
+	tampered.Version = 4
+	s.AppDB.destructiveFallback = DestructiveFallbackToCleanDB
+	s.DBA.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(true)
+	s.expectRecords(tampered)
+
+	shouldRetry, err := s.AppDB.Init("asasasa")
+	assert.False(s.T(), shouldRetry, "Modified metadata should not lead to destruction")
+	assert.Equal(s.T(), "Room Schema Master was modified outside Room. Found checksum mismatch for versions [4]", err.Error())
+}
+
+func (s *IntegrityTestSuite) TestRepairSchemaMaster() {
+	unsigned := s.Record
+	unsigned.Checksum = ""
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	s.expectRecords(GoRoomSchemaMaster{Version: 2}, unsigned)
+	gomock.InOrder(
+		s.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&s.Record).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.RepairSchemaMaster())
+}
+
+func (s *IntegrityTestSuite) TestRepairSchemaMasterWithoutRecords() {
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	s.expectRecords()
+
+	assert.Equal(s.T(), fmt.Errorf("No records found in Room Schema Master"), s.AppDB.RepairSchemaMaster())
+}
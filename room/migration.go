@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"sort"
 
-	"github.com/adonmo/goroom/logger"
 	"github.com/adonmo/goroom/orm"
 )
 
@@ -70,49 +69,331 @@ func getMigrationMap(migrations []orm.Migration) map[orm.VersionNumber][]orm.Mig
 	return migrationMap
 }
 
+//getAuxiliaryTables Tables kept by the given migrations besides entity tables sorted by name
+func getAuxiliaryTables(migrations []orm.Migration) []string {
+	auxiliarySet := make(map[string]bool)
+	for _, migration := range migrations {
+		if auxiliaryMigration, ok := migration.(orm.AuxiliaryTableMigration); ok {
+			for _, tableName := range auxiliaryMigration.GetAuxiliaryTables() {
+				auxiliarySet[tableName] = true
+			}
+		}
+	}
+
+	auxiliaryTables := make([]string, 0, len(auxiliarySet))
+	for tableName := range auxiliarySet {
+		auxiliaryTables = append(auxiliaryTables, tableName)
+	}
+	sort.Strings(auxiliaryTables)
+	return auxiliaryTables
+}
+
+//migrationSegment Consecutive migrations applied together. A non transactional migration makes up a segment of its own
+type migrationSegment struct {
+	migrations    []orm.Migration
+	firstStep     int //Index of the first migration of the segment among all applicable migrations
+	totalSteps    int
+	transactional bool
+	final         bool //Segment reaching the version of current Room instance
+}
+
+func (segment migrationSegment) getTargetVersion() orm.VersionNumber {
+	return segment.migrations[len(segment.migrations)-1].GetTargetVersion()
+}
+
+//splitIntoSegments Groups migrations so that every non transactional migration runs on its own between schema transactions
+func splitIntoSegments(applicableMigrations []orm.Migration) (segments []migrationSegment) {
+	for i, migration := range applicableMigrations {
+		nonTransactional, ok := migration.(orm.NonTransactionalMigration)
+		transactional := !ok || !nonTransactional.RunsOutsideTransaction()
+
+		last := len(segments) - 1
+		if transactional && last >= 0 && segments[last].transactional {
+			segments[last].migrations = append(segments[last].migrations, migration)
+			continue
+		}
+
+		segments = append(segments, migrationSegment{
+			migrations:    []orm.Migration{migration},
+			firstStep:     i,
+			totalSteps:    len(applicableMigrations),
+			transactional: transactional,
+		})
+	}
+
+	if len(segments) > 0 {
+		segments[len(segments)-1].final = true
+	}
+	return
+}
+
+//PartialMigrationError Returned by Init when migrating failed after changes may have reached the DB. Either committing a migration
+//transaction failed, or migrations applied outside a transaction or earlier segments were committed before the failure.
+//Other failures of migrations are rolled back leaving the DB as it was
+type PartialMigrationError struct {
+	Err error
+}
+
+func (e *PartialMigrationError) Error() string {
+	return fmt.Sprintf("Migration failed after changing the DB partially. %v", e.Err)
+}
+
+//Unwrap Gives the error the migration failed with
+func (e *PartialMigrationError) Unwrap() error {
+	return e.Err
+}
+
+//doInMigrationTransaction Runs a migration transaction reporting a failure to commit it as a PartialMigrationError
+func (appDB *Room) doInMigrationTransaction(fc func(orm.ORM) error) error {
+	committing := false
+	err := appDB.dba.DoInTransaction(func(dba orm.ORM) error {
+		err := fc(dba)
+		if err == nil {
+			err = appDB.checkLockHeld()
+		}
+		committing = err == nil
+		return err
+	})
+	if err != nil && committing {
+		return &PartialMigrationError{Err: err}
+	}
+	return err
+}
+
 func (appDB *Room) performMigrations(currentIdentityHash string, applicableMigrations []orm.Migration) error {
 
-	return appDB.dba.DoInTransaction(getMigrationTransactionFunction(appDB.version, currentIdentityHash, applicableMigrations))
+	entityHashes, err := appDB.getEntityHashes()
+	if err != nil {
+		return err
+	}
+
+	//Claimed in a transaction of its own as non transactional migrations create them outside the migration transactions
+	if err = appDB.claimAuxiliaryTables(applicableMigrations); err != nil {
+		appDB.log().Errorf("Unable to claim tables kept by the migrations. %v", err)
+		return err
+	}
+
+	segments := splitIntoSegments(applicableMigrations)
+	if len(segments) < 1 || len(segments) == 1 && segments[0].transactional {
+		if err = appDB.doInMigrationTransaction(appDB.getMigrationTransactionFunction(currentIdentityHash, entityHashes, applicableMigrations)); err != nil {
+			return err
+		}
+		appDB.recordAppliedMigrations(applicableMigrations)
+		return nil
+	}
+
+	/*
+		Non transactional migrations(e.g. batched backfills) are applied outside the schema transaction.
+		The version reached before and after each of them is committed separately so that an interrupted Init resumes from there.
+	*/
+	changed := false
+	for _, segment := range segments {
+		if !segment.transactional {
+			changed = true
+			err = appDB.applyMigrations(appDB.dba.GetUnderlyingORM(), segment)
+		}
+
+		if err == nil && segment.final {
+			err = appDB.doInMigrationTransaction(appDB.getSegmentTransactionFunction(currentIdentityHash, entityHashes, segment))
+		} else if err == nil {
+			err = appDB.doInMigrationTransaction(appDB.getSegmentTransactionFunction("", nil, segment))
+		}
+		if err != nil {
+			if _, partial := err.(*PartialMigrationError); changed && !partial {
+				err = &PartialMigrationError{Err: err}
+			}
+			return err
+		}
+		changed = true
+		appDB.recordAppliedMigrations(segment.migrations)
+	}
+
+	return nil
+}
+
+//recordAppliedMigrations Adds committed migrations to the result of the ongoing Init
+func (appDB *Room) recordAppliedMigrations(migrations []orm.Migration) {
+	if appDB.lastResult == nil {
+		return
+	}
+
+	for _, migration := range migrations {
+		appDB.lastResult.MigrationsApplied = append(appDB.lastResult.MigrationsApplied, AppliedMigration{
+			From: migration.GetBaseVersion(),
+			To:   migration.GetTargetVersion(),
+		})
+		appDB.lastResult.Version = migration.GetTargetVersion()
+	}
 }
 
-func getMigrationTransactionFunction(targetVersion orm.VersionNumber, currentIdentityHash string, applicableMigrations []orm.Migration) func(orm.ORM) error {
+func (appDB *Room) getMigrationTransactionFunction(currentIdentityHash string, entityHashes map[string]string, applicableMigrations []orm.Migration) func(orm.ORM) error {
+
+	return appDB.getSegmentTransactionFunction(currentIdentityHash, entityHashes, migrationSegment{
+		migrations:    applicableMigrations,
+		totalSteps:    len(applicableMigrations),
+		transactional: true,
+		final:         true,
+	})
+}
+
+func (appDB *Room) getSegmentTransactionFunction(currentIdentityHash string, entityHashes map[string]string, segment migrationSegment) func(orm.ORM) error {
 
 	/*
 		Failure Scenarios:
-		1.) A migration fails
-		2.) Truncating the Schema Master fails
-		3.) Creating a new entry in Schema Master fails
+		1.) A migration or its pre/post conditions fail
+		2.) Handling orphaned tables as per the configured policy fails
+		3.) Migrated schema differs from a fresh install of the entities as per the configured verifier
+		4.) Adding columns introduced by newer Room releases to the Schema Master fails
+		5.) Truncating the Schema Master fails
+		6.) Creating a new entry in Schema Master fails
 
-		Migrations, truncation and new entry creation are done in a single transaction.
+		Migrations, orphaned table handling, verification, truncation and new entry creation are done in a single transaction.
+		Segments not reaching current version only record the version they reach.
 	*/
 
 	return func(dba orm.ORM) error {
-		for _, migration := range applicableMigrations {
-			err := migration.Apply(dba.GetUnderlyingORM())
-			if err != nil {
-				logger.Errorf("Failed while applying migration. %v", migration)
+		if segment.transactional {
+			if err := appDB.applyMigrations(dba.GetUnderlyingORM(), segment); err != nil {
 				return err
 			}
 		}
 
-		dbExec := dba.TruncateTable(GoRoomSchemaMaster{})
-		if dbExec.Error != nil {
-			logger.Errorf("Error while purging Room Schema Master. %v", dbExec.Error)
-			return dbExec.Error
+		if !segment.final {
+			return appDB.recordIntermediateVersion(dba, segment.getTargetVersion())
 		}
 
-		metadata := GoRoomSchemaMaster{
-			Version:      targetVersion,
-			IdentityHash: currentIdentityHash,
+		orphanedTables, err := appDB.handleOrphanedTables(dba, entityHashes)
+		if err != nil {
+			appDB.log().Errorf("Error while handling orphaned tables. %v", err)
+			return err
 		}
 
-		dbExec = dba.Create(&metadata)
-		if dbExec.Error != nil {
-			logger.Errorf("Error while adding entity hash to Room Schema Master. %v", dbExec.Error)
-			return dbExec.Error
+		//Orphaned tables still present stay claimed till they are dropped
+		if err = appDB.claimEntityTables(dba, orphanedTables); err != nil {
+			return err
 		}
 
-		return nil
+		if appDB.schemaVerifier != nil {
+			if err = appDB.schemaVerifier.VerifySchema(dba, appDB.entities); err != nil {
+				appDB.log().Errorf("Migration to version %v failed verification. %v", appDB.version, err)
+				return err
+			}
+		}
+
+		metadata := appDB.schemaMaster.withRecord(appDB.version, currentIdentityHash).withEntityHashes(entityHashes).withMinCompatibleVersion(appDB.minCompatibleVersion)
+		metadata.OrphanedTables = encodeJSONColumn(orphanedTables)
+		if err = appDB.carryRepeatableChecksums(dba, &metadata); err != nil {
+			return err
+		}
+		return appDB.replaceRoomRecord(dba, metadata)
+	}
+
+}
+
+//recordIntermediateVersion Records a version reached midway. Tables known for the version migrated from are carried forward
+func (appDB *Room) recordIntermediateVersion(dba orm.ORM, version orm.VersionNumber) error {
+	previous, err := appDB.getLatestRoomRecord(dba)
+	if err != nil {
+		return err
+	}
+
+	metadata := appDB.schemaMaster.withRecord(version, "")
+	metadata.EntityHashes = previous.EntityHashes
+	metadata.OrphanedTables = previous.OrphanedTables
+	metadata.RepeatableChecksums = previous.RepeatableChecksums
+	return appDB.replaceRoomRecord(dba, metadata)
+}
+
+//replaceRoomRecord Replaces the record of Schema Master with the given one
+func (appDB *Room) replaceRoomRecord(dba orm.ORM, metadata GoRoomSchemaMaster) error {
+	if err := autoMigrate(dba, appDB.schemaMaster); err != nil {
+		appDB.log().Errorf("Error while upgrading Room Schema Master. %v", err)
+		return err
+	}
+
+	dbExec := dba.TruncateTable(appDB.schemaMaster)
+	if dbExec.Error != nil {
+		appDB.log().Errorf("Error while purging Room Schema Master. %v", dbExec.Error)
+		return dbExec.Error
+	}
+
+	metadata = appDB.sign(metadata)
+	dbExec = dba.Create(&metadata)
+	if dbExec.Error != nil {
+		appDB.log().Errorf("Error while adding entity hash to Room Schema Master. %v", dbExec.Error)
+		return dbExec.Error
+	}
+
+	return nil
+}
+
+//applyMigrations Applies migrations of the segment reporting progress of each
+func (appDB *Room) applyMigrations(db interface{}, segment migrationSegment) error {
+	for i, migration := range segment.migrations {
+		progress := orm.MigrationProgress{
+			Stage:      orm.MigrationStarted,
+			Step:       segment.firstStep + i + 1,
+			TotalSteps: segment.totalSteps,
+			Migration:  migration,
+		}
+		appDB.reportProgress(progress)
+
+		reportRowsProcessed := func(rowsProcessed int64) {
+			progress.Stage = orm.MigrationRowsProcessed
+			progress.RowsProcessed = rowsProcessed
+			appDB.reportProgress(progress)
+		}
+		operation := appDB.startMigrationOperation(migration)
+		err := applyMigration(migration, db, reportRowsProcessed)
+		operation.End(err)
+		if err != nil {
+			appDB.log().Errorf("Failed while applying migration. %v", migration)
+			return err
+		}
+
+		progress.Stage = orm.MigrationCompleted
+		appDB.reportProgress(progress)
+	}
+
+	return nil
+}
+
+func (appDB *Room) reportProgress(progress orm.MigrationProgress) {
+	if appDB.progressReporter != nil {
+		appDB.progressReporter(progress)
+	}
+}
+
+//applyMigration Applies the migration checking its pre and post conditions if it has any
+func applyMigration(migration orm.Migration, db interface{}, reportRowsProcessed func(int64)) error {
+	preValidated, isPreValidated := migration.(orm.PreValidatedMigration)
+	var before interface{}
+	var err error
+	if isPreValidated {
+		if before, err = preValidated.ValidateBefore(db); err != nil {
+			return fmt.Errorf("Pre conditions of migration from %v to %v failed. %v", migration.GetBaseVersion(), migration.GetTargetVersion(), err)
+		}
+	}
+
+	if reportingMigration, ok := migration.(orm.ReportingMigration); ok {
+		err = reportingMigration.ApplyWithProgress(db, reportRowsProcessed)
+	} else {
+		err = migration.Apply(db)
+	}
+	if err != nil {
+		return err
+	}
+
+	if isPreValidated {
+		if err = preValidated.ValidateAfter(db, before); err != nil {
+			return fmt.Errorf("Post conditions of migration from %v to %v failed. %v", migration.GetBaseVersion(), migration.GetTargetVersion(), err)
+		}
+	}
+	if validated, ok := migration.(orm.ValidatedMigration); ok {
+		if err = validated.Validate(db); err != nil {
+			return fmt.Errorf("Post conditions of migration from %v to %v failed. %v", migration.GetBaseVersion(), migration.GetTargetVersion(), err)
+		}
 	}
 
+	return nil
 }
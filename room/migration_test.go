@@ -1,6 +1,7 @@
 package room
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/adonmo/goroom/orm"
@@ -122,6 +123,9 @@ type MigrationExecutionTestSuite struct {
 	InvalidMigrations []orm.Migration
 	AppDB             *Room
 	MockDBA           *mocks.MockORM
+	Loader            *mocks.MockRowLoader
+	Migrator          *mocks.MockAutoMigrator
+	ORM               *RowLoadingORM
 }
 
 func (suite *MigrationExecutionTestSuite) SetupTest() {
@@ -138,10 +142,14 @@ func (suite *MigrationExecutionTestSuite) SetupTest() {
 		suite.InvalidMigrations = append(suite.ValidMigrations, m)
 	}
 
-	suite.MockDBA = mocks.NewMockORM(suite.MockCtrl)
+	suite.MockDBA = newMockORM(suite.MockCtrl)
+	suite.Loader = mocks.NewMockRowLoader(suite.MockCtrl)
+	suite.Migrator = mocks.NewMockAutoMigrator(suite.MockCtrl)
+	suite.ORM = &RowLoadingORM{MockORM: suite.MockDBA, MockRowLoader: suite.Loader, MockAutoMigrator: suite.Migrator}
+	suite.MockDBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false).AnyTimes()
 	suite.AppDB = &Room{
 		version: orm.VersionNumber(3),
-		dba:     suite.MockDBA,
+		dba:     suite.ORM,
 	}
 }
 
@@ -150,22 +158,227 @@ func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWit
 	var dummyORM interface{}
 	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
 
-	migrationFunc := getMigrationTransactionFunction(suite.AppDB.version, "asasasa", append(suite.ValidMigrations, suite.InvalidMigrations...))
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, append(suite.ValidMigrations, suite.InvalidMigrations...))
 
 	err := migrationFunc(suite.AppDB.dba)
 	assert.NotNil(suite.T(), err, "Should have received an error for invalid migrations")
 }
 
+func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionReportsProgress() {
+
+	var dummyORM interface{}
+	var events []orm.MigrationProgress
+	suite.AppDB.progressReporter = func(progress orm.MigrationProgress) {
+		events = append(events, progress)
+	}
+
+	plainMigration := suite.ValidMigrations[0]
+	backfillMigration := mocks.NewMockReportingMigration(suite.MockCtrl)
+	backfillMigration.EXPECT().ApplyWithProgress(dummyORM, gomock.Any()).DoAndReturn(func(db interface{}, reportRowsProcessed func(int64)) error {
+		reportRowsProcessed(500)
+		reportRowsProcessed(1000)
+		return nil
+	})
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	suite.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{})
+	suite.MockDBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{})
+	suite.MockDBA.EXPECT().Create(gomock.Any()).Return(orm.Result{})
+
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, []orm.Migration{plainMigration, backfillMigration})
+	assert.Nil(suite.T(), migrationFunc(suite.AppDB.dba))
+
+	expectedEvents := []orm.MigrationProgress{
+		{Stage: orm.MigrationStarted, Step: 1, TotalSteps: 2, Migration: plainMigration},
+		{Stage: orm.MigrationCompleted, Step: 1, TotalSteps: 2, Migration: plainMigration},
+		{Stage: orm.MigrationStarted, Step: 2, TotalSteps: 2, Migration: backfillMigration},
+		{Stage: orm.MigrationRowsProcessed, Step: 2, TotalSteps: 2, Migration: backfillMigration, RowsProcessed: 500},
+		{Stage: orm.MigrationRowsProcessed, Step: 2, TotalSteps: 2, Migration: backfillMigration, RowsProcessed: 1000},
+		{Stage: orm.MigrationCompleted, Step: 2, TotalSteps: 2, Migration: backfillMigration, RowsProcessed: 1000},
+	}
+	assert.Equal(suite.T(), expectedEvents, events)
+}
+
+func (suite *MigrationExecutionTestSuite) getNonTransactionalMigration(targetVersion orm.VersionNumber) *mocks.MockNonTransactionalMigration {
+	migration := mocks.NewMockNonTransactionalMigration(suite.MockCtrl)
+	migration.EXPECT().RunsOutsideTransaction().Return(true).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(targetVersion).AnyTimes()
+	return migration
+}
+
+func (suite *MigrationExecutionTestSuite) TestSplitIntoSegments() {
+
+	first, second := suite.ValidMigrations[0], suite.ValidMigrations[1]
+	backfill := suite.getNonTransactionalMigration(3)
+
+	segments := splitIntoSegments([]orm.Migration{first, second, backfill, suite.ValidMigrations[2]})
+	assert.Equal(suite.T(), []migrationSegment{
+		{migrations: []orm.Migration{first, second}, firstStep: 0, totalSteps: 4, transactional: true},
+		{migrations: []orm.Migration{backfill}, firstStep: 2, totalSteps: 4},
+		{migrations: []orm.Migration{suite.ValidMigrations[2]}, firstStep: 3, totalSteps: 4, transactional: true, final: true},
+	}, segments)
+
+	assert.Empty(suite.T(), splitIntoSegments([]orm.Migration{}))
+}
+
+func (suite *MigrationExecutionTestSuite) TestPerformMigrationsOutsideTransaction() {
+
+	var dummyORM interface{}
+	identityCalc := mocks.NewMockIdentityHashCalculator(suite.MockCtrl)
+	suite.AppDB.identityCalculator = identityCalc
+	backfill := suite.getNonTransactionalMigration(2)
+
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	suite.Loader.EXPECT().FindAll(GoRoomSchemaMaster{}, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{
+		Version:      1,
+		IdentityHash: "old",
+		EntityHashes: `{"dummy_tables":"d1"}`,
+	}}).Return(orm.Result{})
+	gomock.InOrder(
+		backfill.EXPECT().Apply(dummyORM).Return(nil),
+		suite.MockDBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+			return fc(suite.ORM)
+		}),
+		suite.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		suite.MockDBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		suite.MockDBA.EXPECT().Create(&GoRoomSchemaMaster{
+			Version:      2,
+			EntityHashes: `{"dummy_tables":"d1"}`,
+		}).Return(orm.Result{}),
+		suite.MockDBA.EXPECT().DoInTransaction(gomock.Any()).Return(nil),
+	)
+
+	err := suite.AppDB.performMigrations("asasasa", []orm.Migration{backfill, suite.ValidMigrations[0]})
+	assert.Nil(suite.T(), err, "Version reached by the non transactional migration should be committed before the remaining migrations")
+}
+
+func (suite *MigrationExecutionTestSuite) TestPerformMigrationsOutsideTransactionWithFailure() {
+
+	var dummyORM interface{}
+	backfill := suite.getNonTransactionalMigration(2)
+	backfill.EXPECT().GetBaseVersion().Return(orm.VersionNumber(1)).AnyTimes()
+	backfillError := fmt.Errorf("Batch failed")
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	suite.Loader.EXPECT().FindAll(GoRoomSchemaMaster{}, gomock.Any()).Return(orm.Result{})
+	backfill.EXPECT().Apply(dummyORM).Return(backfillError)
+
+	err := suite.AppDB.performMigrations("asasasa", []orm.Migration{backfill, suite.ValidMigrations[0]})
+
+	partialErr, ok := err.(*PartialMigrationError)
+	assert.True(suite.T(), ok, "Batches committed before the failure remain in the DB. Got %v", err)
+	assert.True(suite.T(), errors.Is(partialErr, backfillError))
+}
+
+func (suite *MigrationExecutionTestSuite) TestDoInMigrationTransaction() {
+
+	commitError := fmt.Errorf("disk I/O error")
+	suite.MockDBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		if err := fc(suite.ORM); err != nil {
+			return err
+		}
+		return commitError
+	}).Times(2)
+
+	err := suite.AppDB.doInMigrationTransaction(func(orm.ORM) error { return nil })
+	assert.Equal(suite.T(), &PartialMigrationError{Err: commitError}, err, "Failed commit may leave changes in the DB")
+
+	migrationError := fmt.Errorf("Migration from 1 to 2 failed")
+	err = suite.AppDB.doInMigrationTransaction(func(orm.ORM) error { return migrationError })
+	assert.Equal(suite.T(), migrationError, err, "Failed migration is rolled back")
+}
+
+func (suite *MigrationExecutionTestSuite) TestDoInMigrationTransactionWithLostLock() {
+
+	locker := mocks.NewMockLeasedLocker(suite.MockCtrl)
+	suite.AppDB.locker = locker
+	lockError := fmt.Errorf("Lock go_room_init was taken over by someone else")
+	locker.EXPECT().CheckHeld().Return(lockError)
+	suite.MockDBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(suite.ORM)
+	})
+
+	err := suite.AppDB.doInMigrationTransaction(func(orm.ORM) error { return nil })
+	assert.Equal(suite.T(), lockError, err, "Migration must be rolled back once the lock is lost")
+}
+
+func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWithFailedPreConditions() {
+
+	var dummyORM interface{}
+	migration := mocks.NewMockPreValidatedMigration(suite.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	migration.EXPECT().ValidateBefore(dummyORM).Return(nil, fmt.Errorf("Table users is empty"))
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, []orm.Migration{migration})
+
+	err := migrationFunc(suite.AppDB.dba)
+	assert.EqualError(suite.T(), err, "Pre conditions of migration from 2 to 3 failed. Table users is empty", "Migration must not be applied when pre conditions fail")
+}
+
+func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionHandsPreConditionStateToPostConditions() {
+
+	var dummyORM interface{}
+	migration := mocks.NewMockPreValidatedMigration(suite.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	gomock.InOrder(
+		migration.EXPECT().ValidateBefore(dummyORM).Return(7, nil),
+		migration.EXPECT().Apply(dummyORM).Return(nil),
+		migration.EXPECT().ValidateAfter(dummyORM, 7).Return(fmt.Errorf("Table users had 7 rows before migration and has 6 after")),
+	)
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, []orm.Migration{migration})
+
+	err := migrationFunc(suite.AppDB.dba)
+	assert.EqualError(suite.T(), err, "Post conditions of migration from 2 to 3 failed. Table users had 7 rows before migration and has 6 after")
+}
+
+func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWithFailedPostConditions() {
+
+	var dummyORM interface{}
+	migration := mocks.NewMockValidatedMigration(suite.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	gomock.InOrder(
+		migration.EXPECT().Apply(dummyORM).Return(nil),
+		migration.EXPECT().Validate(dummyORM).Return(fmt.Errorf("Column user_id of profiles has 2 NULL values")),
+	)
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, []orm.Migration{migration})
+
+	err := migrationFunc(suite.AppDB.dba)
+	assert.EqualError(suite.T(), err, "Post conditions of migration from 2 to 3 failed. Column user_id of profiles has 2 NULL values")
+}
+
+func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWithFailedVerification() {
+
+	var dummyORM interface{}
+	expectedError := fmt.Errorf("Migrated schema differs from a fresh install")
+	verifier := mocks.NewMockSchemaVerifier(suite.MockCtrl)
+	suite.AppDB.entities = []interface{}{DummyTable{}}
+	suite.AppDB.schemaVerifier = verifier
+	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	verifier.EXPECT().VerifySchema(suite.MockDBA, []interface{}{DummyTable{}}).Return(expectedError)
+
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, suite.ValidMigrations)
+
+	err := migrationFunc(suite.MockDBA)
+	assert.Equal(suite.T(), expectedError, err, "Schema master must not be updated when verification fails")
+}
+
 func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWithFailedTruncationOfSchemaMaster() {
 
 	var dummyORM interface{}
 	expectedError := fmt.Errorf("Some DB mess happened")
 	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	suite.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{})
 	suite.MockDBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{
 		Error: expectedError,
 	})
 
-	migrationFunc := getMigrationTransactionFunction(suite.AppDB.version, "asasasa", append(suite.ValidMigrations))
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction("asasasa", nil, suite.ValidMigrations)
 
 	err := migrationFunc(suite.AppDB.dba)
 	assert.Equal(suite.T(), expectedError, err, "Should have received the expected error for failed truncation of schema master")
@@ -177,6 +390,7 @@ func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWit
 	expectedError := fmt.Errorf("Creation Failed")
 	identityHash := "asasasa"
 	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	suite.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{})
 	suite.MockDBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{
 		Error: nil,
 	})
@@ -187,7 +401,7 @@ func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunctionWit
 		Error: expectedError,
 	})
 
-	migrationFunc := getMigrationTransactionFunction(suite.AppDB.version, identityHash, append(suite.ValidMigrations))
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction(identityHash, nil, suite.ValidMigrations)
 
 	err := migrationFunc(suite.AppDB.dba)
 	assert.Equal(suite.T(), expectedError, err, "Should have received the expected error for failed creation of schema master record")
@@ -198,6 +412,10 @@ func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunction()
 	var dummyORM interface{}
 	identityHash := "asasasa"
 	suite.MockDBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	verifier := mocks.NewMockSchemaVerifier(suite.MockCtrl)
+	suite.AppDB.schemaVerifier = verifier
+	verifier.EXPECT().VerifySchema(suite.ORM, gomock.Any()).Return(nil)
+	suite.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{})
 	suite.MockDBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{
 		Error: nil,
 	})
@@ -208,7 +426,7 @@ func (suite *MigrationExecutionTestSuite) TestGetMigrationTransactionFunction()
 		Error: nil,
 	})
 
-	migrationFunc := getMigrationTransactionFunction(suite.AppDB.version, identityHash, append(suite.ValidMigrations))
+	migrationFunc := suite.AppDB.getMigrationTransactionFunction(identityHash, nil, suite.ValidMigrations)
 
 	err := migrationFunc(suite.AppDB.dba)
 	assert.Equal(suite.T(), nil, err, "No error expected. This is supposed to be the ideal scenario.")
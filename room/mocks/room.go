@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	room "github.com/adonmo/goroom/room"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -75,3 +76,84 @@ func (mr *MockInitializerMockRecorder) PerformDBCleanUp() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PerformDBCleanUp", reflect.TypeOf((*MockInitializer)(nil).PerformDBCleanUp))
 }
+
+// MockConfiguredInitializer is a mock of ConfiguredInitializer interface
+type MockConfiguredInitializer struct {
+	ctrl     *gomock.Controller
+	recorder *MockConfiguredInitializerMockRecorder
+}
+
+// MockConfiguredInitializerMockRecorder is the mock recorder for MockConfiguredInitializer
+type MockConfiguredInitializerMockRecorder struct {
+	mock *MockConfiguredInitializer
+}
+
+// NewMockConfiguredInitializer creates a new mock instance
+func NewMockConfiguredInitializer(ctrl *gomock.Controller) *MockConfiguredInitializer {
+	mock := &MockConfiguredInitializer{ctrl: ctrl}
+	mock.recorder = &MockConfiguredInitializerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockConfiguredInitializer) EXPECT() *MockConfiguredInitializerMockRecorder {
+	return m.recorder
+}
+
+// Init mocks base method
+func (m *MockConfiguredInitializer) Init(currentIdentityHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init", currentIdentityHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Init indicates an expected call of Init
+func (mr *MockConfiguredInitializerMockRecorder) Init(currentIdentityHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockConfiguredInitializer)(nil).Init), currentIdentityHash)
+}
+
+// CalculateIdentityHash mocks base method
+func (m *MockConfiguredInitializer) CalculateIdentityHash() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CalculateIdentityHash")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CalculateIdentityHash indicates an expected call of CalculateIdentityHash
+func (mr *MockConfiguredInitializerMockRecorder) CalculateIdentityHash() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CalculateIdentityHash", reflect.TypeOf((*MockConfiguredInitializer)(nil).CalculateIdentityHash))
+}
+
+// PerformDBCleanUp mocks base method
+func (m *MockConfiguredInitializer) PerformDBCleanUp() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PerformDBCleanUp")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PerformDBCleanUp indicates an expected call of PerformDBCleanUp
+func (mr *MockConfiguredInitializerMockRecorder) PerformDBCleanUp() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PerformDBCleanUp", reflect.TypeOf((*MockConfiguredInitializer)(nil).PerformDBCleanUp))
+}
+
+// GetDestructiveFallbackPolicy mocks base method
+func (m *MockConfiguredInitializer) GetDestructiveFallbackPolicy() room.DestructiveFallbackPolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDestructiveFallbackPolicy")
+	ret0, _ := ret[0].(room.DestructiveFallbackPolicy)
+	return ret0
+}
+
+// GetDestructiveFallbackPolicy indicates an expected call of GetDestructiveFallbackPolicy
+func (mr *MockConfiguredInitializerMockRecorder) GetDestructiveFallbackPolicy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDestructiveFallbackPolicy", reflect.TypeOf((*MockConfiguredInitializer)(nil).GetDestructiveFallbackPolicy))
+}
@@ -0,0 +1,234 @@
+package room
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//GoRoomNamespaceTable Records the namespace managing an entity table when Rooms of different namespaces share a DB.
+//Tables of Rooms without a namespace are recorded against the empty namespace
+type GoRoomNamespaceTable struct {
+	EntityTable string `gorm:"primary_key"`
+	Namespace   string
+}
+
+//defaultNamespaceLabel Name of the empty namespace in messages
+const defaultNamespaceLabel = "<default>"
+
+//checkNamespaceCollisions Fails if another namespace manages any of the entity tables. The DB is not modified
+func (appDB *Room) checkNamespaceCollisions() error {
+	if !isNamespaceRegistered(appDB.dba) || !appDB.dba.HasTable(GoRoomNamespaceTable{}) {
+		return nil
+	}
+
+	claims, err := appDB.getNamespaceClaims(appDB.dba)
+	if err != nil {
+		return err
+	}
+	return appDB.getNamespaceCollisionError(claims, appDB.getEntityTableNames())
+}
+
+//claimEntityTables Registers the entity tables along with the kept tables against the namespace of this Room and releases
+//claims of the namespace on any other table. It is run in the transaction recording the version of the Room so that claims change along with it
+func (appDB *Room) claimEntityTables(dba orm.ORM, keptTables []string) error {
+	if !isNamespaceRegistered(dba) {
+		return nil
+	}
+	return appDB.syncNamespaceClaims(dba, append(appDB.getEntityTableNames(), keptTables...))
+}
+
+//releaseEntityTables Releases all claims of the namespace of this Room e.g. when its tables are dropped
+func (appDB *Room) releaseEntityTables(dba orm.ORM) error {
+	if !isNamespaceRegistered(dba) {
+		return nil
+	}
+	return appDB.syncNamespaceClaims(dba, nil)
+}
+
+//claimAuxiliaryTables Registers the tables kept by the migrations against the namespace of this Room along with the tables it claims already.
+//They are released once the version migrated to is recorded, by when the migrations should have dropped them
+func (appDB *Room) claimAuxiliaryTables(migrations []orm.Migration) error {
+	auxiliaryTables := getAuxiliaryTables(migrations)
+	if len(auxiliaryTables) < 1 || !isNamespaceRegistered(appDB.dba) {
+		return nil
+	}
+
+	return appDB.doInTransaction(func(dba orm.ORM) error {
+		claimedTables, err := appDB.getClaimedTables(dba)
+		if err != nil {
+			return err
+		}
+		return appDB.syncNamespaceClaims(dba, append(claimedTables, auxiliaryTables...))
+	})
+}
+
+//releaseTables Releases claims of the namespace of this Room on the given tables only
+func (appDB *Room) releaseTables(dba orm.ORM, tableNames []string) error {
+	if len(tableNames) < 1 || !isNamespaceRegistered(dba) {
+		return nil
+	}
+
+	claimedTables, err := appDB.getClaimedTables(dba)
+	if err != nil {
+		return err
+	}
+
+	released := make(map[string]bool)
+	for _, tableName := range tableNames {
+		released[tableName] = true
+	}
+	var keptTables []string
+	for _, tableName := range claimedTables {
+		if !released[tableName] {
+			keptTables = append(keptTables, tableName)
+		}
+	}
+	return appDB.syncNamespaceClaims(dba, keptTables)
+}
+
+//getClaimedTables Tables claimed by the namespace of this Room
+func (appDB *Room) getClaimedTables(dba orm.ORM) ([]string, error) {
+	if !dba.HasTable(GoRoomNamespaceTable{}) {
+		return nil, nil
+	}
+
+	claims, err := appDB.getNamespaceClaims(dba)
+	if err != nil {
+		return nil, err
+	}
+	var tableNames []string
+	for _, claim := range claims {
+		if claim.Namespace == appDB.namespace {
+			tableNames = append(tableNames, claim.EntityTable)
+		}
+	}
+	return tableNames, nil
+}
+
+//isNamespaceRegistered Whether the tables of a Room using the ORM are tracked in the namespace registry. Rooms of the default namespace
+//skip the registry if the ORM can not read it whereas other namespaces are refused such an ORM up front
+func isNamespaceRegistered(dba orm.ORM) bool {
+	_, ok := dba.(orm.RowLoader)
+	return ok
+}
+
+func getNamespaceLabel(namespace string) string {
+	if namespace == "" {
+		return defaultNamespaceLabel
+	}
+	return namespace
+}
+
+//syncNamespaceClaims Makes the given tables the only ones claimed by the namespace of this Room
+func (appDB *Room) syncNamespaceClaims(dba orm.ORM, tableNames []string) error {
+	if !dba.HasTable(GoRoomNamespaceTable{}) {
+		if len(tableNames) < 1 {
+			return nil
+		}
+		//Room of another namespace could have created it in the meanwhile
+		if err := dba.CreateTable(GoRoomNamespaceTable{}).Error; err != nil && !dba.HasTable(GoRoomNamespaceTable{}) {
+			return err
+		}
+	}
+
+	claims, err := appDB.getNamespaceClaims(dba)
+	if err != nil {
+		return err
+	}
+	if err = appDB.getNamespaceCollisionError(claims, tableNames); err != nil {
+		return err
+	}
+
+	claimed := make(map[string]bool)
+	for _, tableName := range tableNames {
+		claimed[tableName] = true
+	}
+
+	var kept, released []GoRoomNamespaceTable
+	owned := make(map[string]bool)
+	for _, claim := range claims {
+		if claim.Namespace == appDB.namespace && !claimed[claim.EntityTable] {
+			released = append(released, claim)
+		} else {
+			kept = append(kept, claim)
+			owned[claim.EntityTable] = true
+		}
+	}
+
+	//Registry has no delete hence it is rewritten without the released claims
+	if len(released) > 0 {
+		if err = dba.TruncateTable(GoRoomNamespaceTable{}).Error; err != nil {
+			return err
+		}
+		for i := range kept {
+			if err = dba.Create(&kept[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	sortedTableNames := append([]string{}, tableNames...)
+	sort.Strings(sortedTableNames)
+	for _, tableName := range sortedTableNames {
+		if owned[tableName] {
+			continue
+		}
+		claim := GoRoomNamespaceTable{
+			EntityTable: tableName,
+			Namespace:   appDB.namespace,
+		}
+		if err = dba.Create(&claim).Error; err != nil {
+			return err
+		}
+		//Kept tables can be entity tables too e.g. ones of the version being adopted
+		owned[tableName] = true
+	}
+
+	for _, claim := range released {
+		appDB.log().Infof("Released table %v from namespace %v", claim.EntityTable, getNamespaceLabel(appDB.namespace))
+	}
+	return nil
+}
+
+func (appDB *Room) getNamespaceClaims(dba orm.ORM) ([]GoRoomNamespaceTable, error) {
+	var claims []GoRoomNamespaceTable
+	if err := findAll(dba, GoRoomNamespaceTable{}, &claims); err != nil {
+		appDB.log().Errorf("Unable to read namespace registry. %v", err)
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (appDB *Room) getNamespaceCollisionError(claims []GoRoomNamespaceTable, tableNames []string) error {
+	owners := make(map[string]string)
+	for _, claim := range claims {
+		owners[claim.EntityTable] = claim.Namespace
+	}
+
+	sortedTableNames := append([]string{}, tableNames...)
+	sort.Strings(sortedTableNames)
+
+	var collisions []string
+	for _, tableName := range sortedTableNames {
+		if owner, ok := owners[tableName]; ok && owner != appDB.namespace {
+			collisions = append(collisions, fmt.Sprintf("%v(namespace %v)", tableName, getNamespaceLabel(owner)))
+		}
+	}
+
+	if len(collisions) > 0 {
+		appDB.log().Errorf("Table name collision detected for namespace %v", getNamespaceLabel(appDB.namespace))
+		return fmt.Errorf("Tables %v are already managed by other namespaces than %v", collisions, getNamespaceLabel(appDB.namespace))
+	}
+	return nil
+}
+
+//getEntityTableNames Tables of the entities of this Room sorted by name
+func (appDB *Room) getEntityTableNames() []string {
+	var tableNames []string
+	for _, model := range appDB.getSortedModelDefinitions() {
+		tableNames = append(tableNames, model.TableName)
+	}
+	return tableNames
+}
@@ -0,0 +1,262 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type NamespaceTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	Loader       *mocks.MockRowLoader
+	ORM          *RowLoadingORM
+	IdentityCalc *mocks.MockIdentityHashCalculator
+	AppDB        *Room
+}
+
+func (s *NamespaceTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.ORM = &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader}
+	s.IdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+
+	var errList []error
+	s.AppDB, errList = NewWithNamespace("billing", []interface{}{DummyTable{}, AnotherDummyTable{}}, s.ORM, 2, []orm.Migration{}, s.IdentityCalc)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{TableName: "dummy_tables"}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{TableName: "another_dummy_tables"}).AnyTimes()
+}
+
+func (s *NamespaceTestSuite) expectClaims(claims []GoRoomNamespaceTable) {
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(true)
+	s.Loader.EXPECT().FindAll(GoRoomNamespaceTable{}, gomock.AssignableToTypeOf(&[]GoRoomNamespaceTable{})).DoAndReturn(func(entity interface{}, out interface{}) orm.Result {
+		*out.(*[]GoRoomNamespaceTable) = claims
+		return orm.Result{}
+	})
+}
+
+func (s *NamespaceTestSuite) TestNewWithNamespace() {
+	assert.Equal(s.T(), "billing", s.AppDB.namespace)
+	assert.Equal(s.T(), "billing_go_room_schema_masters", s.AppDB.schemaMaster.TableName())
+
+	defaultDB, _ := New([]interface{}{DummyTable{}}, s.DBA, 2, []orm.Migration{}, s.IdentityCalc)
+	assert.Equal(s.T(), GoRoomSchemaMaster{}, defaultDB.schemaMaster)
+	assert.Equal(s.T(), "go_room_schema_masters", defaultDB.schemaMaster.TableName())
+}
+
+func (s *NamespaceTestSuite) TestNewDerivesSchemaMasterTableNameFromORM() {
+	dba := mocks.NewMockORM(s.MockCtrl)
+	dba.EXPECT().GetModelDefinition(legacySchemaMaster).Return(orm.ModelDefinition{TableName: "go_room_schema_master"})
+
+	singularDB, _ := New([]interface{}{DummyTable{}}, dba, 2, []orm.Migration{}, s.IdentityCalc)
+	assert.Equal(s.T(), "go_room_schema_master", singularDB.schemaMaster.TableName())
+}
+
+func (s *NamespaceTestSuite) TestNewWithInvalidNamespace() {
+	got, errList := NewWithNamespace("Billing-Module", []interface{}{DummyTable{}}, s.ORM, 2, []orm.Migration{}, s.IdentityCalc)
+
+	expectedError := fmt.Errorf("Namespace %v is invalid. Only lower case letters, digits and underscores are allowed", "Billing-Module")
+	assert.Nil(s.T(), got)
+	assert.Equal(s.T(), []error{expectedError}, errList)
+}
+
+func (s *NamespaceTestSuite) TestNewWithNamespaceWithoutRowLoader() {
+	got, errList := NewWithNamespace("billing", []interface{}{DummyTable{}}, s.DBA, 2, []orm.Migration{}, s.IdentityCalc)
+
+	expectedError := fmt.Errorf("Namespace %v needs an ORM implementing orm.RowLoader to register its tables", "billing")
+	assert.Nil(s.T(), got)
+	assert.Equal(s.T(), []error{expectedError}, errList)
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesOfDefaultNamespace() {
+	s.AppDB.namespace = ""
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "billing"},
+	})
+
+	expectedError := fmt.Errorf("Tables %v are already managed by other namespaces than %v", []string{"dummy_tables(namespace billing)"}, "<default>")
+	assert.Equal(s.T(), expectedError, s.AppDB.checkNamespaceCollisions(), "Rooms without namespace register their tables too")
+
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "another_dummy_tables", Namespace: ""},
+	})
+	s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables", Namespace: ""}).Return(orm.Result{})
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesOfDefaultNamespaceWithoutRowLoader() {
+	s.AppDB.namespace = ""
+	s.AppDB.dba = s.DBA
+
+	assert.Nil(s.T(), s.AppDB.checkNamespaceCollisions(), "Registry is skipped if the ORM can not read it")
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.DBA, nil))
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesCollidingWithDefaultNamespace() {
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: ""},
+	})
+
+	expectedError := fmt.Errorf("Tables %v are already managed by other namespaces than %v", []string{"dummy_tables(namespace <default>)"}, "billing")
+	assert.Equal(s.T(), expectedError, s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestCheckNamespaceCollisionsWithoutRegistry() {
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false)
+
+	assert.Nil(s.T(), s.AppDB.checkNamespaceCollisions(), "Registry should not be created outside the Init transaction")
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTables() {
+	gomock.InOrder(
+		s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false),
+		s.DBA.EXPECT().CreateTable(GoRoomNamespaceTable{}).Return(orm.Result{}),
+	)
+	s.Loader.EXPECT().FindAll(GoRoomNamespaceTable{}, gomock.Any()).Return(orm.Result{})
+	gomock.InOrder(
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "another_dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesAlreadyClaimed() {
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "another_dummy_tables", Namespace: "billing"},
+		{EntityTable: "unrelated_tables", Namespace: "uploads"},
+	})
+	s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables", Namespace: "billing"}).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesAlsoKept() {
+	s.expectClaims(nil)
+	gomock.InOrder(
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "another_dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.ORM, []string{"dummy_tables"}), "Table should be claimed once")
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesReleasesOtherTables() {
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "billing"},
+		{EntityTable: "invoices", Namespace: "billing"},
+		{EntityTable: "unrelated_tables", Namespace: "uploads"},
+	})
+	gomock.InOrder(
+		s.DBA.EXPECT().TruncateTable(GoRoomNamespaceTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "unrelated_tables", Namespace: "uploads"}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "another_dummy_tables", Namespace: "billing"}).Return(orm.Result{}),
+	)
+
+	assert.Nil(s.T(), s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestClaimAuxiliaryTables() {
+	migration := mocks.NewMockAuxiliaryTableMigration(s.MockCtrl)
+	migration.EXPECT().GetAuxiliaryTables().Return([]string{"go_room_batch_cursors"})
+	claims := []GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "billing"},
+		{EntityTable: "invoices", Namespace: "billing"},
+		{EntityTable: "unrelated_tables", Namespace: "uploads"},
+	}
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	s.expectClaims(claims)
+	s.expectClaims(claims)
+	s.DBA.EXPECT().Create(&GoRoomNamespaceTable{EntityTable: "go_room_batch_cursors", Namespace: "billing"}).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.claimAuxiliaryTables([]orm.Migration{migration}), "Tables claimed already should stay claimed")
+}
+
+func (s *NamespaceTestSuite) TestClaimAuxiliaryTablesWithCollision() {
+	migration := mocks.NewMockAuxiliaryTableMigration(s.MockCtrl)
+	migration.EXPECT().GetAuxiliaryTables().Return([]string{"go_room_batch_cursors"})
+	claims := []GoRoomNamespaceTable{
+		{EntityTable: "go_room_batch_cursors", Namespace: "uploads"},
+	}
+
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	})
+	s.expectClaims(claims)
+	s.expectClaims(claims)
+
+	assert.Equal(s.T(), fmt.Errorf("Tables [go_room_batch_cursors(namespace uploads)] are already managed by other namespaces than billing"),
+		s.AppDB.claimAuxiliaryTables([]orm.Migration{migration}))
+}
+
+func (s *NamespaceTestSuite) TestClaimAuxiliaryTablesWithoutAuxiliaryTables() {
+	assert.Nil(s.T(), s.AppDB.claimAuxiliaryTables([]orm.Migration{mocks.NewMockMigration(s.MockCtrl)}))
+}
+
+func (s *NamespaceTestSuite) TestClaimEntityTablesWithCollision() {
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "uploads"},
+	})
+
+	expectedError := fmt.Errorf("Tables %v are already managed by other namespaces than %v", []string{"dummy_tables(namespace uploads)"}, "billing")
+	assert.Equal(s.T(), expectedError, s.AppDB.claimEntityTables(s.ORM, nil))
+}
+
+func (s *NamespaceTestSuite) TestInitWithCollision() {
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "uploads"},
+	})
+
+	shouldRetry, err := s.AppDB.Init("asasaasa")
+	assert.False(s.T(), shouldRetry, "Destruction must not be suggested as the table belongs to another namespace")
+	assert.NotNil(s.T(), err)
+}
+
+func (s *NamespaceTestSuite) TestInitUsesNamespacedSchemaMaster() {
+	identityHash := "asasaasa"
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "billing"},
+		{EntityTable: "another_dummy_tables", Namespace: "billing"},
+	})
+	s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true)
+	s.Loader.EXPECT().FindAll(s.AppDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{IdentityHash: identityHash, Version: 2}}).Return(orm.Result{})
+
+	shouldRetry, err := s.AppDB.Init(identityHash)
+	assert.True(s.T(), !shouldRetry && err == nil, "No error expected. %v", err)
+}
+
+func (s *NamespaceTestSuite) TestPerformDBCleanUpIsScopedToNamespace() {
+	gomock.InOrder(
+		s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+			return fc(s.ORM)
+		}),
+	)
+	gomock.InOrder(
+		s.DBA.EXPECT().HasTable(DummyTable{}).Return(true),
+		s.DBA.EXPECT().DropTable(DummyTable{}).Return(orm.Result{}),
+		s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(false),
+		s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true),
+		s.DBA.EXPECT().DropTable(s.AppDB.schemaMaster).Return(orm.Result{}),
+	)
+	s.expectClaims([]GoRoomNamespaceTable{
+		{EntityTable: "dummy_tables", Namespace: "billing"},
+		{EntityTable: "another_dummy_tables", Namespace: "billing"},
+	})
+	s.DBA.EXPECT().TruncateTable(GoRoomNamespaceTable{}).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.PerformDBCleanUp())
+}
@@ -0,0 +1,87 @@
+package room
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//GetOrphanedTables Tables found orphaned during the last Init of this Room
+func (appDB *Room) GetOrphanedTables() []string {
+	return appDB.lastOrphanedTables
+}
+
+//handleOrphanedTables Applies the orphaned table policy and returns the orphaned tables that are left in the DB
+func (appDB *Room) handleOrphanedTables(dba orm.ORM, entityHashes map[string]string) ([]string, error) {
+	if appDB.orphanedTables == IgnoreOrphanedTables {
+		return nil, nil
+	}
+
+	record, err := appDB.getLatestRoomRecord(dba)
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedTables, err := appDB.getOrphanedTables(dba, record, entityHashes)
+	if err != nil {
+		return nil, err
+	}
+	appDB.lastOrphanedTables = orphanedTables
+	if len(orphanedTables) < 1 {
+		return nil, nil
+	}
+
+	switch appDB.orphanedTables {
+	case ArchiveOrphanedTables:
+		for _, tableName := range orphanedTables {
+			archiveName := fmt.Sprintf("%v_archived_v%v", tableName, record.Version)
+			if err := renameTable(dba, tableName, archiveName); err != nil {
+				return nil, err
+			}
+			appDB.log().Infof("Archived orphaned table %v as %v", tableName, archiveName)
+		}
+		return nil, nil
+	case DropOrphanedTables:
+		for _, tableName := range orphanedTables {
+			if err := dba.DropTable(tableName).Error; err != nil {
+				return nil, err
+			}
+			appDB.log().Infof("Dropped orphaned table %v", tableName)
+		}
+		return nil, nil
+	default:
+		appDB.log().Warnf("Tables %v are no longer among the entities of version %v. Drop them in a migration or configure an orphaned table policy", orphanedTables, appDB.version)
+		return orphanedTables, nil
+	}
+}
+
+//getOrphanedTables Tables recorded against the given schema master record that are no longer entities but still exist in the DB
+func (appDB *Room) getOrphanedTables(dba orm.ORM, record *GoRoomSchemaMaster, entityHashes map[string]string) ([]string, error) {
+	storedHashes, err := record.GetEntityHashes()
+	if err != nil {
+		return nil, err
+	}
+	previouslyOrphaned, err := record.GetOrphanedTables()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]bool)
+	for tableName := range storedHashes {
+		candidates[tableName] = true
+	}
+	for _, tableName := range previouslyOrphaned {
+		candidates[tableName] = true
+	}
+
+	var orphanedTables []string
+	for tableName := range candidates {
+		if _, ok := entityHashes[tableName]; !ok && dba.HasTable(tableName) {
+			orphanedTables = append(orphanedTables, tableName)
+		}
+	}
+	sort.Strings(orphanedTables)
+
+	return orphanedTables, nil
+}
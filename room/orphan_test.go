@@ -0,0 +1,134 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type OrphanedTablesTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	Loader       *mocks.MockRowLoader
+	Migrator     *mocks.MockAutoMigrator
+	Renamer      *mocks.MockTableRenamer
+	ORM          *RowLoadingORM
+	EntityHashes map[string]string
+}
+
+func (s *OrphanedTablesTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.Migrator = mocks.NewMockAutoMigrator(s.MockCtrl)
+	s.Renamer = mocks.NewMockTableRenamer(s.MockCtrl)
+	s.ORM = &RowLoadingORM{s.DBA, s.Loader, s.Migrator, s.Renamer}
+	s.EntityHashes = map[string]string{"dummy_tables": "d1"}
+
+	s.Loader.EXPECT().FindAll(GoRoomSchemaMaster{}, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{
+		Version:        2,
+		EntityHashes:   `{"another_dummy_tables":"a1","dummy_tables":"d1","removed_tables":"r1"}`,
+		OrphanedTables: `["legacy_tables","dropped_by_hand"]`,
+	}}).Return(orm.Result{}).AnyTimes()
+	s.DBA.EXPECT().HasTable("another_dummy_tables").Return(true).AnyTimes()
+	s.DBA.EXPECT().HasTable("legacy_tables").Return(true).AnyTimes()
+	s.DBA.EXPECT().HasTable("removed_tables").Return(false).AnyTimes()
+	s.DBA.EXPECT().HasTable("dropped_by_hand").Return(false).AnyTimes()
+}
+
+func (s *OrphanedTablesTestSuite) getRoom(policy OrphanedTablePolicy) *Room {
+	return &Room{
+		version:        3,
+		dba:            s.ORM,
+		orphanedTables: policy,
+	}
+}
+
+func (s *OrphanedTablesTestSuite) TestIgnoreOrphanedTables() {
+	s.DBA = newMockORM(s.MockCtrl)
+	appDB := s.getRoom(IgnoreOrphanedTables)
+
+	remaining, err := appDB.handleOrphanedTables(s.DBA, s.EntityHashes)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), remaining)
+	assert.Nil(s.T(), appDB.GetOrphanedTables(), "Schema master should not even be read when orphaned tables are ignored")
+}
+
+func (s *OrphanedTablesTestSuite) TestReportOrphanedTables() {
+	appDB := s.getRoom(ReportOrphanedTables)
+
+	remaining, err := appDB.handleOrphanedTables(s.ORM, s.EntityHashes)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"another_dummy_tables", "legacy_tables"}, remaining, "Reported tables should be carried forward")
+	assert.Equal(s.T(), []string{"another_dummy_tables", "legacy_tables"}, appDB.GetOrphanedTables())
+}
+
+func (s *OrphanedTablesTestSuite) TestArchiveOrphanedTables() {
+	appDB := s.getRoom(ArchiveOrphanedTables)
+
+	gomock.InOrder(
+		s.Renamer.EXPECT().RenameTable("another_dummy_tables", "another_dummy_tables_archived_v2").Return(orm.Result{}),
+		s.Renamer.EXPECT().RenameTable("legacy_tables", "legacy_tables_archived_v2").Return(orm.Result{}),
+	)
+
+	remaining, err := appDB.handleOrphanedTables(s.ORM, s.EntityHashes)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), remaining)
+	assert.Equal(s.T(), []string{"another_dummy_tables", "legacy_tables"}, appDB.GetOrphanedTables())
+}
+
+func (s *OrphanedTablesTestSuite) TestArchiveOrphanedTablesWithoutTableRenamer() {
+	appDB := s.getRoom(ArchiveOrphanedTables)
+	dba := &struct {
+		*mocks.MockORM
+		*mocks.MockRowLoader
+	}{s.DBA, s.Loader}
+
+	remaining, err := appDB.handleOrphanedTables(dba, s.EntityHashes)
+	assert.Equal(s.T(), fmt.Errorf("ORM %T can not rename table %v. It should implement orm.TableRenamer", dba, "another_dummy_tables"), err)
+	assert.Nil(s.T(), remaining)
+}
+
+func (s *OrphanedTablesTestSuite) TestDropOrphanedTables() {
+	appDB := s.getRoom(DropOrphanedTables)
+
+	expectedError := fmt.Errorf("Table is locked")
+	gomock.InOrder(
+		s.DBA.EXPECT().DropTable("another_dummy_tables").Return(orm.Result{}),
+		s.DBA.EXPECT().DropTable("legacy_tables").Return(orm.Result{Error: expectedError}),
+	)
+
+	remaining, err := appDB.handleOrphanedTables(s.ORM, s.EntityHashes)
+	assert.Equal(s.T(), expectedError, err)
+	assert.Nil(s.T(), remaining)
+}
+
+func (s *OrphanedTablesTestSuite) TestMigrationRecordsReportedOrphanedTables() {
+	appDB := s.getRoom(ReportOrphanedTables)
+
+	var dummyORM interface{}
+	s.DBA.EXPECT().GetUnderlyingORM().Return(dummyORM).AnyTimes()
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(true)
+	s.Loader.EXPECT().FindAll(GoRoomNamespaceTable{}, gomock.Any()).SetArg(1, []GoRoomNamespaceTable{
+		{EntityTable: "another_dummy_tables"},
+		{EntityTable: "legacy_tables"},
+	}).Return(orm.Result{})
+	gomock.InOrder(
+		s.Migrator.EXPECT().AutoMigrate(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().TruncateTable(GoRoomSchemaMaster{}).Return(orm.Result{}),
+		s.DBA.EXPECT().Create(&GoRoomSchemaMaster{
+			Version:        3,
+			IdentityHash:   "asasasa",
+			EntityHashes:   `{"dummy_tables":"d1"}`,
+			OrphanedTables: `["another_dummy_tables","legacy_tables"]`,
+		}).Return(orm.Result{}),
+	)
+
+	migrationFunc := appDB.getMigrationTransactionFunction("asasasa", s.EntityHashes, []orm.Migration{})
+	assert.Nil(s.T(), migrationFunc(s.ORM))
+}
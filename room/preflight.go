@@ -0,0 +1,36 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//PreflightError Returned by Init when a preflight check fails. Migrations are not attempted and the DB is left as is
+type PreflightError struct {
+	Check string //Name of the failing check
+	From  orm.VersionNumber
+	To    orm.VersionNumber
+	Err   error
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("Preflight check %v failed for migration from %v to %v. %v", e.Check, e.From, e.To, e.Err)
+}
+
+//Unwrap Gives the error returned by the check
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+//runPreflightChecks Runs the configured checks in order stopping at the first one that fails
+func (appDB *Room) runPreflightChecks(from orm.VersionNumber, migrations []orm.Migration) error {
+	for _, check := range appDB.preflightChecks {
+		if err := check.Check(appDB.dba, from, appDB.version, migrations); err != nil {
+			preflightErr := &PreflightError{Check: check.GetName(), From: from, To: appDB.version, Err: err}
+			appDB.log().Errorf("Migration aborted. %v", preflightErr)
+			return preflightErr
+		}
+	}
+	return nil
+}
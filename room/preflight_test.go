@@ -0,0 +1,92 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PreflightTestSuite struct {
+	suite.Suite
+	MockCtrl *gomock.Controller
+	DBA      *mocks.MockORM
+	Loader   *mocks.MockRowLoader
+	ORM      *RowLoadingORM
+	Config   Config
+}
+
+func (s *PreflightTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.ORM = &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader}
+	identityCalc := mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	s.Config = Config{
+		Entities:              []interface{}{DummyTable{}},
+		DBA:                   s.ORM,
+		Version:               3,
+		IdentityCalculator:    identityCalc,
+		SchemaMasterTableName: "app_metadata",
+		Logger:                &RecordingLogger{},
+		DestructiveFallback:   DestructiveFallbackToCleanDB,
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName:   "dummy_tables",
+		EntityModel: DummyTable{},
+	}).AnyTimes()
+	s.DBA.EXPECT().HasTable(GoRoomNamespaceTable{}).Return(false).AnyTimes()
+	identityCalc.EXPECT().ConstructHash(gomock.Any()).Return("asasasa", nil).AnyTimes()
+}
+
+func (s *PreflightTestSuite) TestInitWithFailingPreflightCheck() {
+	hookCalled := false
+	s.Config.Hooks.BeforeMigration = func(from orm.VersionNumber, to orm.VersionNumber) error {
+		hookCalled = true
+		return nil
+	}
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	s.Config.Migrations = []orm.Migration{migration}
+	passingCheck := mocks.NewMockPreflightCheck(s.MockCtrl)
+	failingCheck := mocks.NewMockPreflightCheck(s.MockCtrl)
+	skippedCheck := mocks.NewMockPreflightCheck(s.MockCtrl)
+	s.Config.PreflightChecks = []orm.PreflightCheck{passingCheck, failingCheck, skippedCheck}
+	appDB, _ := NewFromConfig(s.Config)
+
+	checkError := fmt.Errorf("Not enough space")
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.Loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{IdentityHash: "old", Version: 2}}).Return(orm.Result{})
+	passingCheck.EXPECT().Check(s.ORM, orm.VersionNumber(2), orm.VersionNumber(3), []orm.Migration{migration}).Return(nil)
+	failingCheck.EXPECT().Check(s.ORM, orm.VersionNumber(2), orm.VersionNumber(3), []orm.Migration{migration}).Return(checkError)
+	failingCheck.EXPECT().GetName().Return("disk-space")
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.False(s.T(), shouldRetry, "Failed preflight check must not lead to destruction")
+	assert.Equal(s.T(), &PreflightError{Check: "disk-space", From: 2, To: 3, Err: checkError}, err)
+	assert.Equal(s.T(), "Preflight check disk-space failed for migration from 2 to 3. Not enough space", err.Error())
+	assert.False(s.T(), hookCalled)
+}
+
+func (s *PreflightTestSuite) TestInitWithPassingPreflightCheck() {
+	migration := mocks.NewMockMigration(s.MockCtrl)
+	migration.EXPECT().GetBaseVersion().Return(orm.VersionNumber(2)).AnyTimes()
+	migration.EXPECT().GetTargetVersion().Return(orm.VersionNumber(3)).AnyTimes()
+	s.Config.Migrations = []orm.Migration{migration}
+	check := mocks.NewMockPreflightCheck(s.MockCtrl)
+	s.Config.PreflightChecks = []orm.PreflightCheck{check}
+	appDB, _ := NewFromConfig(s.Config)
+
+	s.DBA.EXPECT().HasTable(appDB.schemaMaster).Return(true)
+	s.Loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{IdentityHash: "old", Version: 2}}).Return(orm.Result{})
+	check.EXPECT().Check(s.ORM, orm.VersionNumber(2), orm.VersionNumber(3), []orm.Migration{migration}).Return(nil)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).Return(nil)
+
+	shouldRetry, err := appDB.Init("asasasa")
+	assert.True(s.T(), !shouldRetry && err == nil)
+}
@@ -0,0 +1,124 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//Compatibility Compatibility of a DB with the entities and version of the app opening it
+type Compatibility int
+
+const (
+	//Compatible DB is at the version of the app and its identity hash matches the entities
+	Compatible Compatibility = iota
+	//NeedsMigration DB is at an older version than the app
+	NeedsMigration
+	//NewerThanApp DB was migrated by a newer version of the app
+	NewerThanApp
+	//Corrupt DB is at the version of the app but its identity hash differs or its metadata can not be read
+	Corrupt
+	//NotCreated DB has no Room Schema Master yet
+	NotCreated
+	//NewerCompatible DB was migrated by a newer version of the app which declared this version compatible
+	NewerCompatible
+)
+
+func (compatibility Compatibility) String() string {
+	switch compatibility {
+	case Compatible:
+		return "compatible"
+	case NeedsMigration:
+		return "needs-migration"
+	case NewerThanApp:
+		return "newer-than-app"
+	case Corrupt:
+		return "corrupt"
+	case NotCreated:
+		return "not-created"
+	case NewerCompatible:
+		return "newer-compatible"
+	default:
+		return fmt.Sprintf("compatibility(%d)", int(compatibility))
+	}
+}
+
+//CompatibilityResult Outcome of comparing the DB with the entities of a Room
+type CompatibilityResult struct {
+	Compatibility Compatibility
+	DBVersion     orm.VersionNumber //Version recorded in the Schema Master. Zero if it is not created
+	AppVersion    orm.VersionNumber
+}
+
+//IsReadOnly Whether this Room only verifies the DB and never modifies it
+func (appDB *Room) IsReadOnly() bool {
+	return appDB.readOnly
+}
+
+//CheckCompatibility Compares the version and identity hash recorded in the DB with the entities of this Room.
+//Only reads the Schema Master hence it is safe to call from processes which must never modify the DB
+func (appDB *Room) CheckCompatibility(currentIdentityHash string) (result CompatibilityResult, err error) {
+	result.AppVersion = appDB.version
+
+	if !appDB.isSchemaMasterPresent() {
+		result.Compatibility = NotCreated
+		return result, nil
+	}
+
+	if err = appDB.verifySchemaMasterIntegrity(); err != nil {
+		result.Compatibility = Corrupt
+		return result, err
+	}
+
+	roomMetadata, err := appDB.getRoomMetadataFromDB()
+	if err != nil {
+		result.Compatibility = Corrupt
+		return result, err
+	}
+	result.DBVersion = roomMetadata.Version
+
+	switch {
+	case roomMetadata.Version < appDB.version:
+		result.Compatibility = NeedsMigration
+	case roomMetadata.Version > appDB.version && appDB.isCompatibleNewerDB():
+		result.Compatibility = NewerCompatible
+	case roomMetadata.Version > appDB.version:
+		result.Compatibility = NewerThanApp
+	case roomMetadata.IdentityHash != currentIdentityHash:
+		result.Compatibility = Corrupt
+	default:
+		result.Compatibility = Compatible
+	}
+
+	return result, nil
+}
+
+//verifyReadOnly Init of a read only Room. Fails unless the DB is compatible without suggesting destruction
+func (appDB *Room) verifyReadOnly(currentIdentityHash string) error {
+	appDB.lastResult.Scenario = ScenarioReadOnly
+
+	result, err := appDB.CheckCompatibility(currentIdentityHash)
+	appDB.lastResult.PreviousVersion = result.DBVersion
+	appDB.lastResult.Version = result.DBVersion
+	if err != nil {
+		return err
+	}
+
+	if result.Compatibility != Compatible && result.Compatibility != NewerCompatible {
+		appDB.log().Errorf("Room opened read only can not use the DB as it is %v", result.Compatibility)
+		return fmt.Errorf("Database is not compatible(%v). Version %v found while version %v opened read only", result.Compatibility, result.DBVersion, result.AppVersion)
+	}
+
+	return nil
+}
+
+//isCompatibleNewerDB Whether the version that created or migrated the DB declared the version of this Room compatible
+func (appDB *Room) isCompatibleNewerDB() bool {
+	record, err := appDB.getLatestRoomRecordFromDB()
+	if err != nil {
+		appDB.log().Warnf("Unable to read the compatible versions of the DB. %v", err)
+		return false
+	}
+
+	return record.MinCompatibleVersion > 0 && record.MinCompatibleVersion <= appDB.version
+}
@@ -0,0 +1,116 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReadOnlyTestSuite struct {
+	suite.Suite
+	MockCtrl *gomock.Controller
+	DBA      *mocks.MockORM
+	Loader   *mocks.MockRowLoader
+	AppDB    *Room
+}
+
+func (s *ReadOnlyTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+
+	//Locker and ORM mocks fail the test on any call that is not expected i.e. locking or modifying the DB
+	appDB, errList := NewFromConfig(Config{
+		Entities:           []interface{}{DummyTable{}},
+		DBA:                &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader},
+		Version:            3,
+		IdentityCalculator: mocks.NewMockIdentityHashCalculator(s.MockCtrl),
+		Locker:             mocks.NewMockLocker(s.MockCtrl),
+		Logger:             &RecordingLogger{},
+		ReadOnly:           true,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	s.AppDB = appDB
+}
+
+func (s *ReadOnlyTestSuite) TestCheckCompatibility() {
+	testCases := []struct {
+		storedHash           string
+		storedVersion        int
+		minCompatibleVersion orm.VersionNumber
+		expected             Compatibility
+	}{
+		{"asasasa", 3, 0, Compatible},
+		{"oldhash", 2, 0, NeedsMigration},
+		{"newhash", 4, 0, NewerThanApp},
+		{"newhash", 5, 4, NewerThanApp},
+		{"newhash", 5, 3, NewerCompatible},
+		{"changed", 3, 0, Corrupt},
+	}
+
+	for _, testCase := range testCases {
+		s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true)
+		s.DBA.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return(testCase.storedHash, testCase.storedVersion, nil)
+		if testCase.storedVersion > 3 {
+			s.Loader.EXPECT().FindAll(s.AppDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{{
+				Version:              orm.VersionNumber(testCase.storedVersion),
+				IdentityHash:         testCase.storedHash,
+				MinCompatibleVersion: testCase.minCompatibleVersion,
+			}}).Return(orm.Result{})
+		}
+
+		result, err := s.AppDB.CheckCompatibility("asasasa")
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), CompatibilityResult{
+			Compatibility: testCase.expected,
+			DBVersion:     orm.VersionNumber(testCase.storedVersion),
+			AppVersion:    3,
+		}, result)
+	}
+}
+
+func (s *ReadOnlyTestSuite) TestCheckCompatibilityWithoutSchemaMaster() {
+	s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(false)
+
+	result, err := s.AppDB.CheckCompatibility("asasasa")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), NotCreated, result.Compatibility)
+}
+
+func (s *ReadOnlyTestSuite) TestCheckCompatibilityWithUnreadableMetadata() {
+	readError := fmt.Errorf("Malformed schema master")
+	s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true)
+	s.DBA.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return("", 0, readError)
+
+	result, err := s.AppDB.CheckCompatibility("asasasa")
+	assert.Equal(s.T(), readError, err)
+	assert.Equal(s.T(), Corrupt, result.Compatibility)
+}
+
+func (s *ReadOnlyTestSuite) TestInit() {
+	s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true)
+	s.DBA.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return("asasasa", 3, nil)
+
+	shouldRetry, err := s.AppDB.Init("asasasa")
+	assert.True(s.T(), !shouldRetry && err == nil)
+	assert.Equal(s.T(), &InitResult{Scenario: ScenarioReadOnly, PreviousVersion: 3, Version: 3}, s.AppDB.GetLastResult())
+}
+
+func (s *ReadOnlyTestSuite) TestInitWithOlderDB() {
+	s.DBA.EXPECT().HasTable(s.AppDB.schemaMaster).Return(true)
+	s.DBA.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return("oldhash", 2, nil)
+
+	shouldRetry, err := s.AppDB.Init("asasasa")
+	assert.False(s.T(), shouldRetry, "Read only Room must never suggest destruction")
+	assert.Equal(s.T(), fmt.Errorf("Database is not compatible(needs-migration). Version 2 found while version 3 opened read only"), err)
+}
+
+func (s *ReadOnlyTestSuite) TestPerformDBCleanUp() {
+	assert.Equal(s.T(), fmt.Errorf("Room opened read only. Clean up of the DB is not allowed"), s.AppDB.PerformDBCleanUp())
+}
@@ -0,0 +1,235 @@
+package room
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//recoverMissingSchemaMaster Follows the missing schema master policy when entity tables already exist.
+//Returns true if a version was adopted, in which case Init proceeds as if the schema master was found
+func (appDB *Room) recoverMissingSchemaMaster() (adopted bool, err error) {
+	if appDB.missingSchemaMaster == StampCurrentVersion {
+		return false, nil
+	}
+
+	var existingTables []string
+	for tableName, entity := range appDB.getEntitiesByTableName() {
+		if appDB.dba.HasTable(entity) {
+			existingTables = append(existingTables, tableName)
+		}
+	}
+	if len(existingTables) < 1 {
+		return false, nil
+	}
+	sort.Strings(existingTables)
+
+	if appDB.missingSchemaMaster == RefuseExistingTables {
+		appDB.log().Errorf("Entity tables %v exist without a Room Schema Master", existingTables)
+		return false, fmt.Errorf("Entity tables %v exist without a Room Schema Master. Refusing to assume their version", existingTables)
+	}
+
+	snapshot, err := appDB.detectSchemaVersion()
+	if err != nil {
+		return false, err
+	}
+	if snapshot.Version == appDB.version {
+		appDB.log().Infof("Existing entity tables match version %v", appDB.version)
+		return false, nil
+	}
+
+	appDB.log().Warnf("Room Schema Master missing. Existing entity tables match version %v. Adopting it to migrate to version %v", snapshot.Version, appDB.version)
+	if err = appDB.doInTransaction(appDB.getVersionAdoptionFunction(snapshot)); err != nil {
+		appDB.log().Errorf("Unable to adopt version %v. %v", snapshot.Version, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+//detectSchemaVersion Finds the version whose snapshot matches the tables in the DB exactly. Snapshot of current version is considered
+//along with the configured snapshots of earlier versions. Tables matching more than one version are refused as their version is ambiguous
+func (appDB *Room) detectSchemaVersion() (*SchemaSnapshot, error) {
+	introspector, ok := appDB.dba.(orm.SchemaIntrospector)
+	if !ok {
+		return nil, fmt.Errorf("ORM can not introspect the schema of existing entity tables to detect their version")
+	}
+
+	current, err := appDB.GetSchemaSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []*SchemaSnapshot{current}
+	for _, snapshot := range appDB.schemaSnapshots {
+		//Snapshot stored for current version is superseded by the one of the entities
+		if snapshot.Version != current.Version {
+			candidates = append(candidates, snapshot)
+		}
+	}
+
+	knownTables := make(map[string]*existingTable)
+	for _, snapshot := range candidates {
+		for _, entity := range snapshot.Entities {
+			knownTables[entity.TableName] = nil
+		}
+	}
+	for tableName := range knownTables {
+		table, err := getExistingTable(introspector, tableName)
+		if err != nil {
+			return nil, err
+		}
+		knownTables[tableName] = table
+	}
+
+	var matching []*SchemaSnapshot
+	for _, snapshot := range candidates {
+		if isSnapshotMatching(snapshot, knownTables) {
+			matching = append(matching, snapshot)
+		}
+	}
+	if len(matching) < 1 {
+		appDB.log().Errorf("Existing entity tables match none of the schema snapshots")
+		return nil, fmt.Errorf("Entity tables exist without a Room Schema Master and match no known version")
+	}
+	if len(matching) > 1 {
+		var versions []orm.VersionNumber
+		for _, snapshot := range matching {
+			versions = append(versions, snapshot.Version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] < versions[j]
+		})
+		appDB.log().Errorf("Existing entity tables match schema snapshots of versions %v", versions)
+		return nil, fmt.Errorf("Entity tables exist without a Room Schema Master and match versions %v. Unable to tell which one they are at", versions)
+	}
+
+	return matching[0], nil
+}
+
+//existingTable Schema of a table as found in the DB. It has no columns if the table does not exist
+type existingTable struct {
+	columns     []orm.ColumnInfo
+	indexes     []orm.IndexDefinition
+	foreignKeys []orm.ForeignKeyDefinition
+}
+
+func getExistingTable(introspector orm.SchemaIntrospector, tableName string) (*existingTable, error) {
+	table := &existingTable{}
+	var err error
+	if table.columns, err = introspector.GetColumns(tableName); err != nil || len(table.columns) < 1 {
+		return table, err
+	}
+	if table.indexes, err = introspector.GetIndexes(tableName); err != nil {
+		return nil, err
+	}
+	if table.foreignKeys, err = introspector.GetForeignKeys(tableName); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func (table *existingTable) getColumnNames() []string {
+	names := make([]string, 0, len(table.columns))
+	for _, column := range table.columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+//isSnapshotMatching Whether tables of the snapshot match the ones in the DB and no other known table exists
+func isSnapshotMatching(snapshot *SchemaSnapshot, tables map[string]*existingTable) bool {
+	snapshotTables := make(map[string]bool)
+	for _, entity := range snapshot.Entities {
+		if !isTableMatching(entity, tables[entity.TableName]) {
+			return false
+		}
+		snapshotTables[entity.TableName] = true
+	}
+
+	for tableName, table := range tables {
+		if !snapshotTables[tableName] && table != nil && len(table.columns) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+//isTableMatching Whether the table exists with exactly the columns of the entity and, if the snapshot records them,
+//with the same column types, indexes and foreign keys
+func isTableMatching(entity EntitySnapshot, table *existingTable) bool {
+	if table == nil || len(entity.Columns) < 1 || !isSameSet(entity.Columns, table.getColumnNames()) {
+		return false
+	}
+	if entity.Table == nil {
+		return true
+	}
+
+	for _, column := range table.columns {
+		if declaredType, ok := entity.Table.ColumnTypes[column.Name]; ok && !strings.EqualFold(declaredType, column.Type) {
+			return false
+		}
+	}
+
+	var expectedIndexes, actualIndexes []string
+	for _, index := range entity.Table.Indexes {
+		expectedIndexes = append(expectedIndexes, fmt.Sprintf("%v(unique=%v)%v", index.Name, index.Unique, index.Columns))
+	}
+	for _, index := range table.indexes {
+		actualIndexes = append(actualIndexes, fmt.Sprintf("%v(unique=%v)%v", index.Name, index.Unique, index.Columns))
+	}
+
+	var expectedForeignKeys, actualForeignKeys []string
+	for _, foreignKey := range entity.Table.ForeignKeys {
+		expectedForeignKeys = append(expectedForeignKeys, fmt.Sprintf("%v->%v%v", foreignKey.Columns, foreignKey.ReferencedTable, foreignKey.ReferencedColumns))
+	}
+	for _, foreignKey := range table.foreignKeys {
+		actualForeignKeys = append(actualForeignKeys, fmt.Sprintf("%v->%v%v", foreignKey.Columns, foreignKey.ReferencedTable, foreignKey.ReferencedColumns))
+	}
+
+	return isSameSet(expectedIndexes, actualIndexes) && isSameSet(expectedForeignKeys, actualForeignKeys)
+}
+
+func isSameSet(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	members := make(map[string]bool)
+	for _, member := range actual {
+		members[member] = true
+	}
+	for _, member := range expected {
+		if !members[member] {
+			return false
+		}
+	}
+
+	return true
+}
+
+//getVersionAdoptionFunction Creates the schema master recording the version of the given snapshot
+func (appDB *Room) getVersionAdoptionFunction(snapshot *SchemaSnapshot) func(orm.ORM) error {
+
+	return func(dba orm.ORM) error {
+		if err := dba.CreateTable(appDB.schemaMaster).Error; err != nil {
+			return err
+		}
+
+		entityHashes := make(map[string]string)
+		var tableNames []string
+		for _, entity := range snapshot.Entities {
+			entityHashes[entity.TableName] = entity.IdentityHash
+			tableNames = append(tableNames, entity.TableName)
+		}
+
+		if err := appDB.claimEntityTables(dba, tableNames); err != nil {
+			return err
+		}
+
+		metadata := appDB.sign(appDB.schemaMaster.withRecord(snapshot.Version, snapshot.IdentityHash).withEntityHashes(entityHashes))
+		return dba.Create(&metadata).Error
+	}
+}
@@ -0,0 +1,211 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type IntrospectableORM struct {
+	*mocks.MockORM
+	*mocks.MockSchemaIntrospector
+}
+
+func getColumnInfos(names []string) []orm.ColumnInfo {
+	var columns []orm.ColumnInfo
+	for _, name := range names {
+		columns = append(columns, orm.ColumnInfo{Name: name})
+	}
+	return columns
+}
+
+type RecoveryTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	Introspector *mocks.MockSchemaIntrospector
+	IdentityCalc *mocks.MockIdentityHashCalculator
+	AppDB        *Room
+}
+
+func (s *RecoveryTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Introspector = mocks.NewMockSchemaIntrospector(s.MockCtrl)
+	s.IdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	s.AppDB = &Room{
+		entities:            []interface{}{DummyTable{}, AnotherDummyTable{}},
+		version:             3,
+		dba:                 &IntrospectableORM{s.DBA, s.Introspector},
+		identityCalculator:  s.IdentityCalc,
+		logger:              &RecordingLogger{},
+		missingSchemaMaster: DetectVersionFromSnapshots,
+		schemaSnapshots: []*SchemaSnapshot{
+			{Version: 1, IdentityHash: "v1", Entities: []EntitySnapshot{
+				{TableName: "dummy_tables", IdentityHash: "d1", Columns: []string{"id"}},
+			}},
+			{Version: 2, IdentityHash: "v2", Entities: []EntitySnapshot{
+				{TableName: "dummy_tables", IdentityHash: "d2", Columns: []string{"id", "value"}},
+			}},
+		},
+	}
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName: "dummy_tables", EntityModel: "dummyModel", Columns: []string{"id", "value"},
+	}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{
+		TableName: "another_dummy_tables", EntityModel: "anotherModel", Columns: []string{"num", "text"},
+	}).AnyTimes()
+	s.IdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return("v3", nil).AnyTimes()
+}
+
+func (s *RecoveryTestSuite) expectTables(dummyColumns []string, anotherColumns []string) {
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(len(dummyColumns) > 0).AnyTimes()
+	s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(len(anotherColumns) > 0).AnyTimes()
+	s.Introspector.EXPECT().GetColumns("dummy_tables").Return(getColumnInfos(dummyColumns), nil).AnyTimes()
+	s.Introspector.EXPECT().GetColumns("another_dummy_tables").Return(getColumnInfos(anotherColumns), nil).AnyTimes()
+	s.Introspector.EXPECT().GetIndexes(gomock.Any()).Return(nil, nil).AnyTimes()
+	s.Introspector.EXPECT().GetForeignKeys(gomock.Any()).Return(nil, nil).AnyTimes()
+}
+
+func (s *RecoveryTestSuite) TestWithoutExistingTables() {
+	s.expectTables(nil, nil)
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.True(s.T(), !adopted && err == nil, "Fresh install needs no recovery")
+}
+
+func (s *RecoveryTestSuite) TestStampCurrentVersion() {
+	s.AppDB.missingSchemaMaster = StampCurrentVersion
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.True(s.T(), !adopted && err == nil)
+}
+
+func (s *RecoveryTestSuite) TestRefuseExistingTables() {
+	s.AppDB.missingSchemaMaster = RefuseExistingTables
+	s.expectTables([]string{"id"}, nil)
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.False(s.T(), adopted)
+	assert.Equal(s.T(), fmt.Errorf("Entity tables [dummy_tables] exist without a Room Schema Master. Refusing to assume their version"), err)
+}
+
+func (s *RecoveryTestSuite) TestDetectAndAdoptVersion() {
+	s.expectTables([]string{"value", "id"}, nil)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.DBA)
+	})
+	s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{})
+	s.DBA.EXPECT().Create(&GoRoomSchemaMaster{
+		Version:      2,
+		IdentityHash: "v2",
+		EntityHashes: `{"dummy_tables":"d2"}`,
+	}).Return(orm.Result{})
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.True(s.T(), adopted && err == nil)
+}
+
+func (s *RecoveryTestSuite) TestDetectCurrentVersion() {
+	s.expectTables([]string{"id", "value"}, []string{"num", "text"})
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.True(s.T(), !adopted && err == nil, "Tables at current version are stamped as before")
+}
+
+func (s *RecoveryTestSuite) TestDetectUnknownVersion() {
+	s.expectTables([]string{"id", "value"}, []string{"num"})
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.False(s.T(), adopted)
+	assert.Equal(s.T(), fmt.Errorf("Entity tables exist without a Room Schema Master and match no known version"), err)
+}
+
+func (s *RecoveryTestSuite) TestDetectWithoutSchemaIntrospector() {
+	s.AppDB.dba = s.DBA
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(true)
+	s.DBA.EXPECT().HasTable(AnotherDummyTable{}).Return(false)
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.False(s.T(), adopted)
+	assert.Equal(s.T(), fmt.Errorf("ORM can not introspect the schema of existing entity tables to detect their version"), err)
+}
+
+func (s *RecoveryTestSuite) TestDetectAmbiguousVersion() {
+	s.AppDB.schemaSnapshots = append(s.AppDB.schemaSnapshots, &SchemaSnapshot{Version: 1, IdentityHash: "v1b", Entities: []EntitySnapshot{
+		{TableName: "dummy_tables", IdentityHash: "d1b", Columns: []string{"value", "id"}},
+	}})
+	s.expectTables([]string{"id", "value"}, nil)
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.False(s.T(), adopted)
+	assert.Equal(s.T(), fmt.Errorf("Entity tables exist without a Room Schema Master and match versions [1 2]. Unable to tell which one they are at"), err)
+}
+
+func (s *RecoveryTestSuite) TestDetectVersionSharingColumnsByForeignKeys() {
+	foreignKey := orm.ForeignKeyDefinition{Columns: []string{"value"}, ReferencedTable: "values", ReferencedColumns: []string{"id"}}
+	s.AppDB.schemaSnapshots = []*SchemaSnapshot{
+		{Version: 1, IdentityHash: "v1", Entities: []EntitySnapshot{
+			{TableName: "dummy_tables", IdentityHash: "d1", Columns: []string{"id", "value"}, Table: &TableSnapshot{ForeignKeys: []orm.ForeignKeyDefinition{foreignKey}}},
+		}},
+		{Version: 2, IdentityHash: "v2", Entities: []EntitySnapshot{
+			{TableName: "dummy_tables", IdentityHash: "d2", Columns: []string{"id", "value"}, Table: &TableSnapshot{}},
+		}},
+	}
+	s.Introspector.EXPECT().GetForeignKeys("dummy_tables").Return([]orm.ForeignKeyDefinition{foreignKey}, nil).AnyTimes()
+	s.expectTables([]string{"id", "value"}, nil)
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.DBA)
+	})
+	s.DBA.EXPECT().CreateTable(GoRoomSchemaMaster{}).Return(orm.Result{})
+	s.DBA.EXPECT().Create(&GoRoomSchemaMaster{
+		Version:      1,
+		IdentityHash: "v1",
+		EntityHashes: `{"dummy_tables":"d1"}`,
+	}).Return(orm.Result{})
+
+	adopted, err := s.AppDB.recoverMissingSchemaMaster()
+	assert.True(s.T(), adopted && err == nil, "Foreign key should tell version 1 apart from version 2 with the same columns")
+}
+
+func (s *RecoveryTestSuite) TestIsSnapshotMatching() {
+	snapshot := &SchemaSnapshot{Entities: []EntitySnapshot{
+		{TableName: "dummy_tables", Columns: []string{"id", "value"}},
+	}}
+	getTable := func(columns ...string) *existingTable {
+		return &existingTable{columns: getColumnInfos(columns)}
+	}
+
+	assert.True(s.T(), isSnapshotMatching(snapshot, map[string]*existingTable{"dummy_tables": getTable("value", "id"), "another_dummy_tables": getTable()}))
+	assert.False(s.T(), isSnapshotMatching(snapshot, map[string]*existingTable{"dummy_tables": getTable("id")}), "Columns differ")
+	assert.False(s.T(), isSnapshotMatching(snapshot, map[string]*existingTable{"dummy_tables": getTable("id", "value"), "another_dummy_tables": getTable("num")}),
+		"Table of another version exists")
+	assert.False(s.T(), isSnapshotMatching(&SchemaSnapshot{Entities: []EntitySnapshot{{TableName: "dummy_tables"}}}, map[string]*existingTable{"dummy_tables": getTable("id")}),
+		"Snapshots exported without columns can not be matched")
+}
+
+func (s *RecoveryTestSuite) TestIsTableMatching() {
+	entity := EntitySnapshot{TableName: "dummy_tables", Columns: []string{"id", "value"}, Table: &TableSnapshot{
+		ColumnTypes: map[string]string{"id": "integer", "value": "varchar(255)"},
+		Indexes:     []orm.IndexDefinition{{Name: "idx_dummy_tables_value", Columns: []string{"value"}}},
+	}}
+	table := &existingTable{
+		columns: []orm.ColumnInfo{{Name: "id", Type: "INTEGER"}, {Name: "value", Type: "varchar(255)"}},
+		indexes: []orm.IndexDefinition{{Name: "idx_dummy_tables_value", Columns: []string{"value"}}},
+	}
+
+	assert.True(s.T(), isTableMatching(entity, table))
+	assert.False(s.T(), isTableMatching(entity, &existingTable{columns: []orm.ColumnInfo{{Name: "id", Type: "integer"}, {Name: "value", Type: "text"}}, indexes: table.indexes}),
+		"Column types differ")
+	assert.False(s.T(), isTableMatching(entity, &existingTable{columns: table.columns}), "Index is missing")
+	assert.False(s.T(), isTableMatching(entity, &existingTable{columns: table.columns, indexes: table.indexes, foreignKeys: []orm.ForeignKeyDefinition{
+		{Columns: []string{"value"}, ReferencedTable: "values", ReferencedColumns: []string{"id"}},
+	}}), "Foreign key is not expected")
+	entity.Table = nil
+	assert.True(s.T(), isTableMatching(entity, &existingTable{columns: []orm.ColumnInfo{{Name: "id"}, {Name: "value"}}}), "Only columns are compared for older snapshots")
+}
@@ -0,0 +1,71 @@
+package room
+
+import (
+	"github.com/adonmo/goroom/orm"
+)
+
+//applyRepeatableMigrations Applies repeatable migrations whose checksum differs from the one recorded in Schema Master
+//and records their new checksums in the same transaction
+func (appDB *Room) applyRepeatableMigrations() error {
+	if len(appDB.repeatableMigrations) < 1 {
+		return nil
+	}
+
+	var applied []string
+	err := appDB.doInTransaction(func(dba orm.ORM) error {
+		record, err := appDB.getLatestRoomRecord(dba)
+		if err != nil {
+			return err
+		}
+		checksums, err := record.GetRepeatableChecksums()
+		if err != nil {
+			return err
+		}
+		if checksums == nil {
+			checksums = make(map[string]string)
+		}
+
+		for _, migration := range appDB.repeatableMigrations {
+			name, checksum := migration.GetName(), migration.GetChecksum()
+			if checksums[name] == checksum {
+				continue
+			}
+
+			if err = migration.Apply(dba.GetUnderlyingORM()); err != nil {
+				appDB.log().Errorf("Failed while applying repeatable migration %v. %v", name, err)
+				return err
+			}
+			checksums[name] = checksum
+			applied = append(applied, name)
+		}
+
+		if len(applied) < 1 {
+			return nil
+		}
+		record.RepeatableChecksums = encodeJSONColumn(checksums)
+		return appDB.replaceRoomRecord(dba, *record)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(applied) > 0 {
+		appDB.log().Infof("Applied repeatable migrations %v", applied)
+		appDB.lastResult.RepeatableMigrationsApplied = applied
+	}
+	return nil
+}
+
+//carryRepeatableChecksums Keeps checksums of repeatable migrations applied before a new record of Schema Master replaces the latest one
+func (appDB *Room) carryRepeatableChecksums(dba orm.ORM, metadata *GoRoomSchemaMaster) error {
+	if len(appDB.repeatableMigrations) < 1 {
+		return nil
+	}
+
+	previous, err := appDB.getLatestRoomRecord(dba)
+	if err != nil {
+		return err
+	}
+	metadata.RepeatableChecksums = previous.RepeatableChecksums
+	return nil
+}
@@ -0,0 +1,137 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RepeatableMigrationTestSuite struct {
+	suite.Suite
+	MockCtrl *gomock.Controller
+	DBA      *mocks.MockORM
+	Loader   *mocks.MockRowLoader
+	Migrator *mocks.MockAutoMigrator
+	ORM      *RowLoadingORM
+	Defaults *mocks.MockRepeatableMigration
+	Lookups  *mocks.MockRepeatableMigration
+	AppDB    *Room
+}
+
+func (s *RepeatableMigrationTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Loader = mocks.NewMockRowLoader(s.MockCtrl)
+	s.Migrator = mocks.NewMockAutoMigrator(s.MockCtrl)
+	s.ORM = &RowLoadingORM{MockORM: s.DBA, MockRowLoader: s.Loader, MockAutoMigrator: s.Migrator}
+	s.Defaults = mocks.NewMockRepeatableMigration(s.MockCtrl)
+	s.Lookups = mocks.NewMockRepeatableMigration(s.MockCtrl)
+	s.AppDB = &Room{
+		entities:             []interface{}{DummyTable{}},
+		version:              3,
+		dba:                  s.ORM,
+		logger:               &RecordingLogger{},
+		lastResult:           &InitResult{},
+		repeatableMigrations: []orm.RepeatableMigration{s.Defaults, s.Lookups},
+	}
+
+	s.Defaults.EXPECT().GetName().Return("defaults").AnyTimes()
+	s.Defaults.EXPECT().GetChecksum().Return("d2").AnyTimes()
+	s.Lookups.EXPECT().GetName().Return("lookups").AnyTimes()
+	s.Lookups.EXPECT().GetChecksum().Return("l1").AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{TableName: "dummy_tables"}).AnyTimes()
+	s.DBA.EXPECT().GetUnderlyingORM().Return("underlyingORM").AnyTimes()
+	s.DBA.EXPECT().DoInTransaction(gomock.Any()).DoAndReturn(func(fc func(orm.ORM) error) error {
+		return fc(s.ORM)
+	}).AnyTimes()
+}
+
+func (s *RepeatableMigrationTestSuite) expectLatestRecord(record GoRoomSchemaMaster) {
+	s.Loader.EXPECT().FindAll(s.AppDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{record}).Return(orm.Result{})
+}
+
+func (s *RepeatableMigrationTestSuite) TestApplyChangedRepeatableMigrations() {
+	s.expectLatestRecord(GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", RepeatableChecksums: `{"defaults":"d1","lookups":"l1"}`})
+	s.Defaults.EXPECT().Apply("underlyingORM").Return(nil)
+	s.Migrator.EXPECT().AutoMigrate(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().TruncateTable(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().Create(&GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", RepeatableChecksums: `{"defaults":"d2","lookups":"l1"}`}).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.applyRepeatableMigrations())
+	assert.Equal(s.T(), []string{"defaults"}, s.AppDB.GetLastResult().RepeatableMigrationsApplied)
+}
+
+func (s *RepeatableMigrationTestSuite) TestApplyRepeatableMigrationsForTheFirstTime() {
+	s.expectLatestRecord(GoRoomSchemaMaster{Version: 3, IdentityHash: "v3"})
+	gomock.InOrder(
+		s.Defaults.EXPECT().Apply("underlyingORM").Return(nil),
+		s.Lookups.EXPECT().Apply("underlyingORM").Return(nil),
+	)
+	s.Migrator.EXPECT().AutoMigrate(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().TruncateTable(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().Create(&GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", RepeatableChecksums: `{"defaults":"d2","lookups":"l1"}`}).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.applyRepeatableMigrations())
+	assert.Equal(s.T(), []string{"defaults", "lookups"}, s.AppDB.GetLastResult().RepeatableMigrationsApplied)
+}
+
+func (s *RepeatableMigrationTestSuite) TestUnchangedRepeatableMigrationsAreSkipped() {
+	s.expectLatestRecord(GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", RepeatableChecksums: `{"defaults":"d2","lookups":"l1"}`})
+
+	assert.Nil(s.T(), s.AppDB.applyRepeatableMigrations())
+	assert.Nil(s.T(), s.AppDB.GetLastResult().RepeatableMigrationsApplied)
+}
+
+func (s *RepeatableMigrationTestSuite) TestFailingRepeatableMigration() {
+	s.expectLatestRecord(GoRoomSchemaMaster{Version: 3, IdentityHash: "v3", RepeatableChecksums: `{"lookups":"l1"}`})
+	s.Defaults.EXPECT().Apply("underlyingORM").Return(fmt.Errorf("Defaults failed"))
+
+	assert.Equal(s.T(), fmt.Errorf("Defaults failed"), s.AppDB.applyRepeatableMigrations())
+	assert.Nil(s.T(), s.AppDB.GetLastResult().RepeatableMigrationsApplied)
+}
+
+func (s *RepeatableMigrationTestSuite) TestSeedsRunOnFirstTimeCreation() {
+	var seeded []interface{}
+	s.AppDB.seeds = []func(db interface{}) error{
+		func(db interface{}) error {
+			seeded = append(seeded, db)
+			return nil
+		},
+	}
+	s.DBA.EXPECT().CreateTable(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(false)
+	s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{})
+	s.DBA.EXPECT().Create(gomock.Any()).Return(orm.Result{})
+
+	assert.Nil(s.T(), s.AppDB.getFirstTimeDBCreationFunction("v3", nil, nil)(s.DBA))
+	assert.Equal(s.T(), []interface{}{"underlyingORM"}, seeded)
+}
+
+func (s *RepeatableMigrationTestSuite) TestFailingSeedAbortsFirstTimeCreation() {
+	s.AppDB.seeds = []func(db interface{}) error{
+		func(db interface{}) error { return fmt.Errorf("Seed failed") },
+	}
+	s.DBA.EXPECT().CreateTable(s.AppDB.schemaMaster).Return(orm.Result{})
+	s.DBA.EXPECT().HasTable(DummyTable{}).Return(false)
+	s.DBA.EXPECT().CreateTable(DummyTable{}).Return(orm.Result{})
+
+	assert.Equal(s.T(), fmt.Errorf("Seed failed"), s.AppDB.getFirstTimeDBCreationFunction("v3", nil, nil)(s.DBA))
+}
+
+func (s *RepeatableMigrationTestSuite) TestNewFromConfigWithDuplicateRepeatableMigrations() {
+	identityCalc := mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	appDB, errList := NewFromConfig(Config{
+		Entities:             []interface{}{DummyTable{}},
+		DBA:                  s.DBA,
+		Version:              3,
+		IdentityCalculator:   identityCalc,
+		RepeatableMigrations: []orm.RepeatableMigration{s.Defaults, s.Lookups, s.Defaults},
+	})
+
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), []error{fmt.Errorf("Repeatable migration defaults is declared more than once")}, errList)
+}
@@ -0,0 +1,76 @@
+package room
+
+import (
+	"time"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//AppliedMigration Migration that was committed during initialization
+type AppliedMigration struct {
+	From orm.VersionNumber
+	To   orm.VersionNumber
+}
+
+//InitTimings Time spent in the steps of initialization
+type InitTimings struct {
+	IdentityHash time.Duration
+	Migrations   time.Duration
+	CleanUp      time.Duration
+	Total        time.Duration
+}
+
+//InitResult Describes what actually happened while initializing a Room managed DB
+type InitResult struct {
+	Scenario                    string            //One of ScenarioCreate, ScenarioSanityCheck, ScenarioMigration, ScenarioReadOnly and ScenarioNewerCompatible as per the last Init
+	PreviousVersion             orm.VersionNumber //Version found in the DB. Zero if there was no Room managed DB
+	Version                     orm.VersionNumber //Version the DB is at once done
+	MigrationsApplied           []AppliedMigration
+	RepeatableMigrationsApplied []string //Names of repeatable migrations applied as their checksum changed
+	Destroyed                   bool     //Tables were dropped by destructive fallback hence data was lost
+	Unusable                    bool     //Init failed suggesting to retry after destruction as the DB is unusable as is e.g. corrupt
+	TablesCreated               []string
+	TablesDropped               []string
+	Timings                     InitTimings
+}
+
+//InitResultProvider Initializer which describes what its last Init or PerformDBCleanUp did
+type InitResultProvider interface {
+	GetLastResult() *InitResult
+}
+
+//Add Accumulates the result of a later step(Init or PerformDBCleanUp) of the same initialization
+func (result *InitResult) Add(step *InitResult) {
+	if step == nil {
+		return
+	}
+
+	//Version found by the first step. Steps after a destructive fallback find a DB reset by it
+	if result.PreviousVersion == 0 {
+		result.PreviousVersion = step.PreviousVersion
+	}
+	if step.Scenario != "" {
+		result.Scenario = step.Scenario
+	}
+	result.Version = step.Version
+	result.MigrationsApplied = append(result.MigrationsApplied, step.MigrationsApplied...)
+	result.RepeatableMigrationsApplied = append(result.RepeatableMigrationsApplied, step.RepeatableMigrationsApplied...)
+	result.Destroyed = result.Destroyed || step.Destroyed
+	result.TablesCreated = append(result.TablesCreated, step.TablesCreated...)
+	result.TablesDropped = append(result.TablesDropped, step.TablesDropped...)
+	result.Timings.Migrations += step.Timings.Migrations
+	result.Timings.CleanUp += step.Timings.CleanUp
+}
+
+//GetLastResult What the last Init or PerformDBCleanUp of this Room did
+func (appDB *Room) GetLastResult() *InitResult {
+	return appDB.lastResult
+}
+
+func (appDB *Room) getTableNames(entities []interface{}) []string {
+	tableNames := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		tableNames = append(tableNames, appDB.dba.GetModelDefinition(entity).TableName)
+	}
+	return tableNames
+}
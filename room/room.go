@@ -2,6 +2,7 @@ package room
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/adonmo/goroom/logger"
 	"github.com/adonmo/goroom/orm"
@@ -14,50 +15,227 @@ type Initializer interface {
 	PerformDBCleanUp() error
 }
 
+//ConfiguredInitializer Initializer which carries the destructive fallback policy it was configured with
+type ConfiguredInitializer interface {
+	Initializer
+	GetDestructiveFallbackPolicy() DestructiveFallbackPolicy
+}
+
 //Room Tracks the database objects, properties and configuration
 type Room struct {
-	entities           []interface{}
-	version            orm.VersionNumber
-	migrations         []orm.Migration
-	dba                orm.ORM
-	identityCalculator orm.IdentityHashCalculator
+	entities             []interface{}
+	version              orm.VersionNumber
+	migrations           []orm.Migration
+	dba                  orm.ORM
+	identityCalculator   orm.IdentityHashCalculator
+	locker               orm.Locker
+	namespace            string
+	schema               string
+	schemaScoper         orm.SchemaScoper
+	schemaMaster         GoRoomSchemaMaster
+	logger               logger.Logger
+	hooks                Hooks
+	destructiveFallback  DestructiveFallbackPolicy
+	orphanedTables       OrphanedTablePolicy
+	schemaVerifier       orm.SchemaVerifier
+	progressReporter     orm.ProgressReporter
+	instrumentation      orm.Instrumentation
+	lastResetTables      []string
+	lastOrphanedTables   []string
+	lastResult           *InitResult
+	readOnly             bool
+	minCompatibleVersion orm.VersionNumber
+	integrity            IntegrityPolicy
+	integrityKey         []byte
+	missingSchemaMaster  MissingSchemaMasterPolicy
+	schemaSnapshots      []*SchemaSnapshot
+	repeatableMigrations []orm.RepeatableMigration
+	seeds                []func(db interface{}) error
+	preflightChecks      []orm.PreflightCheck
 }
 
 //New Returns a new room struct that can be used to initialize and get a DB managed by room
 func New(entities []interface{}, dba orm.ORM, version orm.VersionNumber,
 	migrations []orm.Migration, identityCalculator orm.IdentityHashCalculator) (room *Room, errors []error) {
 
-	if len(entities) < 1 {
+	return NewFromConfig(Config{
+		Entities:           entities,
+		DBA:                dba,
+		Version:            version,
+		Migrations:         migrations,
+		IdentityCalculator: identityCalculator,
+	})
+}
+
+//NewWithNamespace Returns a new room which versions its entities independently of Rooms in other namespaces of the same DB
+func NewWithNamespace(namespace string, entities []interface{}, dba orm.ORM, version orm.VersionNumber,
+	migrations []orm.Migration, identityCalculator orm.IdentityHashCalculator) (room *Room, errors []error) {
+
+	return NewFromConfig(Config{
+		Entities:           entities,
+		DBA:                dba,
+		Version:            version,
+		Migrations:         migrations,
+		IdentityCalculator: identityCalculator,
+		Namespace:          namespace,
+	})
+}
+
+//NewFromConfig Returns a new room as per the given configuration
+func NewFromConfig(config Config) (room *Room, errors []error) {
+
+	if len(config.Entities) < 1 {
 		errors = append(errors, fmt.Errorf("No entities provided for the database"))
 	}
-	if dba == nil {
+	if config.DBA == nil {
 		errors = append(errors, fmt.Errorf("Need an ORM to work with"))
 	}
-	if version < 1 {
+	if config.Version < 1 {
 		errors = append(errors, fmt.Errorf("Only non zero versions allowed"))
 	}
-	if identityCalculator == nil {
+	if config.IdentityCalculator == nil {
 		errors = append(errors, fmt.Errorf("Need an identity calculator"))
 	}
+	if !isValidNamespace(config.Namespace) {
+		errors = append(errors, fmt.Errorf("Namespace %v is invalid. Only lower case letters, digits and underscores are allowed", config.Namespace))
+	}
+	if config.SchemaMasterTableName != "" && !isValidNamespace(config.SchemaMasterTableName) {
+		errors = append(errors, fmt.Errorf("Schema master table name %v is invalid. Only lower case letters, digits and underscores are allowed", config.SchemaMasterTableName))
+	}
+	if _, ok := config.DBA.(orm.RowLoader); !ok && config.DBA != nil {
+		if config.Namespace != "" {
+			errors = append(errors, fmt.Errorf("Namespace %v needs an ORM implementing orm.RowLoader to register its tables", config.Namespace))
+		}
+		if config.SchemaMasterTableName != "" {
+			errors = append(errors, fmt.Errorf("Schema master table name %v needs an ORM implementing orm.RowLoader to read it", config.SchemaMasterTableName))
+		}
+	}
+	if config.MinCompatibleVersion > config.Version {
+		errors = append(errors, fmt.Errorf("Minimum compatible version %v can not be greater than version %v", config.MinCompatibleVersion, config.Version))
+	}
+	repeatableNames := make(map[string]bool)
+	for _, migration := range config.RepeatableMigrations {
+		if repeatableNames[migration.GetName()] {
+			errors = append(errors, fmt.Errorf("Repeatable migration %v is declared more than once", migration.GetName()))
+		}
+		repeatableNames[migration.GetName()] = true
+	}
+
+	var dba orm.ORM
+	var schemaScoper orm.SchemaScoper
+	if config.Schema != "" && !isValidNamespace(config.Schema) {
+		errors = append(errors, fmt.Errorf("Schema %v is invalid. Only lower case letters, digits and underscores are allowed", config.Schema))
+	} else {
+		var err error
+		dba, schemaScoper, err = getSchemaScopedORM(config.DBA, config.Schema)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
 
 	if len(errors) < 1 {
 		room = &Room{
-			entities:           entities,
-			version:            version,
-			migrations:         migrations,
-			dba:                dba,
-			identityCalculator: identityCalculator,
+			entities:             config.Entities,
+			version:              config.Version,
+			migrations:           config.Migrations,
+			dba:                  dba,
+			identityCalculator:   config.IdentityCalculator,
+			locker:               config.Locker,
+			namespace:            config.Namespace,
+			schema:               config.Schema,
+			schemaScoper:         schemaScoper,
+			schemaMaster:         newSchemaMaster(dba, config.Namespace, config.SchemaMasterTableName),
+			logger:               config.Logger,
+			hooks:                config.Hooks,
+			destructiveFallback:  config.DestructiveFallback,
+			orphanedTables:       config.OrphanedTables,
+			schemaVerifier:       config.SchemaVerifier,
+			progressReporter:     config.ProgressReporter,
+			instrumentation:      config.Instrumentation,
+			readOnly:             config.ReadOnly,
+			minCompatibleVersion: config.MinCompatibleVersion,
+			integrity:            config.Integrity,
+			integrityKey:         config.IntegrityKey,
+			missingSchemaMaster:  config.MissingSchemaMaster,
+			schemaSnapshots:      config.SchemaSnapshots,
+			repeatableMigrations: config.RepeatableMigrations,
+			seeds:                config.Seeds,
+			preflightChecks:      config.PreflightChecks,
 		}
 	}
 
 	return
 }
 
+//GetDestructiveFallbackPolicy Policy to follow when initialization fails
+func (appDB *Room) GetDestructiveFallbackPolicy() DestructiveFallbackPolicy {
+	return appDB.destructiveFallback
+}
+
+func (appDB *Room) log() logger.Logger {
+	if appDB.logger == nil {
+		return logger.Standard
+	}
+	return appDB.logger
+}
+
+//UseLocker Serialize Init and PerformDBCleanUp across processes using the given lock
+func (appDB *Room) UseLocker(locker orm.Locker) {
+	appDB.locker = locker
+}
+
+func (appDB *Room) acquireLock() error {
+	if appDB.locker == nil {
+		return nil
+	}
+
+	if err := appDB.locker.Lock(); err != nil {
+		appDB.log().Errorf("Unable to acquire Room lock. %v", err)
+		return err
+	}
+	return nil
+}
+
+func (appDB *Room) releaseLock() {
+	if appDB.locker == nil {
+		return
+	}
+
+	if err := appDB.locker.Unlock(); err != nil {
+		appDB.log().Warnf("Unable to release Room lock. %v", err)
+	}
+}
+
+//checkLockHeld Fails if a lock that can be lost while held, e.g. by failing to renew its lease, is no longer held
+func (appDB *Room) checkLockHeld() error {
+	leasedLocker, ok := appDB.locker.(orm.LeasedLocker)
+	if !ok {
+		return nil
+	}
+
+	if err := leasedLocker.CheckHeld(); err != nil {
+		appDB.log().Errorf("Room lock was lost. %v", err)
+		return err
+	}
+	return nil
+}
+
+//doInTransaction Runs fc in a transaction which is committed only if the Room lock is still held
+func (appDB *Room) doInTransaction(fc func(orm.ORM) error) error {
+	return appDB.dba.DoInTransaction(func(dba orm.ORM) error {
+		if err := fc(dba); err != nil {
+			return err
+		}
+		return appDB.checkLockHeld()
+	})
+}
+
 /* Initialization Scenarios In Brief:
 Scenario 1:
 	Trigger: 	No Schema Master Present.
 	Action:		Room creates Schema Master and any entity tables that are not there already.
-	Gotcha:		Pre Existing Tables are assumed to have schema same as current version
+	Gotcha:		Pre Existing Tables are assumed to have schema same as current version unless a missing schema master policy says otherwise.
+				The version of pre existing tables can be detected from schema snapshots, in which case it is recorded and Scenario 3 follows.
 
 Scenario 2:
 	Trigger: 	Schema Master Present and Version is same.
@@ -70,43 +248,144 @@ Scenario 3:
 	Gotcha: 	An Empty migration must be specified even if no database action(like altering tables etc) is required for version change.
 
 If the initialization fails for any reason in any of the three scenarios then we check for destructive migration option.
-If enabled whole DB(Schema Master and known entities) is wiped out and init is retried
+If enabled whole DB(Schema Master and known entities) is wiped out and init is retried.
+With selective destructive fallback only the tables whose identity hash changed since the recorded version, or which are
+declared by any migration from the recorded version, are dropped and recreated. Every other table keeps its data.
+
+Rooms in a namespace keep their metadata in a schema master of their own. Every Room, including the one of the default
+namespace, registers its entity tables so that two namespaces sharing a DB can not manage the same table. Collisions fail
+Init without suggesting destruction. Rooms of the default namespace skip the registry if the ORM is not an orm.RowLoader.
+
+Tables recorded for the version migrated from that are no longer among the entities are orphaned tables. They are reported,
+archived or dropped as part of the migration transaction when an orphaned table policy is configured.
+
+Repeatable migrations whose checksum changed since they were last applied are applied once any of the three scenarios succeeds.
+Their failure is returned without suggesting destruction.
+
+A Room confined to a schema of the DB creates the schema if needed before any of these scenarios.
+
+If a locker is configured it is held for the whole of Init so that processes sharing the DB do not race on these scenarios.
+
+A DB at a newer version is used as is, without migrating or destroying it, if the version that created or migrated it
+declared the version of this Room compatible. This lets an app rolled back by an OTA update keep working with its data.
+
+With an integrity policy the rows of Schema Master carry a checksum which is verified before any of these scenarios.
+Modified or conflicting rows fail Init without suggesting destruction. They are repaired with RepairSchemaMaster.
+
+A read only Room neither takes the lock nor modifies the DB. Init only checks that the DB is at the same version with the same identity hash.
+
+Before migrating, preflight checks e.g. of free disk space are run. If any of them fails Init returns a *PreflightError
+without invoking the migration hooks or changing the DB, and without suggesting destruction.
+
+A failed migration is rolled back unless committing it failed or migrations outside a transaction had already changed the DB.
+Init then returns a *PartialMigrationError so that hooks, e.g. restoring a snapshot, can tell the DB may have changed.
 */
 
 //Init Initialize Room Database
 func (appDB *Room) Init(currentIdentityHash string) (shouldRetryAfterDestruction bool, err error) {
 
+	operation := appDB.startOperation(OperationInit, map[string]string{
+		LabelToVersion: versionLabel(appDB.version),
+	})
+	defer func() {
+		operation.End(err)
+	}()
+
+	appDB.lastResult = &InitResult{}
+	if appDB.readOnly {
+		operation.SetLabel(LabelScenario, ScenarioReadOnly)
+		return false, appDB.verifyReadOnly(currentIdentityHash)
+	}
+
+	if err = appDB.createSchema(); err != nil {
+		return false, err
+	}
+
+	if err = appDB.acquireLock(); err != nil {
+		return false, err
+	}
+	defer appDB.releaseLock()
+
+	appDB.lastOrphanedTables = nil
+
+	if err = appDB.checkNamespaceCollisions(); err != nil {
+		return false, err
+	}
+
 	if !appDB.isSchemaMasterPresent() {
-		logger.Info("No Room Schema Master Detected in existing SQL DB. Creating now..")
-		dbCreationFunc := getFirstTimeDBCreationFunction(currentIdentityHash, appDB.version, appDB.entities)
-		err = appDB.dba.DoInTransaction(dbCreationFunc)
+		adopted, err := appDB.recoverMissingSchemaMaster()
 		if err != nil {
-			logger.Errorf("Unable to Initialize Room. Unexpected Error. %v", err)
-			return true, err
+			return false, err
 		}
-		return false, nil
+
+		if !adopted {
+			operation.SetLabel(LabelScenario, ScenarioCreate)
+			if shouldRetryAfterDestruction, err = appDB.performFirstTimeCreation(currentIdentityHash); err != nil {
+				return shouldRetryAfterDestruction, err
+			}
+			return false, appDB.applyRepeatableMigrations()
+		}
+	}
+
+	if err = appDB.verifySchemaMasterIntegrity(); err != nil {
+		return false, err
 	}
 
 	roomMetadata, err := appDB.getRoomMetadataFromDB()
 	if err != nil {
-		logger.Error("Unable to fetch metadata although room master exists. This could be a sign of database corruption.")
+		appDB.log().Errorf("Unable to fetch metadata although room master exists. This could be a sign of database corruption.")
 		return true, err
 	}
 
+	operation.SetLabel(LabelFromVersion, versionLabel(roomMetadata.Version))
+	appDB.lastResult.PreviousVersion = roomMetadata.Version
+	appDB.lastResult.Version = roomMetadata.Version
+
+	if roomMetadata.Version > appDB.version && appDB.isCompatibleNewerDB() {
+		appDB.log().Warnf("Database at version %v is newer than version %v. Using it as it declares this version compatible", roomMetadata.Version, appDB.version)
+		operation.SetLabel(LabelScenario, ScenarioNewerCompatible)
+		appDB.lastResult.Scenario = ScenarioNewerCompatible
+		return false, nil
+	}
+
 	applicableMigrations, err := GetApplicableMigrations(appDB.migrations, roomMetadata.Version, appDB.version)
 	if err != nil {
 		return true, err
 	}
 
 	if appDB.version == roomMetadata.Version {
+		operation.SetLabel(LabelScenario, ScenarioSanityCheck)
+		appDB.lastResult.Scenario = ScenarioSanityCheck
 		err = appDB.peformDatabaseSanityChecks(currentIdentityHash, roomMetadata)
 	} else {
+		operation.SetLabel(LabelScenario, ScenarioMigration)
+		appDB.lastResult.Scenario = ScenarioMigration
+		if err = appDB.runPreflightChecks(roomMetadata.Version, applicableMigrations); err != nil {
+			return false, err
+		}
+		if appDB.hooks.BeforeMigration != nil {
+			if err = appDB.hooks.BeforeMigration(roomMetadata.Version, appDB.version); err != nil {
+				appDB.log().Warnf("Migration from %v to %v aborted by hook. %v", roomMetadata.Version, appDB.version, err)
+				return false, err
+			}
+		}
+		start := time.Now()
 		err = appDB.performMigrations(currentIdentityHash, applicableMigrations)
+		appDB.lastResult.Timings.Migrations = time.Since(start)
+		if err != nil {
+			appDB.log().Errorf("Migration from %v to %v failed. %v", roomMetadata.Version, appDB.version, err)
+		} else if appDB.orphanedTables == DropOrphanedTables {
+			appDB.lastResult.TablesDropped = appDB.lastOrphanedTables
+		}
+		if appDB.hooks.AfterMigration != nil {
+			appDB.hooks.AfterMigration(roomMetadata.Version, appDB.version, err)
+		}
 	}
 
 	if err != nil {
-		shouldRetryAfterDestruction = true
+		return true, err
 	}
 
-	return shouldRetryAfterDestruction, err
+	appDB.lastResult.Version = appDB.version
+	return false, appDB.applyRepeatableMigrations()
 }
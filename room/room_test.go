@@ -22,6 +22,21 @@ type AnotherDummyTable struct {
 	Text string
 }
 
+//newMockORM Mock ORM which derives the table name of the schema master like GORM does by default
+func newMockORM(ctrl *gomock.Controller) *mocks.MockORM {
+	dba := mocks.NewMockORM(ctrl)
+	dba.EXPECT().GetModelDefinition(legacySchemaMaster).Return(orm.ModelDefinition{TableName: defaultSchemaMasterTableName}).AnyTimes()
+	return dba
+}
+
+//RowLoadingORM Mock ORM implementing the optional interfaces through which Room reads rows, upgrades the schema master and renames tables
+type RowLoadingORM struct {
+	*mocks.MockORM
+	*mocks.MockRowLoader
+	*mocks.MockAutoMigrator
+	*mocks.MockTableRenamer
+}
+
 type RoomConstructorTestSuite struct {
 	suite.Suite
 	MockControl        *gomock.Controller
@@ -36,7 +51,7 @@ func (suite *RoomConstructorTestSuite) SetupTest() {
 	mockCtrl := gomock.NewController(suite.T())
 	suite.MockControl = mockCtrl
 	suite.Entities = []interface{}{DummyTable{}, AnotherDummyTable{}}
-	suite.Dba = mocks.NewMockORM(suite.MockControl)
+	suite.Dba = newMockORM(suite.MockControl)
 	suite.Version = orm.VersionNumber(3)
 	suite.IdentityCalculator = mocks.NewMockIdentityHashCalculator(suite.MockControl)
 	suite.Migrations = []orm.Migration{}
@@ -132,7 +147,7 @@ type RoomInitTestSuite struct {
 func (s *RoomInitTestSuite) SetupTest() {
 	mockCtrl := gomock.NewController(s.T())
 	s.MockControl = mockCtrl
-	s.MockORM = mocks.NewMockORM(s.MockControl)
+	s.MockORM = newMockORM(s.MockControl)
 	s.MockIdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockControl)
 	s.Entities = []interface{}{DummyTable{}, AnotherDummyTable{}}
 	s.Dba = s.MockORM
@@ -161,7 +176,7 @@ func (s *RoomInitTestSuite) TestInitRoomDBForScenario1() {
 	}).AnyTimes()
 	s.MockIdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return(identityHash, nil).AnyTimes()
 
-	dbCreationFunc := getFirstTimeDBCreationFunction(identityHash, s.AppDB.version, s.AppDB.entities)
+	dbCreationFunc := s.AppDB.getFirstTimeDBCreationFunction(identityHash, nil, nil)
 	//TODO Tighter check on function arguments
 	s.MockORM.EXPECT().DoInTransaction(gomock.AssignableToTypeOf(dbCreationFunc)).Return(nil)
 
@@ -180,7 +195,7 @@ func (s *RoomInitTestSuite) TestInitRoomDBForScenario1WithErrorInDBCreation() {
 	}).AnyTimes()
 	s.MockIdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return(identityHash, nil).AnyTimes()
 
-	dbCreationFunc := getFirstTimeDBCreationFunction(identityHash, s.AppDB.version, s.AppDB.entities)
+	dbCreationFunc := s.AppDB.getFirstTimeDBCreationFunction(identityHash, nil, nil)
 	//TODO Tighter check on function arguments
 	someError := fmt.Errorf("Creation Transaction Failed")
 	s.MockORM.EXPECT().DoInTransaction(gomock.AssignableToTypeOf(dbCreationFunc)).Return(someError)
@@ -260,7 +275,7 @@ func (s *RoomInitTestSuite) TestInitRoomDBForScenario3() {
 	}).AnyTimes()
 	s.MockIdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return(identityHash, nil).AnyTimes()
 	s.MockORM.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return(storedHash, int(storedVersion), nil)
-	migrationFunc := getMigrationTransactionFunction(s.AppDB.version, identityHash, migrations)
+	migrationFunc := s.AppDB.getMigrationTransactionFunction(identityHash, nil, migrations)
 	s.MockORM.EXPECT().DoInTransaction(gomock.AssignableToTypeOf(migrationFunc)).Return(nil)
 
 	shouldRetry, err := s.AppDB.Init(identityHash)
@@ -295,6 +310,35 @@ func (s *RoomInitTestSuite) TestInitRoomDBForScenario3() {
 	assert.True(s.T(), shouldRetry && err != nil, "Error expected here for Scenario 3 due to failed migration")
 }
 
+func (s *RoomInitTestSuite) TestInitRoomDBWithLock() {
+
+	identityHash := "asasaasa"
+	locker := mocks.NewMockLocker(s.MockControl)
+	s.AppDB.UseLocker(locker)
+
+	gomock.InOrder(
+		locker.EXPECT().Lock().Return(nil),
+		s.MockORM.EXPECT().HasTable(GoRoomSchemaMaster{}).Return(true),
+		s.MockORM.EXPECT().GetLatestSchemaIdentityHashAndVersion().Return(identityHash, int(s.AppDB.version), nil),
+		locker.EXPECT().Unlock().Return(nil),
+	)
+
+	shouldRetry, err := s.AppDB.Init(identityHash)
+	assert.True(s.T(), !shouldRetry && err == nil, "No error expected when lock is acquired")
+}
+
+func (s *RoomInitTestSuite) TestInitRoomDBWithLockTimeout() {
+
+	locker := mocks.NewMockLocker(s.MockControl)
+	s.AppDB.UseLocker(locker)
+
+	lockError := fmt.Errorf("Timed out waiting for lock")
+	locker.EXPECT().Lock().Return(lockError)
+
+	shouldRetry, err := s.AppDB.Init("asasaasa")
+	assert.True(s.T(), !shouldRetry && err == lockError, "Lock error expected without a destructive retry")
+}
+
 func TestMain(t *testing.T) {
 	suite.Run(t, new(RoomConstructorTestSuite))
 	suite.Run(t, new(MigrationSetupTestSuite))
@@ -303,4 +347,16 @@ func TestMain(t *testing.T) {
 	suite.Run(t, new(EntityTestSuite))
 	suite.Run(t, new(DatabaseOperationsTestSuite))
 	suite.Run(t, new(RoomInitTestSuite))
+	suite.Run(t, new(SchemaSnapshotTestSuite))
+	suite.Run(t, new(NamespaceTestSuite))
+	suite.Run(t, new(ConfigTestSuite))
+	suite.Run(t, new(OrphanedTablesTestSuite))
+	suite.Run(t, new(ReadOnlyTestSuite))
+	suite.Run(t, new(IntegrityTestSuite))
+	suite.Run(t, new(RecoveryTestSuite))
+	suite.Run(t, new(BaselineTestSuite))
+	suite.Run(t, new(RepeatableMigrationTestSuite))
+	suite.Run(t, new(CreationTestSuite))
+	suite.Run(t, new(SchemaTestSuite))
+	suite.Run(t, new(PreflightTestSuite))
 }
@@ -0,0 +1,180 @@
+package roomtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/adonmo/goroom/util/verify"
+	"github.com/jinzhu/gorm"
+
+	//SQLite dialect used for the in-memory databases of the harness
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+//VersionFixture Entities of a historical version along with fixture rows that must survive the upgrade
+type VersionFixture struct {
+	Version  orm.VersionNumber
+	Entities []interface{}
+	Seed     func(db *gorm.DB) error //Adds fixture rows to a database at this version
+	Verify   func(db *gorm.DB) error //Checks the fixture rows after the database is upgraded to current version
+}
+
+//MigrationHarness Replays upgrade paths from every historical version with a stored schema snapshot to the current version
+type MigrationHarness struct {
+	SnapshotDir        string
+	History            []VersionFixture
+	Entities           []interface{}
+	Version            orm.VersionNumber
+	Migrations         []orm.Migration
+	IdentityCalculator orm.IdentityHashCalculator
+}
+
+//Run Verifies the upgrade path from each historical version to the current version as a subtest
+func (h *MigrationHarness) Run(t *testing.T) {
+	t.Helper()
+
+	fixtures, err := h.GetFixturesForSnapshots()
+	if err != nil {
+		t.Fatalf("Unable to prepare migration tests. %v", err)
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(fmt.Sprintf("%v=>%v", fixture.Version, h.Version), func(t *testing.T) {
+			if err := h.VerifyUpgradePath(fixture); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+//GetFixturesForSnapshots Matches every stored snapshot older than current version with the fixture declared for it
+func (h *MigrationHarness) GetFixturesForSnapshots() ([]VersionFixture, error) {
+	snapshots, err := room.LoadSchemaSnapshots(h.SnapshotDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtureMap := make(map[orm.VersionNumber]VersionFixture)
+	for _, fixture := range h.History {
+		fixtureMap[fixture.Version] = fixture
+	}
+
+	var fixtures []VersionFixture
+	for _, snapshot := range snapshots {
+		if snapshot.Version >= h.Version {
+			continue
+		}
+
+		fixture, ok := fixtureMap[snapshot.Version]
+		if !ok {
+			return nil, fmt.Errorf("Schema snapshot exists for version %v but no fixture declares its entities", snapshot.Version)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, nil
+}
+
+//VerifyUpgradePath Creates a database at the fixture version, seeds it, upgrades it to current version and compares it with a fresh install
+func (h *MigrationHarness) VerifyUpgradePath(fixture VersionFixture) error {
+	db, err := openInMemoryDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	oldDB, err := h.newRoom(db, fixture.Version, fixture.Entities)
+	if err != nil {
+		return err
+	}
+	if err = oldDB.VerifySchemaSnapshot(h.SnapshotDir); err != nil {
+		return fmt.Errorf("Fixture entities for version %v do not match its snapshot. %v", fixture.Version, err)
+	}
+	if _, err = goroom.InitializeRoom(oldDB, false); err != nil {
+		return fmt.Errorf("Unable to create database for version %v. %v", fixture.Version, err)
+	}
+
+	if fixture.Seed != nil {
+		if err = fixture.Seed(db); err != nil {
+			return fmt.Errorf("Unable to seed fixtures for version %v. %v", fixture.Version, err)
+		}
+	}
+
+	currentDB, err := h.newRoom(db, h.Version, h.Entities)
+	if err != nil {
+		return err
+	}
+	if _, err = goroom.InitializeRoom(currentDB, false); err != nil {
+		return fmt.Errorf("Migration from version %v to %v failed. %v", fixture.Version, h.Version, err)
+	}
+
+	expectedSchema, err := h.getFreshInstallSchema()
+	if err != nil {
+		return err
+	}
+	actualSchema, err := verify.DumpSQLiteSchema(db)
+	if err != nil {
+		return err
+	}
+	if differences := expectedSchema.Diff(actualSchema); len(differences) > 0 {
+		return fmt.Errorf("Schema migrated from version %v differs from a fresh install of version %v:\n%v",
+			fixture.Version, h.Version, strings.Join(differences, "\n"))
+	}
+
+	if fixture.Verify != nil {
+		if err = fixture.Verify(db); err != nil {
+			return fmt.Errorf("Fixtures of version %v did not survive migration to %v. %v", fixture.Version, h.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *MigrationHarness) getFreshInstallSchema() (verify.SQLiteSchema, error) {
+	db, err := openInMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	appDB, err := h.newRoom(db, h.Version, h.Entities)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = goroom.InitializeRoom(appDB, false); err != nil {
+		return nil, fmt.Errorf("Unable to create fresh database for version %v. %v", h.Version, err)
+	}
+
+	return verify.DumpSQLiteSchema(db)
+}
+
+func (h *MigrationHarness) newRoom(db *gorm.DB, version orm.VersionNumber, entities []interface{}) (*room.Room, error) {
+	identityCalculator := h.IdentityCalculator
+	if identityCalculator == nil {
+		identityCalculator = new(adapter.EntityHashConstructor)
+	}
+
+	appDB, errList := room.New(entities, adapter.NewGORM(db), version, h.Migrations, identityCalculator)
+	if len(errList) > 0 {
+		return nil, fmt.Errorf("Unable to create room for version %v. %v", version, errList)
+	}
+
+	return appDB, nil
+}
+
+func openInMemoryDB() (*gorm.DB, error) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	//Every new connection to :memory: is a new database hence the pool is restricted to a single connection
+	db.DB().SetMaxOpenConns(1)
+	return db, nil
+}
@@ -0,0 +1,141 @@
+package roomtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type OldItem struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+func (OldItem) TableName() string {
+	return "items"
+}
+
+type Item struct {
+	ID       int `gorm:"primary_key"`
+	Name     string
+	Quantity int
+}
+
+type itemMigration struct {
+	addColumn bool
+}
+
+func (m *itemMigration) GetBaseVersion() orm.VersionNumber {
+	return 1
+}
+
+func (m *itemMigration) GetTargetVersion() orm.VersionNumber {
+	return 2
+}
+
+func (m *itemMigration) Apply(db interface{}) error {
+	if !m.addColumn {
+		return nil
+	}
+	return db.(*gorm.DB).AutoMigrate(Item{}).Error
+}
+
+type MigrationHarnessTestSuite struct {
+	suite.Suite
+	Dir     string
+	Harness *MigrationHarness
+}
+
+func (s *MigrationHarnessTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "goroom_harness")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+
+	s.Harness = &MigrationHarness{
+		SnapshotDir: dir,
+		History: []VersionFixture{{
+			Version:  1,
+			Entities: []interface{}{OldItem{}},
+			Seed: func(db *gorm.DB) error {
+				return db.Create(&OldItem{ID: 1, Name: "bolt"}).Error
+			},
+			Verify: func(db *gorm.DB) error {
+				var item Item
+				return db.First(&item, 1).Error
+			},
+		}},
+		Entities:   []interface{}{Item{}},
+		Version:    2,
+		Migrations: []orm.Migration{&itemMigration{addColumn: true}},
+	}
+
+	db, err := openInMemoryDB()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	for _, fixture := range append(s.Harness.History, VersionFixture{Version: 2, Entities: []interface{}{Item{}}}) {
+		appDB, errList := room.New(fixture.Entities, adapter.NewGORM(db), fixture.Version, nil, new(adapter.EntityHashConstructor))
+		if len(errList) > 0 {
+			panic(errList)
+		}
+		if _, err = appDB.ExportSchemaSnapshot(dir); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (s *MigrationHarnessTestSuite) TearDownTest() {
+	os.RemoveAll(s.Dir)
+}
+
+func (s *MigrationHarnessTestSuite) TestRun() {
+	s.Harness.Run(s.T())
+}
+
+func (s *MigrationHarnessTestSuite) TestVerifyUpgradePathWithIncompleteMigration() {
+	s.Harness.Migrations = []orm.Migration{&itemMigration{addColumn: false}}
+
+	err := s.Harness.VerifyUpgradePath(s.Harness.History[0])
+	assert.NotNil(s.T(), err)
+	assert.True(s.T(), strings.Contains(err.Error(), "Table items is missing column quantity"), "Unexpected error %v", err)
+}
+
+func (s *MigrationHarnessTestSuite) TestVerifyUpgradePathWithLostFixtures() {
+	s.Harness.History[0].Seed = nil
+
+	err := s.Harness.VerifyUpgradePath(s.Harness.History[0])
+	assert.NotNil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(err.Error(), "Fixtures of version 1 did not survive migration to 2."), "Unexpected error %v", err)
+}
+
+func (s *MigrationHarnessTestSuite) TestVerifyUpgradePathWithEntitiesNotMatchingSnapshot() {
+	s.Harness.History[0].Entities = []interface{}{Item{}}
+
+	err := s.Harness.VerifyUpgradePath(s.Harness.History[0])
+	assert.NotNil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(err.Error(), "Fixture entities for version 1 do not match its snapshot."), "Unexpected error %v", err)
+}
+
+func (s *MigrationHarnessTestSuite) TestGetFixturesForSnapshotsWithMissingFixture() {
+	s.Harness.History = nil
+
+	_, err := s.Harness.GetFixturesForSnapshots()
+	assert.Equal(s.T(), fmt.Errorf("Schema snapshot exists for version %v but no fixture declares its entities", 1), err)
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(MigrationHarnessTestSuite))
+}
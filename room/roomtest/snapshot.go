@@ -0,0 +1,19 @@
+package roomtest
+
+import (
+	"testing"
+
+	"github.com/adonmo/goroom/room"
+)
+
+//AssertSchemaSnapshot Fails the test if entities of the room changed without a version upgrade or if the version has no exported snapshot
+func AssertSchemaSnapshot(t testing.TB, appDB *room.Room, dir string) bool {
+	t.Helper()
+
+	if err := appDB.VerifySchemaSnapshot(dir); err != nil {
+		t.Errorf("Schema snapshot verification failed. %v", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,37 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//getSchemaScopedORM ORM confined to the given schema. The ORM is returned as is if no schema is given
+func getSchemaScopedORM(dba orm.ORM, schema string) (orm.ORM, orm.SchemaScoper, error) {
+	if schema == "" || dba == nil {
+		return dba, nil, nil
+	}
+
+	scoper, ok := dba.(orm.SchemaScoper)
+	if !ok {
+		return nil, nil, fmt.Errorf("ORM does not support schemas. Unable to use schema %v", schema)
+	}
+
+	scoped, err := scoper.InSchema(schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to use schema %v. %v", schema, err)
+	}
+	return scoped, scoper, nil
+}
+
+func (appDB *Room) createSchema() error {
+	if appDB.schemaScoper == nil {
+		return nil
+	}
+
+	if err := appDB.schemaScoper.CreateSchema(appDB.schema).Error; err != nil {
+		appDB.log().Errorf("Unable to create schema %v. %v", appDB.schema, err)
+		return err
+	}
+	return nil
+}
@@ -1,28 +1,177 @@
 package room
 
 import (
-	"github.com/adonmo/goroom/logger"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+
 	"github.com/adonmo/goroom/orm"
 )
 
+const defaultSchemaMasterTableName = "go_room_schema_masters"
+
+var identifierPattern = regexp.MustCompile("^[a-z][a-z0-9_]*$")
+
 //GoRoomSchemaMaster Tracks the schema of entities against current version of DB
 type GoRoomSchemaMaster struct {
-	Version      orm.VersionNumber `gorm:"primary_key"`
-	IdentityHash string
+	Version             orm.VersionNumber `gorm:"primary_key"`
+	IdentityHash        string
+	EntityHashes        string `gorm:"type:text"` //JSON object with identity hash of each entity table
+	ResetTables         string `gorm:"type:text"` //JSON array of tables reset by selective destructive fallback
+	OrphanedTables      string `gorm:"type:text"` //JSON array of tables managed by earlier versions that are still present
+	RepeatableChecksums string `gorm:"type:text"` //JSON object with checksum of each repeatable migration applied
+
+	MinCompatibleVersion orm.VersionNumber //Oldest app version which can use the DB at this version without migrating it. Zero if only this version can
+	Checksum             string            //Detects modification of the row outside Room when an integrity policy is configured
+	tableName            string
+}
+
+//legacySchemaMaster Named like the schema master but without TableName so that the ORM derives its table name by its own
+//naming rules, as it did before namespaces e.g. go_room_schema_master with singular tables of GORM
+var legacySchemaMaster = func() interface{} {
+	type GoRoomSchemaMaster struct {
+		Version      orm.VersionNumber `gorm:"primary_key"`
+		IdentityHash string
+	}
+	return GoRoomSchemaMaster{}
+}()
+
+//TableName Name of the table backing the schema master. Rooms in a namespace get a table of their own
+func (master GoRoomSchemaMaster) TableName() string {
+	if master.tableName == "" {
+		return defaultSchemaMasterTableName
+	}
+	return master.tableName
+}
+
+func newSchemaMaster(dba orm.ORM, namespace string, tableName string) GoRoomSchemaMaster {
+	if tableName != "" {
+		return GoRoomSchemaMaster{
+			tableName: tableName,
+		}
+	}
+	if namespace == "" {
+		if derivedName := dba.GetModelDefinition(legacySchemaMaster).TableName; derivedName != defaultSchemaMasterTableName {
+			return GoRoomSchemaMaster{
+				tableName: derivedName,
+			}
+		}
+		return GoRoomSchemaMaster{}
+	}
+	return GoRoomSchemaMaster{
+		tableName: fmt.Sprintf("%v_%v", namespace, defaultSchemaMasterTableName),
+	}
+}
+
+func (master GoRoomSchemaMaster) withRecord(version orm.VersionNumber, identityHash string) GoRoomSchemaMaster {
+	master.Version = version
+	master.IdentityHash = identityHash
+	return master
+}
+
+func (master GoRoomSchemaMaster) withEntityHashes(entityHashes map[string]string) GoRoomSchemaMaster {
+	master.EntityHashes = encodeJSONColumn(entityHashes)
+	return master
+}
+
+func (master GoRoomSchemaMaster) withMinCompatibleVersion(version orm.VersionNumber) GoRoomSchemaMaster {
+	master.MinCompatibleVersion = version
+	return master
+}
+
+//GetRepeatableChecksums Checksum of each repeatable migration applied to the DB keyed by name
+func (master GoRoomSchemaMaster) GetRepeatableChecksums() (checksums map[string]string, err error) {
+	err = decodeJSONColumn(master.RepeatableChecksums, &checksums)
+	return
+}
+
+//GetEntityHashes Identity hash of each entity table as recorded for this version
+func (master GoRoomSchemaMaster) GetEntityHashes() (entityHashes map[string]string, err error) {
+	err = decodeJSONColumn(master.EntityHashes, &entityHashes)
+	return
+}
+
+//GetResetTables Tables that were reset by selective destructive fallback to reach this version
+func (master GoRoomSchemaMaster) GetResetTables() (tables []string, err error) {
+	err = decodeJSONColumn(master.ResetTables, &tables)
+	return
+}
+
+//GetOrphanedTables Tables managed by earlier versions which were still present when this version was reached
+func (master GoRoomSchemaMaster) GetOrphanedTables() (tables []string, err error) {
+	err = decodeJSONColumn(master.OrphanedTables, &tables)
+	return
+}
+
+func encodeJSONColumn(value interface{}) string {
+	reflected := reflect.ValueOf(value)
+	if !reflected.IsValid() || reflected.Len() == 0 {
+		return ""
+	}
+
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func decodeJSONColumn(column string, out interface{}) error {
+	if column == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column), out)
+}
+
+func isValidNamespace(namespace string) bool {
+	return namespace == "" || identifierPattern.MatchString(namespace)
 }
 
 func (appDB *Room) isSchemaMasterPresent() bool {
-	return appDB.dba.HasTable(GoRoomSchemaMaster{})
+	return appDB.dba.HasTable(appDB.schemaMaster)
 }
 
+//getRoomMetadataFromDB Reads the version and identity hash of the latest schema master row. ORMs read only the default schema
+//master table by themselves hence a custom named one is read through orm.RowLoader
 func (appDB *Room) getRoomMetadataFromDB() (*GoRoomSchemaMaster, error) {
+	if appDB.schemaMaster.tableName != "" {
+		record, err := appDB.getLatestRoomRecordFromDB()
+		if err != nil {
+			return nil, err
+		}
+		metadata := appDB.schemaMaster.withRecord(record.Version, record.IdentityHash)
+		return &metadata, nil
+	}
+
 	identityHash, version, err := appDB.dba.GetLatestSchemaIdentityHashAndVersion()
 	if err != nil {
-		logger.Errorf("Error while fetching room metadata from the DB. %v", err)
+		appDB.log().Errorf("Error while fetching room metadata from the DB. %v", err)
+		return nil, err
+	}
+	metadata := appDB.schemaMaster.withRecord(orm.VersionNumber(version), identityHash)
+	return &metadata, err
+}
+
+//getLatestRoomRecordFromDB Reads the schema master row with the highest version including per entity details
+func (appDB *Room) getLatestRoomRecordFromDB() (*GoRoomSchemaMaster, error) {
+	return appDB.getLatestRoomRecord(appDB.dba)
+}
+
+func (appDB *Room) getLatestRoomRecord(dba orm.ORM) (*GoRoomSchemaMaster, error) {
+	var records []GoRoomSchemaMaster
+	if err := findAll(dba, appDB.schemaMaster, &records); err != nil {
+		appDB.log().Errorf("Error while fetching room records from the DB. %v", err)
 		return nil, err
 	}
-	return &GoRoomSchemaMaster{
-		IdentityHash: identityHash,
-		Version:      orm.VersionNumber(version),
-	}, err
+	if len(records) < 1 {
+		return nil, fmt.Errorf("No records found in Room Schema Master")
+	}
+
+	latest := records[0]
+	for _, record := range records[1:] {
+		if record.Version > latest.Version {
+			latest = record
+		}
+	}
+	latest.tableName = appDB.schemaMaster.tableName
+
+	return &latest, nil
 }
@@ -21,7 +21,7 @@ func (s *SchemaMasterTestSuite) SetupTest() {
 }
 
 func (s *SchemaMasterTestSuite) TestIsSchemaMasterPresent() {
-	dba := mocks.NewMockORM(s.MockCtrl)
+	dba := newMockORM(s.MockCtrl)
 	appDB := &Room{
 		dba: dba,
 	}
@@ -34,7 +34,7 @@ func (s *SchemaMasterTestSuite) TestIsSchemaMasterPresent() {
 }
 
 func (s *SchemaMasterTestSuite) TestGetRoomMetadataFromDB() {
-	dba := mocks.NewMockORM(s.MockCtrl)
+	dba := newMockORM(s.MockCtrl)
 	appDB := &Room{
 		dba: dba,
 	}
@@ -54,8 +54,37 @@ func (s *SchemaMasterTestSuite) TestGetRoomMetadataFromDB() {
 	}
 }
 
+func (s *SchemaMasterTestSuite) TestGetRoomMetadataFromCustomTable() {
+	dba := newMockORM(s.MockCtrl)
+	loader := mocks.NewMockRowLoader(s.MockCtrl)
+	appDB := &Room{
+		dba:          &RowLoadingORM{MockORM: dba, MockRowLoader: loader},
+		schemaMaster: GoRoomSchemaMaster{tableName: "app_metadata"},
+	}
+
+	loader.EXPECT().FindAll(appDB.schemaMaster, gomock.Any()).SetArg(1, []GoRoomSchemaMaster{
+		{IdentityHash: "older", Version: 3},
+		{IdentityHash: "latest", Version: 4},
+	}).Return(orm.Result{})
+	got, err := appDB.getRoomMetadataFromDB()
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), &GoRoomSchemaMaster{tableName: "app_metadata", IdentityHash: "latest", Version: 4}, got)
+}
+
+func (s *SchemaMasterTestSuite) TestGetRoomMetadataFromCustomTableWithoutRowLoader() {
+	dba := newMockORM(s.MockCtrl)
+	appDB := &Room{
+		dba:          dba,
+		schemaMaster: GoRoomSchemaMaster{tableName: "go_room_schema_master"},
+	}
+
+	_, err := appDB.getRoomMetadataFromDB()
+	assert.Equal(s.T(), fmt.Errorf("ORM %T can not read the rows of %T. It should implement orm.RowLoader", dba, appDB.schemaMaster), err)
+}
+
 func (s *SchemaMasterTestSuite) TestGetRoomMetadataFromDBWithError() {
-	dba := mocks.NewMockORM(s.MockCtrl)
+	dba := newMockORM(s.MockCtrl)
 	appDB := &Room{
 		dba: dba,
 	}
@@ -0,0 +1,206 @@
+package room
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+const schemaSnapshotFileExtension = ".json"
+
+//SchemaSnapshot Definition of the entities managed by Room for a version as exported to a schema file
+type SchemaSnapshot struct {
+	Version      orm.VersionNumber `json:"version"`
+	IdentityHash string            `json:"identityHash"`
+	Entities     []EntitySnapshot  `json:"entities"`
+}
+
+//EntitySnapshot Definition of a single entity as seen by the ORM along with its identity hash
+type EntitySnapshot struct {
+	TableName    string         `json:"tableName"`
+	IdentityHash string         `json:"identityHash"`
+	Model        interface{}    `json:"model"`
+	Columns      []string       `json:"columns,omitempty"`
+	Table        *TableSnapshot `json:"table,omitempty"` //Nil in snapshots exported before it was recorded
+}
+
+//TableSnapshot Column types, indexes and foreign keys of the table of an entity as declared by the ORM.
+//Tells apart versions whose tables have the same columns
+type TableSnapshot struct {
+	ColumnTypes map[string]string          `json:"columnTypes,omitempty"` //Empty if the ORM can not tell the types the DB reports
+	Indexes     []orm.IndexDefinition      `json:"indexes"`
+	ForeignKeys []orm.ForeignKeyDefinition `json:"foreignKeys"`
+}
+
+//GetSchemaSnapshot Builds the schema snapshot for the entities and version of current Room instance
+func (appDB *Room) GetSchemaSnapshot() (*SchemaSnapshot, error) {
+	models := appDB.getSortedModelDefinitions()
+
+	entityHashArr, err := appDB.calculateEntityHashes(models)
+	if err != nil {
+		return nil, err
+	}
+
+	identityHash, err := appDB.calculateIdentityHashFromEntityHashes(entityHashArr)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &SchemaSnapshot{
+		Version:      appDB.version,
+		IdentityHash: identityHash,
+		Entities:     make([]EntitySnapshot, 0, len(models)),
+	}
+	for i, model := range models {
+		snapshot.Entities = append(snapshot.Entities, EntitySnapshot{
+			TableName:    model.TableName,
+			IdentityHash: entityHashArr[i],
+			Model:        model.EntityModel,
+			Columns:      model.Columns,
+			Table: &TableSnapshot{
+				ColumnTypes: model.ColumnTypes,
+				Indexes:     model.Indexes,
+				ForeignKeys: model.ForeignKeys,
+			},
+		})
+	}
+
+	return snapshot, nil
+}
+
+//ExportSchemaSnapshot Writes the schema snapshot for current version into the given directory and returns the file path
+func (appDB *Room) ExportSchemaSnapshot(dir string) (string, error) {
+	snapshot, err := appDB.GetSchemaSnapshot()
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := GetSchemaSnapshotFilePath(dir, appDB.version)
+	if err = ioutil.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return "", err
+	}
+
+	appDB.log().Infof("Exported schema snapshot for version %v to %v", appDB.version, path)
+	return path, nil
+}
+
+//VerifySchemaSnapshot Verifies that the snapshot stored for current version matches the entities of current Room instance
+func (appDB *Room) VerifySchemaSnapshot(dir string) error {
+	current, err := appDB.GetSchemaSnapshot()
+	if err != nil {
+		return err
+	}
+
+	stored, err := LoadSchemaSnapshot(dir, appDB.version)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("No schema snapshot found for version %v in %v. Looks like you changed the version but forgot to export the schema", appDB.version, dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if stored.IdentityHash != current.IdentityHash {
+		return fmt.Errorf("Schema snapshot mismatch for version %v. Looks like you changed entity definitions but forgot to upgrade version. Changed tables: %v",
+			appDB.version, getChangedTables(stored, current))
+	}
+
+	return nil
+}
+
+//GetSchemaSnapshotFilePath Path of the schema snapshot file for a version within the given directory
+func GetSchemaSnapshotFilePath(dir string, version orm.VersionNumber) string {
+	return filepath.Join(dir, fmt.Sprintf("%d%s", version, schemaSnapshotFileExtension))
+}
+
+//LoadSchemaSnapshot Reads the schema snapshot stored for a version from the given directory
+func LoadSchemaSnapshot(dir string, version orm.VersionNumber) (*SchemaSnapshot, error) {
+	content, err := ioutil.ReadFile(GetSchemaSnapshotFilePath(dir, version))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot SchemaSnapshot
+	if err = json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("Unable to parse schema snapshot for version %v. %v", version, err)
+	}
+
+	if snapshot.Version != version {
+		return nil, fmt.Errorf("Schema snapshot file for version %v declares version %v", version, snapshot.Version)
+	}
+
+	return &snapshot, nil
+}
+
+//LoadSchemaSnapshots Reads all schema snapshots stored in the given directory ordered by version
+func LoadSchemaSnapshots(dir string) ([]*SchemaSnapshot, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []orm.VersionNumber
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != schemaSnapshotFileExtension {
+			continue
+		}
+
+		version, err := strconv.ParseUint(strings.TrimSuffix(name, schemaSnapshotFileExtension), 10, 0)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, orm.VersionNumber(version))
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+
+	snapshots := make([]*SchemaSnapshot, 0, len(versions))
+	for _, version := range versions {
+		snapshot, err := LoadSchemaSnapshot(dir, version)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+func getChangedTables(stored *SchemaSnapshot, current *SchemaSnapshot) (changed []string) {
+	storedHashes := make(map[string]string)
+	for _, entity := range stored.Entities {
+		storedHashes[entity.TableName] = entity.IdentityHash
+	}
+
+	for _, entity := range current.Entities {
+		if hash, ok := storedHashes[entity.TableName]; !ok || hash != entity.IdentityHash {
+			changed = append(changed, entity.TableName)
+		}
+		delete(storedHashes, entity.TableName)
+	}
+
+	for tableName := range storedHashes {
+		changed = append(changed, tableName)
+	}
+	sort.Strings(changed)
+
+	return
+}
@@ -0,0 +1,145 @@
+package room
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaSnapshotTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	DBA          *mocks.MockORM
+	IdentityCalc *mocks.MockIdentityHashCalculator
+	AppDB        *Room
+	Dir          string
+}
+
+func (s *SchemaSnapshotTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.IdentityCalc = mocks.NewMockIdentityHashCalculator(s.MockCtrl)
+	s.AppDB = &Room{
+		entities:           []interface{}{DummyTable{}, AnotherDummyTable{}},
+		version:            orm.VersionNumber(2),
+		dba:                s.DBA,
+		identityCalculator: s.IdentityCalc,
+	}
+
+	dir, err := ioutil.TempDir("", "goroom_snapshots")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+
+	s.DBA.EXPECT().GetModelDefinition(DummyTable{}).Return(orm.ModelDefinition{
+		TableName:   "dummy_table",
+		EntityModel: MockEntityModel{Fields: []string{"id", "value"}},
+	}).AnyTimes()
+	s.DBA.EXPECT().GetModelDefinition(AnotherDummyTable{}).Return(orm.ModelDefinition{
+		TableName:   "another_dummy_table",
+		EntityModel: MockEntityModel{Fields: []string{"num", "text"}},
+	}).AnyTimes()
+}
+
+func (s *SchemaSnapshotTestSuite) TearDownTest() {
+	os.RemoveAll(s.Dir)
+}
+
+func (s *SchemaSnapshotTestSuite) expectHashes(identityHash string) {
+	s.IdentityCalc.EXPECT().ConstructHash(MockEntityModel{Fields: []string{"num", "text"}}).Return("another_hash", nil).AnyTimes()
+	s.IdentityCalc.EXPECT().ConstructHash(MockEntityModel{Fields: []string{"id", "value"}}).Return("dummy_hash", nil).AnyTimes()
+	s.IdentityCalc.EXPECT().ConstructHash([]string{"another_hash", "dummy_hash"}).Return(identityHash, nil).AnyTimes()
+}
+
+func (s *SchemaSnapshotTestSuite) TestGetSchemaSnapshot() {
+	s.expectHashes("identity")
+
+	snapshot, err := s.AppDB.GetSchemaSnapshot()
+	expected := &SchemaSnapshot{
+		Version:      2,
+		IdentityHash: "identity",
+		Entities: []EntitySnapshot{
+			{TableName: "another_dummy_table", IdentityHash: "another_hash", Model: MockEntityModel{Fields: []string{"num", "text"}}, Table: &TableSnapshot{}},
+			{TableName: "dummy_table", IdentityHash: "dummy_hash", Model: MockEntityModel{Fields: []string{"id", "value"}}, Table: &TableSnapshot{}},
+		},
+	}
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, snapshot)
+}
+
+func (s *SchemaSnapshotTestSuite) TestGetSchemaSnapshotWithHashError() {
+	s.IdentityCalc.EXPECT().ConstructHash(gomock.Any()).Return("", fmt.Errorf("Hashing failed"))
+
+	_, err := s.AppDB.GetSchemaSnapshot()
+	assert.Equal(s.T(), fmt.Errorf("Error while calculating identity hash for Table %v", "another_dummy_table"), err)
+}
+
+func (s *SchemaSnapshotTestSuite) TestExportAndLoadSchemaSnapshot() {
+	s.expectHashes("identity")
+
+	path, err := s.AppDB.ExportSchemaSnapshot(s.Dir)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), GetSchemaSnapshotFilePath(s.Dir, 2), path)
+
+	loaded, err := LoadSchemaSnapshot(s.Dir, 2)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), orm.VersionNumber(2), loaded.Version)
+	assert.Equal(s.T(), "identity", loaded.IdentityHash)
+	assert.Equal(s.T(), 2, len(loaded.Entities))
+	assert.Equal(s.T(), "another_dummy_table", loaded.Entities[0].TableName)
+
+	assert.Nil(s.T(), s.AppDB.VerifySchemaSnapshot(s.Dir))
+}
+
+func (s *SchemaSnapshotTestSuite) TestLoadSchemaSnapshots() {
+	s.expectHashes("identity")
+
+	for _, version := range []orm.VersionNumber{10, 2, 1} {
+		s.AppDB.version = version
+		_, err := s.AppDB.ExportSchemaSnapshot(s.Dir)
+		assert.Nil(s.T(), err)
+	}
+	assert.Nil(s.T(), ioutil.WriteFile(s.Dir+"/README.md", []byte("Not a snapshot"), 0644))
+
+	snapshots, err := LoadSchemaSnapshots(s.Dir)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 3, len(snapshots))
+	assert.Equal(s.T(), []orm.VersionNumber{1, 2, 10}, []orm.VersionNumber{snapshots[0].Version, snapshots[1].Version, snapshots[2].Version})
+}
+
+func (s *SchemaSnapshotTestSuite) TestLoadSchemaSnapshotWithVersionMismatch() {
+	assert.Nil(s.T(), ioutil.WriteFile(GetSchemaSnapshotFilePath(s.Dir, 3), []byte(`{"version": 4}`), 0644))
+
+	_, err := LoadSchemaSnapshot(s.Dir, 3)
+	assert.Equal(s.T(), fmt.Errorf("Schema snapshot file for version %v declares version %v", 3, 4), err)
+}
+
+func (s *SchemaSnapshotTestSuite) TestVerifySchemaSnapshotWithMissingSnapshot() {
+	s.expectHashes("identity")
+
+	err := s.AppDB.VerifySchemaSnapshot(s.Dir)
+	assert.Equal(s.T(), fmt.Errorf("No schema snapshot found for version %v in %v. Looks like you changed the version but forgot to export the schema", 2, s.Dir), err)
+}
+
+func (s *SchemaSnapshotTestSuite) TestVerifySchemaSnapshotWithChangedEntities() {
+	stored := `{"version": 2, "identityHash": "old_identity", "entities": [
+		{"tableName": "another_dummy_table", "identityHash": "another_hash"},
+		{"tableName": "dummy_table", "identityHash": "old_dummy_hash"},
+		{"tableName": "removed_table", "identityHash": "removed_hash"}
+	]}`
+	assert.Nil(s.T(), ioutil.WriteFile(GetSchemaSnapshotFilePath(s.Dir, 2), []byte(stored), 0644))
+	s.expectHashes("identity")
+
+	err := s.AppDB.VerifySchemaSnapshot(s.Dir)
+	expectedError := fmt.Errorf("Schema snapshot mismatch for version %v. Looks like you changed entity definitions but forgot to upgrade version. Changed tables: %v",
+		2, []string{"dummy_table", "removed_table"})
+	assert.Equal(s.T(), expectedError, err)
+}
@@ -0,0 +1,117 @@
+package room
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/orm/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScopableORM struct {
+	*mocks.MockORM
+	*mocks.MockSchemaScoper
+}
+
+type SchemaTestSuite struct {
+	suite.Suite
+	MockCtrl  *gomock.Controller
+	DBA       *mocks.MockORM
+	Scoper    *mocks.MockSchemaScoper
+	TenantDBA *mocks.MockORM
+	Locker    *mocks.MockLocker
+	Config    Config
+}
+
+func (s *SchemaTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.DBA = newMockORM(s.MockCtrl)
+	s.Scoper = mocks.NewMockSchemaScoper(s.MockCtrl)
+	s.TenantDBA = newMockORM(s.MockCtrl)
+	s.Locker = mocks.NewMockLocker(s.MockCtrl)
+	s.Config = Config{
+		Entities:           []interface{}{DummyTable{}},
+		DBA:                &ScopableORM{s.DBA, s.Scoper},
+		Version:            3,
+		IdentityCalculator: mocks.NewMockIdentityHashCalculator(s.MockCtrl),
+		Locker:             s.Locker,
+		Logger:             &RecordingLogger{},
+		Schema:             "tenant_1",
+	}
+}
+
+func (s *SchemaTestSuite) TestNewFromConfigWithSchema() {
+	s.Scoper.EXPECT().InSchema("tenant_1").Return(s.TenantDBA, nil)
+
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Empty(s.T(), errList)
+	assert.Equal(s.T(), s.TenantDBA, appDB.dba, "Room should work with the ORM confined to the schema")
+}
+
+func (s *SchemaTestSuite) TestNewFromConfigWithSchemaUnsupportedByORM() {
+	s.Config.DBA = s.DBA
+
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), []error{fmt.Errorf("ORM does not support schemas. Unable to use schema tenant_1")}, errList)
+}
+
+func (s *SchemaTestSuite) TestNewFromConfigWithSchemaRefusedByORM() {
+	s.Scoper.EXPECT().InSchema("tenant_1").Return(nil, fmt.Errorf("Schemas are not supported for dialect sqlite3"))
+
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), []error{fmt.Errorf("Unable to use schema tenant_1. Schemas are not supported for dialect sqlite3")}, errList)
+}
+
+func (s *SchemaTestSuite) TestNewFromConfigWithInvalidSchema() {
+	s.Config.Schema = `x"; DROP TABLE devices; --`
+
+	appDB, errList := NewFromConfig(s.Config)
+
+	assert.Nil(s.T(), appDB)
+	assert.Equal(s.T(), []error{fmt.Errorf("Schema %v is invalid. Only lower case letters, digits and underscores are allowed", s.Config.Schema)}, errList)
+}
+
+func (s *SchemaTestSuite) TestInitCreatesSchemaBeforeLocking() {
+	s.Scoper.EXPECT().InSchema("tenant_1").Return(s.TenantDBA, nil)
+	appDB, _ := NewFromConfig(s.Config)
+	expectedError := fmt.Errorf("Lock is busy")
+	gomock.InOrder(
+		s.Scoper.EXPECT().CreateSchema("tenant_1").Return(orm.Result{}),
+		s.Locker.EXPECT().Lock().Return(expectedError),
+	)
+
+	shouldRetry, err := appDB.Init("identity")
+
+	assert.False(s.T(), shouldRetry)
+	assert.Equal(s.T(), expectedError, err)
+}
+
+func (s *SchemaTestSuite) TestInitWithFailingSchemaCreation() {
+	s.Scoper.EXPECT().InSchema("tenant_1").Return(s.TenantDBA, nil)
+	appDB, _ := NewFromConfig(s.Config)
+	expectedError := fmt.Errorf("Permission denied")
+	s.Scoper.EXPECT().CreateSchema("tenant_1").Return(orm.Result{Error: expectedError})
+
+	shouldRetry, err := appDB.Init("identity")
+
+	assert.False(s.T(), shouldRetry, "Destruction can not help creating a schema")
+	assert.Equal(s.T(), expectedError, err)
+}
+
+func (s *SchemaTestSuite) TestReadOnlyInitDoesNotCreateSchema() {
+	s.Config.ReadOnly = true
+	s.Scoper.EXPECT().InSchema("tenant_1").Return(s.TenantDBA, nil)
+	appDB, _ := NewFromConfig(s.Config)
+	s.TenantDBA.EXPECT().HasTable(appDB.schemaMaster).Return(false)
+
+	_, err := appDB.Init("identity")
+
+	assert.NotNil(s.T(), err, "Missing schema master should be reported by a read only Room")
+}
@@ -0,0 +1,67 @@
+package goroom
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/room"
+)
+
+//TenantFailurePolicy Decides whether tenants left are initialized once initialization of a tenant fails
+type TenantFailurePolicy int
+
+const (
+	//ContinueOnTenantFailure Every tenant is initialized irrespective of failures of others
+	ContinueOnTenantFailure TenantFailurePolicy = iota
+	//HaltOnTenantFailure Tenants after the one failing are not initialized
+	HaltOnTenantFailure
+)
+
+//TenantResult Outcome of initializing the schema of a tenant
+type TenantResult struct {
+	Schema string
+	Result *room.InitResult //Nil if the Room of the tenant could not be created
+	Error  error
+}
+
+//InitializeTenants Initialize a Room confined to each of the given schemas with the same entities and migrations.
+//The config is used as is for every tenant except for its schema. Results are in the order of the schemas initialized
+func InitializeTenants(config room.Config, schemas []string, policy TenantFailurePolicy) ([]TenantResult, error) {
+	return initializeTenants(schemas, policy, func(schema string) (room.ConfiguredInitializer, error) {
+		tenantConfig := config
+		tenantConfig.Schema = schema
+		appDB, errList := room.NewFromConfig(tenantConfig)
+		if len(errList) > 0 {
+			return nil, fmt.Errorf("Unable to create Room. %v", errList)
+		}
+		return appDB, nil
+	})
+}
+
+func initializeTenants(schemas []string, policy TenantFailurePolicy,
+	newInitializer func(schema string) (room.ConfiguredInitializer, error)) ([]TenantResult, error) {
+
+	results := make([]TenantResult, 0, len(schemas))
+	var failed []string
+	for _, schema := range schemas {
+		tenant := TenantResult{Schema: schema}
+		initializer, err := newInitializer(schema)
+		if err == nil {
+			tenant.Result, err = Initialize(initializer)
+		}
+		tenant.Error = err
+		results = append(results, tenant)
+
+		if err == nil {
+			continue
+		}
+		if policy == HaltOnTenantFailure {
+			return results, fmt.Errorf("Initialization of tenant %v failed. %v", schema, err)
+		}
+		failed = append(failed, schema)
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("Initialization of tenants %v failed", failed)
+	}
+	return results, nil
+}
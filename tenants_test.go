@@ -0,0 +1,97 @@
+package goroom
+
+import (
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+	ormmocks "github.com/adonmo/goroom/orm/mocks"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/room/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TenantInitializationTestSuite struct {
+	suite.Suite
+	MockCtrl     *gomock.Controller
+	Initializers map[string]*mocks.MockConfiguredInitializer
+}
+
+func (s *TenantInitializationTestSuite) SetupTest() {
+	s.MockCtrl = gomock.NewController(s.T())
+	s.Initializers = make(map[string]*mocks.MockConfiguredInitializer)
+	for _, schema := range []string{"tenant_1", "tenant_2", "tenant_3"} {
+		initializer := mocks.NewMockConfiguredInitializer(s.MockCtrl)
+		initializer.EXPECT().GetDestructiveFallbackPolicy().Return(room.NoDestructiveFallback).AnyTimes()
+		s.Initializers[schema] = initializer
+	}
+}
+
+func (s *TenantInitializationTestSuite) newInitializer(schema string) (room.ConfiguredInitializer, error) {
+	if initializer, ok := s.Initializers[schema]; ok {
+		return initializer, nil
+	}
+	return nil, fmt.Errorf("Unknown tenant %v", schema)
+}
+
+func (s *TenantInitializationTestSuite) expectInit(schema string, err error) {
+	s.Initializers[schema].EXPECT().CalculateIdentityHash().Return("identity", nil)
+	s.Initializers[schema].EXPECT().Init("identity").Return(false, err)
+}
+
+func (s *TenantInitializationTestSuite) TestContinueOnTenantFailure() {
+	initError := fmt.Errorf("Error during initialization")
+	s.expectInit("tenant_1", nil)
+	s.expectInit("tenant_2", initError)
+	s.expectInit("tenant_3", nil)
+
+	results, err := initializeTenants([]string{"tenant_1", "tenant_2", "tenant_4", "tenant_3"}, ContinueOnTenantFailure, s.newInitializer)
+
+	assert.Equal(s.T(), fmt.Errorf("Initialization of tenants [tenant_2 tenant_4] failed"), err)
+	assert.Len(s.T(), results, 4)
+	assert.Nil(s.T(), results[0].Error)
+	assert.NotNil(s.T(), results[0].Result)
+	assert.Equal(s.T(), TenantResult{Schema: "tenant_2", Result: results[1].Result, Error: initError}, results[1])
+	assert.Equal(s.T(), TenantResult{Schema: "tenant_4", Error: fmt.Errorf("Unknown tenant tenant_4")}, results[2])
+	assert.Equal(s.T(), "tenant_3", results[3].Schema)
+	assert.Nil(s.T(), results[3].Error)
+}
+
+func (s *TenantInitializationTestSuite) TestHaltOnTenantFailure() {
+	initError := fmt.Errorf("Error during initialization")
+	s.expectInit("tenant_1", nil)
+	s.expectInit("tenant_2", initError)
+
+	results, err := initializeTenants([]string{"tenant_1", "tenant_2", "tenant_3"}, HaltOnTenantFailure, s.newInitializer)
+
+	assert.Equal(s.T(), fmt.Errorf("Initialization of tenant tenant_2 failed. Error during initialization"), err)
+	assert.Len(s.T(), results, 2, "Tenants after the failing one should not be initialized")
+}
+
+func (s *TenantInitializationTestSuite) TestAllTenantsInitialized() {
+	s.expectInit("tenant_1", nil)
+	s.expectInit("tenant_2", nil)
+
+	results, err := initializeTenants([]string{"tenant_1", "tenant_2"}, HaltOnTenantFailure, s.newInitializer)
+
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), results, 2)
+}
+
+func (s *TenantInitializationTestSuite) TestInitializeTenantsWithORMNotSupportingSchemas() {
+	config := room.Config{
+		Entities:           []interface{}{struct{}{}},
+		DBA:                ormmocks.NewMockORM(s.MockCtrl),
+		Version:            orm.VersionNumber(1),
+		IdentityCalculator: ormmocks.NewMockIdentityHashCalculator(s.MockCtrl),
+	}
+
+	results, err := InitializeTenants(config, []string{"tenant_1"}, ContinueOnTenantFailure)
+
+	assert.Equal(s.T(), fmt.Errorf("Initialization of tenants [tenant_1] failed"), err)
+	assert.Equal(s.T(), []TenantResult{{
+		Schema: "tenant_1",
+		Error:  fmt.Errorf("Unable to create Room. [ORM does not support schemas. Unable to use schema tenant_1]"),
+	}}, results)
+}
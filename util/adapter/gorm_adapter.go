@@ -1,7 +1,10 @@
 package adapter
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"go/ast"
 
@@ -10,6 +13,11 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+//referentialActions Actions allowed in the constraint tag of an association
+var referentialActions = map[string]bool{"CASCADE": true, "SET NULL": true, "SET DEFAULT": true, "RESTRICT": true, "NO ACTION": true}
+
+var sqliteColumnConstraints = []string{" constraint ", " primary key", " not null", " null", " unique", " check", " default", " collate", " references", " generated", " as "}
+
 //GORMField Representation
 type GORMField struct {
 	Name string
@@ -38,11 +46,78 @@ func (adapter *GORMAdapter) HasTable(entity interface{}) bool {
 	return adapter.db.HasTable(entity)
 }
 
-//CreateTable Create a Table
+//CreateTable Create a Table along with its indexes and foreign keys. Tables referenced should be created first
 func (adapter *GORMAdapter) CreateTable(entities ...interface{}) orm.Result {
-	return orm.Result{
-		Error: adapter.db.CreateTable(entities...).Error,
+	for _, entity := range entities {
+		foreignKeys, err := adapter.getForeignKeys(adapter.db.NewScope(entity))
+		if err != nil {
+			return orm.Result{Error: err}
+		}
+		if len(foreignKeys) < 1 {
+			if err := adapter.db.CreateTable(entity).Error; err != nil {
+				return orm.Result{Error: err}
+			}
+			continue
+		}
+		if err := adapter.createTableWithForeignKeys(entity, foreignKeys); err != nil {
+			return orm.Result{Error: err}
+		}
+	}
+	return orm.Result{}
+}
+
+//createTableWithForeignKeys Creates the table the way GORM does with the foreign keys as part of its definition, since SQLite can not
+//add them to an existing table. AutoMigrate of GORM then adds the indexes and join tables of the entity
+func (adapter *GORMAdapter) createTableWithForeignKeys(entity interface{}, foreignKeys []orm.ForeignKeyDefinition) error {
+	scope := adapter.db.NewScope(entity)
+	dialect := adapter.db.Dialect()
+	quoteAll := func(names []string) string {
+		quoted := make([]string, 0, len(names))
+		for _, name := range names {
+			quoted = append(quoted, dialect.Quote(name))
+		}
+		return strings.Join(quoted, ",")
+	}
+
+	var definitions, primaryKeys []string
+	primaryKeyInColumnType := false
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsNormal {
+			sqlTag := dialect.DataTypeOf(field)
+			if strings.Contains(strings.ToLower(sqlTag), "primary key") {
+				primaryKeyInColumnType = true
+			}
+			definitions = append(definitions, dialect.Quote(field.DBName)+" "+sqlTag)
+		}
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, field.DBName)
+		}
+	}
+	if len(primaryKeys) > 0 && !primaryKeyInColumnType {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%v)", quoteAll(primaryKeys)))
+	}
+	for _, foreignKey := range foreignKeys {
+		definition := fmt.Sprintf("FOREIGN KEY (%v) REFERENCES %v(%v)",
+			quoteAll(foreignKey.Columns), dialect.Quote(foreignKey.ReferencedTable), quoteAll(foreignKey.ReferencedColumns))
+		if foreignKey.OnDelete != "" {
+			definition += " ON DELETE " + foreignKey.OnDelete
+		}
+		if foreignKey.OnUpdate != "" {
+			definition += " ON UPDATE " + foreignKey.OnUpdate
+		}
+		definitions = append(definitions, definition)
+	}
+
+	var tableOptions string
+	if options, ok := adapter.db.Get("gorm:table_options"); ok {
+		tableOptions = fmt.Sprintf(" %v", options)
+	}
+
+	err := adapter.db.Exec(fmt.Sprintf("CREATE TABLE %v (%v)%v", scope.QuotedTableName(), strings.Join(definitions, ","), tableOptions)).Error
+	if err != nil {
+		return err
 	}
+	return adapter.db.AutoMigrate(entity).Error
 }
 
 //TruncateTable Delete All Values from table
@@ -66,6 +141,14 @@ func (adapter *GORMAdapter) DropTable(entities ...interface{}) orm.Result {
 	}
 }
 
+//RenameTable Rename a table
+func (adapter *GORMAdapter) RenameTable(from string, to string) orm.Result {
+	dialect := adapter.db.Dialect()
+	return orm.Result{
+		Error: adapter.db.Exec(fmt.Sprintf("ALTER TABLE %v RENAME TO %v", dialect.Quote(from), dialect.Quote(to))).Error,
+	}
+}
+
 //GetModelDefinition Get representation of a database table(entity) as done by ORM
 func (adapter *GORMAdapter) GetModelDefinition(entity interface{}) (modelDefinition orm.ModelDefinition) {
 	if entity == nil {
@@ -91,13 +174,134 @@ func (adapter *GORMAdapter) GetModelDefinition(entity interface{}) (modelDefinit
 		}
 	}
 
-	model := adapter.db.NewScope(entity).GetModelStruct()
+	scope := adapter.db.NewScope(entity)
+	model := scope.GetModelStruct()
+	//Malformed constraint tags fail CreateTable
+	foreignKeys, _ := adapter.getForeignKeys(scope)
+	var columns []string
+	var columnTypes map[string]string
+	//SQLite reports a column with the type it was declared with unlike other dialects e.g. character varying of Postgres for varchar(255)
+	if adapter.db.Dialect().GetName() == "sqlite3" {
+		columnTypes = make(map[string]string)
+	}
+	for _, field := range model.StructFields {
+		if field.IsNormal && !field.IsIgnored {
+			columns = append(columns, field.DBName)
+			if columnTypes != nil {
+				columnTypes[field.DBName] = getSQLiteDeclaredType(adapter.db.Dialect().DataTypeOf(field))
+			}
+		}
+	}
+
 	return orm.ModelDefinition{
 		EntityModel: &GORMEntityModel{
 			Fields: fields,
 		},
-		TableName: model.TableName(adapter.db),
+		TableName:   model.TableName(adapter.db),
+		Columns:     columns,
+		ColumnTypes: columnTypes,
+		Indexes:     adapter.getIndexes(scope),
+		ForeignKeys: foreignKeys,
+	}
+}
+
+//getSQLiteDeclaredType Type of a column definition as SQLite records it, which ends where its constraints begin
+func getSQLiteDeclaredType(definition string) string {
+	declaredType := strings.ToLower(definition)
+	for _, constraint := range sqliteColumnConstraints {
+		if position := strings.Index(declaredType, constraint); position >= 0 {
+			declaredType = declaredType[:position]
+		}
 	}
+	return strings.TrimSpace(definition[:len(declaredType)])
+}
+
+//getIndexes Indexes declared with index and unique_index tags named the way GORM names them
+func (adapter *GORMAdapter) getIndexes(scope *gorm.Scope) []orm.IndexDefinition {
+	dialect := adapter.db.Dialect()
+	indexes := make(map[string]*orm.IndexDefinition)
+	addIndex := func(field *gorm.StructField, setting string, prefix string, unique bool) {
+		value, ok := field.TagSettingsGet(setting)
+		if !ok {
+			return
+		}
+
+		for _, name := range strings.Split(value, ",") {
+			if name == setting || name == "" {
+				name = dialect.BuildKeyName(prefix, scope.TableName(), field.DBName)
+			}
+			name, column := dialect.NormalizeIndexAndColumn(name, field.DBName)
+			if indexes[name] == nil {
+				indexes[name] = &orm.IndexDefinition{Name: name, Unique: unique}
+			}
+			indexes[name].Columns = append(indexes[name].Columns, column)
+		}
+	}
+
+	for _, field := range scope.GetStructFields() {
+		addIndex(field, "INDEX", "idx", false)
+		addIndex(field, "UNIQUE_INDEX", "uix", true)
+	}
+
+	var definitions []orm.IndexDefinition
+	for _, index := range indexes {
+		definitions = append(definitions, *index)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+//getForeignKeys Foreign keys of belongs to associations opting in with the constraint tag e.g. `gorm:"constraint"` or
+//`gorm:"constraint:OnDelete:CASCADE,OnUpdate:RESTRICT"`. Like GORM, other associations get no foreign key
+func (adapter *GORMAdapter) getForeignKeys(scope *gorm.Scope) (foreignKeys []orm.ForeignKeyDefinition, err error) {
+	for _, field := range scope.GetStructFields() {
+		if field.Relationship == nil || field.Relationship.Kind != "belongs_to" {
+			continue
+		}
+		constraint, ok := field.TagSettingsGet("CONSTRAINT")
+		if !ok {
+			continue
+		}
+
+		foreignKey := orm.ForeignKeyDefinition{
+			Columns:           field.Relationship.ForeignDBNames,
+			ReferencedTable:   adapter.db.NewScope(reflect.New(field.Struct.Type).Interface()).TableName(),
+			ReferencedColumns: field.Relationship.AssociationForeignDBNames,
+		}
+		if constraint != "CONSTRAINT" {
+			if parseErr := parseReferentialActions(constraint, &foreignKey); parseErr != nil && err == nil {
+				err = fmt.Errorf("Constraint of field %v of %v is invalid. %v", field.Name, scope.TableName(), parseErr)
+			}
+		}
+		foreignKeys = append(foreignKeys, foreignKey)
+	}
+	return
+}
+
+//parseReferentialActions Reads actions of a constraint tag like OnDelete:CASCADE,OnUpdate:SET NULL into the foreign key
+func parseReferentialActions(constraint string, foreignKey *orm.ForeignKeyDefinition) error {
+	for _, setting := range strings.Split(constraint, ",") {
+		parts := strings.SplitN(setting, ":", 2)
+		if len(parts) < 2 {
+			return fmt.Errorf("Expected OnDelete:<action> or OnUpdate:<action> instead of %v", setting)
+		}
+
+		action := strings.ToUpper(strings.TrimSpace(parts[1]))
+		if !referentialActions[action] {
+			return fmt.Errorf("Referential action %v is not supported", parts[1])
+		}
+		switch strings.ToUpper(strings.TrimSpace(parts[0])) {
+		case "ONDELETE":
+			foreignKey.OnDelete = action
+		case "ONUPDATE":
+			foreignKey.OnUpdate = action
+		default:
+			return fmt.Errorf("Expected OnDelete:<action> or OnUpdate:<action> instead of %v", setting)
+		}
+	}
+	return nil
 }
 
 //GetUnderlyingORM Get the underlying ORM for advanced usage
@@ -105,6 +309,21 @@ func (adapter *GORMAdapter) GetUnderlyingORM() interface{} {
 	return adapter.db
 }
 
+//AutoMigrate Add missing columns to tables of given entities
+func (adapter *GORMAdapter) AutoMigrate(entities ...interface{}) orm.Result {
+	return orm.Result{
+		Error: adapter.db.AutoMigrate(entities...).Error,
+	}
+}
+
+//FindAll Load all rows of the table backing an entity
+func (adapter *GORMAdapter) FindAll(entity interface{}, out interface{}) orm.Result {
+	tableName := adapter.db.NewScope(entity).TableName()
+	return orm.Result{
+		Error: adapter.db.Table(tableName).Find(out).Error,
+	}
+}
+
 //GetLatestSchemaIdentityHashAndVersion Query the latest schema master entry
 func (adapter *GORMAdapter) GetLatestSchemaIdentityHashAndVersion() (identityHash string, version int, err error) {
 	var latest room.GoRoomSchemaMaster
@@ -115,6 +334,10 @@ func (adapter *GORMAdapter) GetLatestSchemaIdentityHashAndVersion() (identityHas
 //DoInTransaction Perform operations specified in the input function in a transaction
 func (adapter *GORMAdapter) DoInTransaction(fc func(tx orm.ORM) error) (err error) {
 	gormTxFunc := func(tx *gorm.DB) error {
+		//GORM calls the function even when the transaction could not begin e.g. on a file which is not a database
+		if tx.Error != nil {
+			return tx.Error
+		}
 		return fc(NewGORM(tx))
 	}
 
@@ -1,10 +1,12 @@
 package adapter
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/adonmo/goroom/orm"
 	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/batch"
 	"github.com/go-test/deep"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
@@ -28,6 +30,41 @@ type AnotherDummyTable struct {
 	Text string
 }
 
+type IndexedTable struct {
+	ID      int    `gorm:"primary_key"`
+	Code    string `gorm:"unique_index"`
+	Region  string `gorm:"index:idx_region_zone"`
+	Zone    string `gorm:"index:idx_region_zone"`
+	DummyID int
+	Dummy   DummyTable `gorm:"constraint"`
+}
+
+type CascadingTable struct {
+	ID      int `gorm:"primary_key"`
+	DummyID int
+	Dummy   DummyTable `gorm:"constraint:OnDelete:CASCADE,OnUpdate:SET NULL"`
+}
+
+type UnconstrainedTable struct {
+	ID      int `gorm:"primary_key"`
+	DummyID int
+	Dummy   DummyTable
+}
+
+type MisconstrainedTable struct {
+	ID      int `gorm:"primary_key"`
+	DummyID int
+	Dummy   DummyTable `gorm:"constraint:OnDelete:DROP"`
+}
+
+type CustomSchemaMaster struct {
+	room.GoRoomSchemaMaster
+}
+
+func (CustomSchemaMaster) TableName() string {
+	return "custom_schema_masters"
+}
+
 func (suite *IntegrationTestSuite) SetupTest() {
 	db, err := gorm.Open("sqlite3", ":memory:")
 	if err != nil {
@@ -146,7 +183,9 @@ func (suite *IntegrationTestSuite) TestGetModelDefinition() {
 		EntityModel: &GORMEntityModel{
 			Fields: fields,
 		},
-		TableName: expectedModel.TableName(suite.DB),
+		TableName:   expectedModel.TableName(suite.DB),
+		Columns:     []string{"id", "value"},
+		ColumnTypes: map[string]string{"id": "integer", "value": "varchar(255)"},
 	}
 
 	got := suite.Adapter.GetModelDefinition(DummyTable{})
@@ -160,10 +199,40 @@ func (suite *IntegrationTestSuite) TestGetModelDefinition() {
 	assert.Equal(suite.T(), suite.Adapter.GetModelDefinition(&DummyTable{}), expectedOutput)
 }
 
+func (suite *IntegrationTestSuite) TestColumnTypesMatchIntrospection() {
+	type TypedTable struct {
+		ID      uint   `gorm:"primary_key"`
+		Code    string `gorm:"type:varchar(8);not null;unique"`
+		Balance int    `gorm:"default:0"`
+		Note    string `sql:"type:text"`
+	}
+	suite.Adapter.CreateTable(TypedTable{})
+
+	columns, err := suite.Adapter.(orm.SchemaIntrospector).GetColumns("typed_tables")
+	assert.Nil(suite.T(), err)
+	reported := make(map[string]string)
+	for _, column := range columns {
+		reported[column.Name] = column.Type
+	}
+	assert.Equal(suite.T(), reported, suite.Adapter.GetModelDefinition(TypedTable{}).ColumnTypes)
+}
+
+func (suite *IntegrationTestSuite) TestGetModelDefinitionWithIndexesAndForeignKeys() {
+	got := suite.Adapter.GetModelDefinition(IndexedTable{})
+
+	assert.Equal(suite.T(), []orm.IndexDefinition{
+		{Name: "idx_region_zone", Columns: []string{"region", "zone"}},
+		{Name: "uix_indexed_tables_code", Columns: []string{"code"}, Unique: true},
+	}, got.Indexes)
+	assert.Equal(suite.T(), []orm.ForeignKeyDefinition{
+		{Columns: []string{"dummy_id"}, ReferencedTable: "dummy_tables", ReferencedColumns: []string{"id"}},
+	}, got.ForeignKeys)
+}
+
 func (suite *IntegrationTestSuite) TestGetModelDefinitionWithBadInput() {
 
-	assert.True(suite.T(), suite.Adapter.GetModelDefinition(nil) == orm.ModelDefinition{})
-	assert.True(suite.T(), suite.Adapter.GetModelDefinition([]string{"abc"}) == orm.ModelDefinition{})
+	assert.Equal(suite.T(), orm.ModelDefinition{}, suite.Adapter.GetModelDefinition(nil))
+	assert.Equal(suite.T(), orm.ModelDefinition{}, suite.Adapter.GetModelDefinition([]string{"abc"}))
 }
 
 func (suite *IntegrationTestSuite) TestGetUnderlyingORM() {
@@ -203,6 +272,188 @@ func (suite *IntegrationTestSuite) TestGetLatestSchemaIdentityHashAndVersion() {
 
 }
 
+func (suite *IntegrationTestSuite) TestFindAll() {
+	suite.Adapter.CreateTable(DummyTable{})
+	suite.Adapter.Create(&DummyTable{ID: 1, Value: "One"})
+	suite.Adapter.Create(&DummyTable{ID: 2, Value: "Two"})
+
+	var rows []DummyTable
+	result := suite.Adapter.(orm.RowLoader).FindAll(DummyTable{}, &rows)
+
+	assert.Nil(suite.T(), result.Error)
+	assert.Equal(suite.T(), []DummyTable{{ID: 1, Value: "One"}, {ID: 2, Value: "Two"}}, rows)
+}
+
+func (suite *IntegrationTestSuite) TestFindAllFromCustomTable() {
+	suite.Adapter.CreateTable(CustomSchemaMaster{})
+	suite.Adapter.Create(&CustomSchemaMaster{room.GoRoomSchemaMaster{IdentityHash: "custom", Version: 2}})
+
+	var rows []room.GoRoomSchemaMaster
+	result := suite.Adapter.(orm.RowLoader).FindAll(CustomSchemaMaster{}, &rows)
+
+	assert.Nil(suite.T(), result.Error)
+	assert.Equal(suite.T(), []room.GoRoomSchemaMaster{{IdentityHash: "custom", Version: 2}}, rows)
+}
+
+func (suite *IntegrationTestSuite) TestAutoMigrate() {
+	suite.DB.Exec("CREATE TABLE dummy_tables (id integer primary key)")
+
+	result := suite.Adapter.(orm.AutoMigrator).AutoMigrate(DummyTable{})
+
+	assert.Nil(suite.T(), result.Error)
+	assert.True(suite.T(), suite.DB.Dialect().HasColumn("dummy_tables", "value"), "Missing column should have been added")
+}
+
+func (suite *IntegrationTestSuite) TestRenameTable() {
+	suite.Adapter.CreateTable(DummyTable{})
+
+	result := suite.Adapter.(orm.TableRenamer).RenameTable("dummy_tables", "dummy_tables_archived_v1")
+
+	assert.Nil(suite.T(), result.Error)
+	assert.False(suite.T(), suite.Adapter.HasTable(DummyTable{}))
+	assert.True(suite.T(), suite.Adapter.HasTable("dummy_tables_archived_v1"))
+}
+
+func (suite *IntegrationTestSuite) TestCreateTableWithForeignKeys() {
+	result := suite.Adapter.CreateTable(DummyTable{}, IndexedTable{})
+	assert.Nil(suite.T(), result.Error)
+
+	introspector := suite.Adapter.(orm.SchemaIntrospector)
+	model := suite.Adapter.GetModelDefinition(IndexedTable{})
+	foreignKeys, err := introspector.GetForeignKeys("indexed_tables")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), model.ForeignKeys, foreignKeys, "Foreign keys should be created on SQLite too")
+
+	indexes, err := introspector.GetIndexes("indexed_tables")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), model.Indexes, indexes)
+
+	_, canCreateConstraints := suite.Adapter.(orm.ConstraintCreator)
+	assert.False(suite.T(), canCreateConstraints, "Room should leave indexes and foreign keys to CreateTable")
+}
+
+func (suite *IntegrationTestSuite) TestCreateTableWithReferentialActions() {
+	suite.DB.DB().SetMaxOpenConns(1)
+	suite.DB.Exec("PRAGMA foreign_keys = ON")
+
+	result := suite.Adapter.CreateTable(DummyTable{}, CascadingTable{})
+	assert.Nil(suite.T(), result.Error)
+	assert.Equal(suite.T(), []orm.ForeignKeyDefinition{
+		{Columns: []string{"dummy_id"}, ReferencedTable: "dummy_tables", ReferencedColumns: []string{"id"}, OnDelete: "CASCADE", OnUpdate: "SET NULL"},
+	}, suite.Adapter.GetModelDefinition(CascadingTable{}).ForeignKeys)
+
+	suite.Adapter.Create(&DummyTable{ID: 1, Value: "One"})
+	suite.Adapter.Create(&CascadingTable{ID: 1, DummyID: 1})
+	suite.DB.Exec("DELETE FROM dummy_tables")
+
+	var count int
+	suite.DB.Table("cascading_tables").Count(&count)
+	assert.Equal(suite.T(), 0, count, "Rows should be deleted along with the rows they reference")
+}
+
+func (suite *IntegrationTestSuite) TestCreateTableWithoutConstraintTag() {
+	result := suite.Adapter.CreateTable(DummyTable{}, UnconstrainedTable{})
+	assert.Nil(suite.T(), result.Error)
+
+	foreignKeys, err := suite.Adapter.(orm.SchemaIntrospector).GetForeignKeys("unconstrained_tables")
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), foreignKeys, "Associations should get a foreign key only when tagged with constraint")
+	assert.Empty(suite.T(), suite.Adapter.GetModelDefinition(UnconstrainedTable{}).ForeignKeys)
+}
+
+func (suite *IntegrationTestSuite) TestCreateTableWithInvalidConstraintTag() {
+	result := suite.Adapter.CreateTable(DummyTable{}, MisconstrainedTable{})
+
+	assert.Equal(suite.T(), fmt.Errorf("Constraint of field Dummy of misconstrained_tables is invalid. Referential action DROP is not supported"), result.Error)
+	assert.False(suite.T(), suite.Adapter.HasTable(MisconstrainedTable{}))
+}
+
+func (suite *IntegrationTestSuite) TestGetTables() {
+	suite.Adapter.CreateTable(DummyTable{}, AnotherDummyTable{})
+
+	tables, err := suite.Adapter.(orm.SchemaIntrospector).GetTables()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"another_dummy_tables", "dummy_tables"}, tables)
+}
+
+func (suite *IntegrationTestSuite) TestGetColumns() {
+	suite.DB.Exec("CREATE TABLE settings (id integer primary key, name varchar(64) NOT NULL, value text DEFAULT 'none')")
+	defaultValue := "'none'"
+
+	columns, err := suite.Adapter.(orm.SchemaIntrospector).GetColumns("settings")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []orm.ColumnInfo{
+		{Name: "id", Type: "integer", Nullable: true, PrimaryKey: true},
+		{Name: "name", Type: "varchar(64)"},
+		{Name: "value", Type: "text", Nullable: true, DefaultValue: &defaultValue},
+	}, columns)
+
+	columns, err = suite.Adapter.(orm.SchemaIntrospector).GetColumns("missing_tables")
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), columns)
+}
+
+func (suite *IntegrationTestSuite) TestGetIndexes() {
+	suite.Adapter.CreateTable(IndexedTable{})
+
+	indexes, err := suite.Adapter.(orm.SchemaIntrospector).GetIndexes("indexed_tables")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.Adapter.GetModelDefinition(IndexedTable{}).Indexes, indexes, "Indexes found should match the ones declared")
+}
+
+func (suite *IntegrationTestSuite) TestGetForeignKeys() {
+	suite.Adapter.CreateTable(DummyTable{})
+	suite.DB.Exec("CREATE TABLE regions (code varchar(8), zone varchar(8), PRIMARY KEY (code, zone))")
+	suite.DB.Exec(`CREATE TABLE sites (id integer primary key, dummy_id integer REFERENCES dummy_tables,
+		region varchar(8), zone varchar(8), FOREIGN KEY (region, zone) REFERENCES regions(code, zone))`)
+
+	foreignKeys, err := suite.Adapter.(orm.SchemaIntrospector).GetForeignKeys("sites")
+
+	assert.Nil(suite.T(), err)
+	assert.ElementsMatch(suite.T(), []orm.ForeignKeyDefinition{
+		{Columns: []string{"dummy_id"}, ReferencedTable: "dummy_tables", ReferencedColumns: []string{"id"}},
+		{Columns: []string{"region", "zone"}, ReferencedTable: "regions", ReferencedColumns: []string{"code", "zone"}},
+	}, foreignKeys)
+}
+
+func (suite *IntegrationTestSuite) TestInSchemaOnSQLite() {
+	scoped, err := suite.Adapter.(orm.SchemaScoper).InSchema("tenant_1")
+
+	assert.Nil(suite.T(), scoped)
+	assert.Equal(suite.T(), fmt.Errorf("Schemas are not supported for dialect sqlite3"), err)
+}
+
+func (suite *IntegrationTestSuite) TestQuoteSchema() {
+	assert.Equal(suite.T(), `"tenant_1"`, quoteSchema("tenant_1"))
+	assert.Equal(suite.T(), `"x""; DROP TABLE devices; --"`, quoteSchema(`x"; DROP TABLE devices; --`))
+}
+
+func (suite *IntegrationTestSuite) TestNonTransactionalMigrationInSchema() {
+	suite.DB.CreateTable(DummyTable{})
+	suite.DB.Create(&DummyTable{ID: 1, Value: "old"})
+	//Constructed directly as schemas are not supported on SQLite. Its transactions are not used here
+	scoped := &GORMSchemaAdapter{GORMAdapter: &GORMAdapter{db: suite.DB}, schema: "tenant_1"}
+	migration := &batch.Migration{
+		BaseVersion:   1,
+		TargetVersion: 2,
+		Table:         "dummy_tables",
+		Transform: func(db *gorm.DB, keys []int64) error {
+			return db.Table("dummy_tables").Where("id IN (?)", keys).Update("value", "new").Error
+		},
+		OutsideTransaction: true,
+	}
+
+	err := migration.Apply(scoped.GetUnderlyingORM())
+
+	assert.Equal(suite.T(), errUnconfinedStatement, err)
+	var row DummyTable
+	suite.DB.First(&row, 1)
+	assert.Equal(suite.T(), "old", row.Value)
+	assert.False(suite.T(), suite.DB.HasTable(batch.GoRoomBatchCursor{}))
+}
+
 func (suite *IntegrationTestSuite) TestDoInTransaction() {
 	dummyEntry := room.GoRoomSchemaMaster{
 		IdentityHash: "adaghsghas",
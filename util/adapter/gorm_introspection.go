@@ -0,0 +1,202 @@
+package adapter
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//introspectionQueries Queries describing the schema of the DB in a dialect. All of them except tables take the table name as argument
+type introspectionQueries struct {
+	tables      string //Rows of table name
+	columns     string //Rows of column name, type, nullable, default value and whether it is part of the primary key
+	indexes     string //Rows of index name, uniqueness and column ordered by index and position of the column
+	foreignKeys string //Rows of constraint identifier, column, referenced table and referenced column ordered by constraint and position
+}
+
+var dialectIntrospection = map[string]introspectionQueries{
+	"sqlite3": {
+		tables:      `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+		columns:     `SELECT name, type, "notnull" = 0, dflt_value, pk > 0 FROM pragma_table_info(?) ORDER BY cid`,
+		indexes:     `SELECT il.name, il."unique", ii.name FROM pragma_index_list(?) il JOIN pragma_index_info(il.name) ii WHERE il.origin = 'c' ORDER BY il.name, ii.seqno`,
+		foreignKeys: `SELECT id, "from", "table", "to" FROM pragma_foreign_key_list(?) ORDER BY id, seq`,
+	},
+	"postgres": {
+		tables: `SELECT table_name FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_type = 'BASE TABLE' ORDER BY table_name`,
+		columns: `SELECT c.column_name, c.data_type, c.is_nullable = 'YES', c.column_default,
+			EXISTS (SELECT 1 FROM information_schema.table_constraints tc JOIN information_schema.key_column_usage k
+				ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema AND k.table_name = tc.table_name
+				WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema AND tc.table_name = c.table_name AND k.column_name = c.column_name)
+			FROM information_schema.columns c WHERE c.table_schema = CURRENT_SCHEMA() AND c.table_name = ? ORDER BY c.ordinal_position`,
+		indexes: `SELECT i.relname, ix.indisunique, a.attname FROM pg_class t
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			JOIN pg_index ix ON ix.indrelid = t.oid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, position) ON true
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+			WHERE n.nspname = CURRENT_SCHEMA() AND t.relname = ? AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid)
+			ORDER BY i.relname, k.position`,
+		foreignKeys: `SELECT c.conname, a.attname, rt.relname, ra.attname FROM pg_constraint c
+			JOIN pg_class t ON t.oid = c.conrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			JOIN pg_class rt ON rt.oid = c.confrelid
+			JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, referenced_attnum, position) ON true
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+			JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.referenced_attnum
+			WHERE c.contype = 'f' AND n.nspname = CURRENT_SCHEMA() AND t.relname = ? ORDER BY c.conname, k.position`,
+	},
+	"mysql": {
+		tables: `SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name`,
+		columns: `SELECT column_name, column_type, is_nullable = 'YES', column_default, column_key = 'PRI'
+			FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position`,
+		indexes: `SELECT index_name, non_unique = 0, column_name FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ? AND index_name != 'PRIMARY' ORDER BY index_name, seq_in_index`,
+		foreignKeys: `SELECT constraint_name, column_name, referenced_table_name, referenced_column_name FROM information_schema.key_column_usage
+			WHERE table_schema = DATABASE() AND table_name = ? AND referenced_table_name IS NOT NULL ORDER BY constraint_name, ordinal_position`,
+	},
+}
+
+func (adapter *GORMAdapter) getIntrospectionQueries() (introspectionQueries, error) {
+	dialect := adapter.db.Dialect().GetName()
+	queries, ok := dialectIntrospection[dialect]
+	if !ok {
+		return queries, fmt.Errorf("Schema introspection is not supported for dialect %v", dialect)
+	}
+	return queries, nil
+}
+
+//queryRows Runs a query of the dialect calling scan for each row returned
+func (adapter *GORMAdapter) queryRows(query string, scan func(rows *sql.Rows) error, args ...interface{}) error {
+	rows, err := adapter.db.Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+//GetTables Tables present in the DB sorted by name
+func (adapter *GORMAdapter) GetTables() ([]string, error) {
+	queries, err := adapter.getIntrospectionQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []string
+	err = adapter.queryRows(queries.tables, func(rows *sql.Rows) error {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		tables = append(tables, table)
+		return nil
+	})
+	return tables, err
+}
+
+//GetColumns Columns of a table with their type, nullability and default value
+func (adapter *GORMAdapter) GetColumns(tableName string) ([]orm.ColumnInfo, error) {
+	queries, err := adapter.getIntrospectionQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []orm.ColumnInfo
+	err = adapter.queryRows(queries.columns, func(rows *sql.Rows) error {
+		var column orm.ColumnInfo
+		var defaultValue sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &defaultValue, &column.PrimaryKey); err != nil {
+			return err
+		}
+		if defaultValue.Valid {
+			column.DefaultValue = &defaultValue.String
+		}
+		columns = append(columns, column)
+		return nil
+	}, tableName)
+	return columns, err
+}
+
+//GetIndexes Indexes created on a table other than the ones backing its primary key or unique constraints
+func (adapter *GORMAdapter) GetIndexes(tableName string) ([]orm.IndexDefinition, error) {
+	queries, err := adapter.getIntrospectionQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []orm.IndexDefinition
+	err = adapter.queryRows(queries.indexes, func(rows *sql.Rows) error {
+		var name, column string
+		var unique bool
+		if err := rows.Scan(&name, &unique, &column); err != nil {
+			return err
+		}
+		if len(indexes) < 1 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, orm.IndexDefinition{Name: name, Unique: unique})
+		}
+		index := &indexes[len(indexes)-1]
+		index.Columns = append(index.Columns, column)
+		return nil
+	}, tableName)
+	return indexes, err
+}
+
+//GetForeignKeys Foreign keys present on a table
+func (adapter *GORMAdapter) GetForeignKeys(tableName string) ([]orm.ForeignKeyDefinition, error) {
+	queries, err := adapter.getIntrospectionQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	var foreignKeys []orm.ForeignKeyDefinition
+	var lastConstraint string
+	err = adapter.queryRows(queries.foreignKeys, func(rows *sql.Rows) error {
+		var constraint, column, referencedTable string
+		var referencedColumn sql.NullString
+		if err := rows.Scan(&constraint, &column, &referencedTable, &referencedColumn); err != nil {
+			return err
+		}
+		if len(foreignKeys) < 1 || lastConstraint != constraint {
+			foreignKeys = append(foreignKeys, orm.ForeignKeyDefinition{ReferencedTable: referencedTable})
+			lastConstraint = constraint
+		}
+		foreignKey := &foreignKeys[len(foreignKeys)-1]
+		foreignKey.Columns = append(foreignKey.Columns, column)
+		foreignKey.ReferencedColumns = append(foreignKey.ReferencedColumns, referencedColumn.String)
+		return nil
+	}, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return foreignKeys, adapter.resolveImplicitReferences(foreignKeys)
+}
+
+//resolveImplicitReferences SQLite leaves out referenced columns of foreign keys referencing the primary key. They are filled in here
+func (adapter *GORMAdapter) resolveImplicitReferences(foreignKeys []orm.ForeignKeyDefinition) error {
+	for i, foreignKey := range foreignKeys {
+		if len(foreignKey.ReferencedColumns) < 1 || foreignKey.ReferencedColumns[0] != "" {
+			continue
+		}
+
+		columns, err := adapter.GetColumns(foreignKey.ReferencedTable)
+		if err != nil {
+			return err
+		}
+		var primaryKey []string
+		for _, column := range columns {
+			if column.PrimaryKey {
+				primaryKey = append(primaryKey, column.Name)
+			}
+		}
+		foreignKeys[i].ReferencedColumns = primaryKey
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/jinzhu/gorm"
+)
+
+//GORMSchemaAdapter Adapter for GORM confined to a Postgres schema. Every operation runs in a transaction
+//whose search path is set to the schema, so that tables are created and looked up only in it
+type GORMSchemaAdapter struct {
+	*GORMAdapter
+	schema string
+}
+
+var (
+	refusingDB     *sql.DB
+	refusingDBOnce sync.Once
+)
+
+//errUnconfinedStatement The search path of a schema is set per transaction hence statements run outside one would see other schemas
+var errUnconfinedStatement = fmt.Errorf("Statements outside a transaction can not be confined to a schema. Run them in a transaction of the Room")
+
+//refusingConnector Connector of a DB refusing every connection so that no statement run on it reaches any DB
+type refusingConnector struct{}
+
+func (connector refusingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errUnconfinedStatement
+}
+
+func (connector refusingConnector) Driver() driver.Driver {
+	return connector
+}
+
+func (connector refusingConnector) Open(string) (driver.Conn, error) {
+	return nil, errUnconfinedStatement
+}
+
+//quoteSchema Quotes a schema name for Postgres escaping quotes within it. GORM quotes names without escaping them
+func quoteSchema(schema string) string {
+	return `"` + strings.Replace(schema, `"`, `""`, -1) + `"`
+}
+
+//InSchema Returns an adapter confined to the given schema. Only Postgres is supported
+func (adapter *GORMAdapter) InSchema(schema string) (orm.ORM, error) {
+	if dialect := adapter.db.Dialect().GetName(); dialect != "postgres" {
+		return nil, fmt.Errorf("Schemas are not supported for dialect %v", dialect)
+	}
+
+	return &GORMSchemaAdapter{
+		GORMAdapter: adapter,
+		schema:      schema,
+	}, nil
+}
+
+//CreateSchema Create a schema if it does not exist
+func (adapter *GORMAdapter) CreateSchema(schema string) orm.Result {
+	return orm.Result{
+		Error: adapter.db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %v", quoteSchema(schema))).Error,
+	}
+}
+
+//inSchema Runs fc in a transaction confined to the schema
+func (adapter *GORMSchemaAdapter) inSchema(fc func(tx *GORMAdapter) error) error {
+	return adapter.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL search_path TO %v", quoteSchema(adapter.schema))).Error; err != nil {
+			return err
+		}
+		return fc(&GORMAdapter{db: tx})
+	})
+}
+
+//inSchemaResult Runs an operation returning a result in a transaction confined to the schema
+func (adapter *GORMSchemaAdapter) inSchemaResult(operation func(tx *GORMAdapter) orm.Result) orm.Result {
+	return orm.Result{
+		Error: adapter.inSchema(func(tx *GORMAdapter) error {
+			return operation(tx).Error
+		}),
+	}
+}
+
+//HasTable Check Table exists in the schema
+func (adapter *GORMSchemaAdapter) HasTable(entity interface{}) (hasTable bool) {
+	adapter.inSchema(func(tx *GORMAdapter) error {
+		hasTable = tx.HasTable(entity)
+		return nil
+	})
+	return
+}
+
+//CreateTable Create a Table in the schema
+func (adapter *GORMSchemaAdapter) CreateTable(entities ...interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.CreateTable(entities...)
+	})
+}
+
+//TruncateTable Delete All Values from table
+func (adapter *GORMSchemaAdapter) TruncateTable(entity interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.TruncateTable(entity)
+	})
+}
+
+//Create Create a row
+func (adapter *GORMSchemaAdapter) Create(entity interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.Create(entity)
+	})
+}
+
+//DropTable Drop a table
+func (adapter *GORMSchemaAdapter) DropTable(entities ...interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.DropTable(entities...)
+	})
+}
+
+//RenameTable Rename a table
+func (adapter *GORMSchemaAdapter) RenameTable(from string, to string) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.RenameTable(from, to)
+	})
+}
+
+//GetTables Tables present in the schema sorted by name
+func (adapter *GORMSchemaAdapter) GetTables() (tables []string, err error) {
+	err = adapter.inSchema(func(tx *GORMAdapter) (err error) {
+		tables, err = tx.GetTables()
+		return
+	})
+	return
+}
+
+//GetColumns Columns of a table with their type, nullability and default value
+func (adapter *GORMSchemaAdapter) GetColumns(tableName string) (columns []orm.ColumnInfo, err error) {
+	err = adapter.inSchema(func(tx *GORMAdapter) (err error) {
+		columns, err = tx.GetColumns(tableName)
+		return
+	})
+	return
+}
+
+//GetIndexes Indexes created on a table other than the ones backing its primary key or unique constraints
+func (adapter *GORMSchemaAdapter) GetIndexes(tableName string) (indexes []orm.IndexDefinition, err error) {
+	err = adapter.inSchema(func(tx *GORMAdapter) (err error) {
+		indexes, err = tx.GetIndexes(tableName)
+		return
+	})
+	return
+}
+
+//GetForeignKeys Foreign keys present on a table
+func (adapter *GORMSchemaAdapter) GetForeignKeys(tableName string) (foreignKeys []orm.ForeignKeyDefinition, err error) {
+	err = adapter.inSchema(func(tx *GORMAdapter) (err error) {
+		foreignKeys, err = tx.GetForeignKeys(tableName)
+		return
+	})
+	return
+}
+
+//AutoMigrate Add missing columns to tables of given entities
+func (adapter *GORMSchemaAdapter) AutoMigrate(entities ...interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.AutoMigrate(entities...)
+	})
+}
+
+//FindAll Load all rows of the table backing an entity
+func (adapter *GORMSchemaAdapter) FindAll(entity interface{}, out interface{}) orm.Result {
+	return adapter.inSchemaResult(func(tx *GORMAdapter) orm.Result {
+		return tx.FindAll(entity, out)
+	})
+}
+
+//GetLatestSchemaIdentityHashAndVersion Query the latest schema master entry
+func (adapter *GORMSchemaAdapter) GetLatestSchemaIdentityHashAndVersion() (identityHash string, version int, err error) {
+	err = adapter.inSchema(func(tx *GORMAdapter) (err error) {
+		identityHash, version, err = tx.GetLatestSchemaIdentityHashAndVersion()
+		return
+	})
+	return
+}
+
+//GetUnderlyingORM GORM refusing every statement. The raw DB would run statements outside the schema e.g. of migrations applied
+//outside a transaction, so they fail instead. Transactions of the adapter hand out GORM confined to the schema
+func (adapter *GORMSchemaAdapter) GetUnderlyingORM() interface{} {
+	refusingDBOnce.Do(func() {
+		refusingDB = sql.OpenDB(refusingConnector{})
+	})
+
+	//Error of the ping done by Open is the refusal itself
+	db, _ := gorm.Open(adapter.db.Dialect().GetName(), refusingDB)
+	return db
+}
+
+//DoInTransaction Perform operations specified in the input function in a transaction confined to the schema
+func (adapter *GORMSchemaAdapter) DoInTransaction(fc func(tx orm.ORM) error) error {
+	return adapter.inSchema(func(tx *GORMAdapter) error {
+		return fc(tx)
+	})
+}
@@ -0,0 +1,170 @@
+//go:build postgres
+// +build postgres
+
+package adapter
+
+//Runs against a real Postgres as schemas are not supported on SQLite e.g.
+//	docker run -d -p 5432:5432 -e POSTGRES_PASSWORD=postgres postgres:12
+//	GOROOM_POSTGRES_DSN="host=localhost user=postgres password=postgres sslmode=disable" go test -tags postgres ./util/adapter/
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const postgresTestSchema = "goroom_test_tenant"
+
+type Invoice struct {
+	ID     int `gorm:"primary_key"`
+	Amount int
+}
+
+type valueMigration struct{}
+
+func (m *valueMigration) GetBaseVersion() orm.VersionNumber {
+	return 1
+}
+
+func (m *valueMigration) GetTargetVersion() orm.VersionNumber {
+	return 2
+}
+
+func (m *valueMigration) Apply(db interface{}) error {
+	return db.(*gorm.DB).Table("dummy_tables").Where("id = ?", 1).Update("value", "migrated").Error
+}
+
+type PostgresSchemaTestSuite struct {
+	suite.Suite
+	DB *gorm.DB
+}
+
+func (suite *PostgresSchemaTestSuite) SetupTest() {
+	db, err := gorm.Open("postgres", os.Getenv("GOROOM_POSTGRES_DSN"))
+	if err != nil {
+		panic(err)
+	}
+	suite.DB = db
+	suite.dropSchema(postgresTestSchema)
+}
+
+func (suite *PostgresSchemaTestSuite) TearDownTest() {
+	suite.dropSchema(postgresTestSchema)
+	suite.DB.Close()
+}
+
+func (suite *PostgresSchemaTestSuite) dropSchema(schema string) {
+	if err := suite.DB.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %v CASCADE", quoteSchema(schema))).Error; err != nil {
+		panic(err)
+	}
+}
+
+func (suite *PostgresSchemaTestSuite) getTablesInSchema(schema string) []string {
+	var tables []string
+	err := suite.DB.Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = ? ORDER BY table_name", schema).
+		Pluck("table_name", &tables).Error
+	if err != nil {
+		panic(err)
+	}
+	return tables
+}
+
+func (suite *PostgresSchemaTestSuite) initialize(config room.Config) *room.InitResult {
+	config.DBA = NewGORM(suite.DB)
+	config.IdentityCalculator = new(EntityHashConstructor)
+	config.Schema = postgresTestSchema
+	appDB, errList := room.NewFromConfig(config)
+	if len(errList) > 0 {
+		panic(errList)
+	}
+
+	result, err := goroom.Initialize(appDB)
+	assert.Nil(suite.T(), err)
+	return result
+}
+
+func (suite *PostgresSchemaTestSuite) TestCreate() {
+	result := suite.initialize(room.Config{
+		Entities: []interface{}{DummyTable{}},
+		Version:  1,
+	})
+
+	assert.Equal(suite.T(), room.ScenarioCreate, result.Scenario)
+	assert.Equal(suite.T(), []string{"dummy_tables", "go_room_namespace_tables", "go_room_schema_masters"}, suite.getTablesInSchema(postgresTestSchema))
+	assert.False(suite.T(), suite.DB.HasTable(DummyTable{}), "Entity tables must not be created outside the schema")
+}
+
+func (suite *PostgresSchemaTestSuite) TestMigrate() {
+	suite.initialize(room.Config{
+		Entities: []interface{}{DummyTable{}},
+		Version:  1,
+	})
+	suite.DB.Exec(fmt.Sprintf("INSERT INTO %v.dummy_tables (id, value) VALUES (1, 'created')", postgresTestSchema))
+
+	result := suite.initialize(room.Config{
+		Entities:   []interface{}{DummyTable{}},
+		Version:    2,
+		Migrations: []orm.Migration{&valueMigration{}},
+	})
+
+	assert.Equal(suite.T(), room.ScenarioMigration, result.Scenario)
+	assert.Equal(suite.T(), orm.VersionNumber(2), result.Version)
+	var values []string
+	suite.DB.Table(postgresTestSchema+".dummy_tables").Pluck("value", &values)
+	assert.Equal(suite.T(), []string{"migrated"}, values)
+}
+
+func (suite *PostgresSchemaTestSuite) TestTwoRoomsInSchema() {
+	suite.initialize(room.Config{
+		Entities:  []interface{}{DummyTable{}},
+		Version:   1,
+		Namespace: "inventory",
+	})
+	result := suite.initialize(room.Config{
+		Entities:  []interface{}{Invoice{}},
+		Version:   3,
+		Namespace: "billing",
+	})
+
+	assert.Equal(suite.T(), room.ScenarioCreate, result.Scenario, "Second Room should not see the schema master of the first one")
+	assert.Equal(suite.T(), []string{
+		"billing_go_room_schema_masters", "dummy_tables", "go_room_namespace_tables", "inventory_go_room_schema_masters", "invoices",
+	}, suite.getTablesInSchema(postgresTestSchema))
+
+	result = suite.initialize(room.Config{
+		Entities:  []interface{}{DummyTable{}},
+		Version:   1,
+		Namespace: "inventory",
+	})
+	assert.Equal(suite.T(), room.ScenarioSanityCheck, result.Scenario)
+}
+
+func (suite *PostgresSchemaTestSuite) TestCreateSchemaWithQuoteInName() {
+	schema := `x"; DROP TABLE dummy_tables; --`
+	defer suite.dropSchema(schema)
+	suite.DB.CreateTable(DummyTable{})
+	defer suite.DB.DropTable(DummyTable{})
+
+	result := NewGORM(suite.DB).(orm.SchemaScoper).CreateSchema(schema)
+
+	assert.Nil(suite.T(), result.Error)
+	assert.True(suite.T(), suite.DB.HasTable(DummyTable{}), "Name of the schema must not be run as a statement")
+	var count int
+	suite.DB.Raw("SELECT count(*) FROM information_schema.schemata WHERE schema_name = ?", schema).Row().Scan(&count)
+	assert.Equal(suite.T(), 1, count)
+}
+
+func TestPostgresSchema(t *testing.T) {
+	if os.Getenv("GOROOM_POSTGRES_DSN") == "" {
+		t.Skip("GOROOM_POSTGRES_DSN is not set")
+	}
+	suite.Run(t, new(PostgresSchemaTestSuite))
+}
@@ -0,0 +1,181 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/jinzhu/gorm"
+)
+
+//DefaultBatchSize Number of rows transformed together when BatchSize is not set
+const DefaultBatchSize = 1000
+
+//DefaultCursorTable Table of GoRoomBatchCursor when CursorTable is not set
+const DefaultCursorTable = "go_room_batch_cursors"
+
+//GoRoomBatchCursor Progress of a batched migration so that an interrupted run resumes after the last processed key.
+//Its table is dropped once no batched migration is in progress
+type GoRoomBatchCursor struct {
+	Name          string `gorm:"primary_key"`
+	LastKey       int64
+	RowsProcessed int64
+}
+
+//Migration Data migration iterating a table in primary key ordered batches. Usable as is or from Apply of another migration
+type Migration struct {
+	BaseVersion   orm.VersionNumber
+	TargetVersion orm.VersionNumber
+	Name          string //Identifies the cursor of this migration. Defaults to <table>_<base version>_<target version>
+	Table         string
+	PrimaryKey    string //Integer primary key column of the table. Defaults to id
+	BatchSize     int
+	Transform     func(db *gorm.DB, keys []int64) error //Transforms the rows with the given primary keys
+	CursorTable   string                                //Defaults to DefaultCursorTable. Rooms of different namespaces sharing a DB need one each
+
+	//OutsideTransaction Room applies the migration after committing preceding migrations and every batch is committed on its own.
+	//Transform should then be safe to run again on the batch that was being processed when interrupted
+	OutsideTransaction bool
+}
+
+//GetBaseVersion ...
+func (m *Migration) GetBaseVersion() orm.VersionNumber {
+	return m.BaseVersion
+}
+
+//GetTargetVersion ...
+func (m *Migration) GetTargetVersion() orm.VersionNumber {
+	return m.TargetVersion
+}
+
+//RunsOutsideTransaction ...
+func (m *Migration) RunsOutsideTransaction() bool {
+	return m.OutsideTransaction
+}
+
+//GetAffectedTables Table transformed by the migration. Selective destructive fallback resets it if the migration fails
+func (m *Migration) GetAffectedTables() []string {
+	return []string{m.Table}
+}
+
+//GetAuxiliaryTables Table of the cursor. Room registers it with its namespace while the migration is in progress
+func (m *Migration) GetAuxiliaryTables() []string {
+	return []string{m.getCursorTable()}
+}
+
+//Apply Transforms all remaining rows of the table
+func (m *Migration) Apply(db interface{}) error {
+	return m.ApplyWithProgress(db, nil)
+}
+
+//ApplyWithProgress Transforms all remaining rows of the table reporting the rows processed after every batch
+func (m *Migration) ApplyWithProgress(db interface{}, reportRowsProcessed func(rowsProcessed int64)) error {
+	gormDB, ok := db.(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("Batched migrations work only with GORM. Got %T", db)
+	}
+	if m.Table == "" || m.Transform == nil {
+		return fmt.Errorf("Batched migration from %v to %v needs a table and a transform", m.BaseVersion, m.TargetVersion)
+	}
+
+	cursorTable := m.getCursorTable()
+	if !gormDB.HasTable(cursorTable) {
+		if err := gormDB.Table(cursorTable).CreateTable(GoRoomBatchCursor{}).Error; err != nil {
+			return err
+		}
+	}
+
+	cursor := GoRoomBatchCursor{
+		Name:    m.getName(),
+		LastKey: math.MinInt64,
+	}
+	err := gormDB.Table(cursorTable).Where("name = ?", cursor.Name).First(&cursor).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	for {
+		var keys []int64
+		primaryKey := gormDB.Dialect().Quote(m.getPrimaryKey())
+		err = gormDB.Table(m.Table).Where(fmt.Sprintf("%v > ?", primaryKey), cursor.LastKey).
+			Order(primaryKey).Limit(m.getBatchSize()).Pluck(m.getPrimaryKey(), &keys).Error
+		if err != nil {
+			return err
+		}
+		if len(keys) < 1 {
+			break
+		}
+
+		//Cursor advances only once the batch is committed along with it
+		next := cursor
+		next.LastKey = keys[len(keys)-1]
+		next.RowsProcessed += int64(len(keys))
+		processBatch := func(tx *gorm.DB) error {
+			if err := m.Transform(tx, keys); err != nil {
+				return err
+			}
+			return tx.Table(cursorTable).Save(&next).Error
+		}
+
+		//Transform and cursor are written in one transaction so that an interruption neither skips nor repeats a committed batch
+		if isInTransaction(gormDB) {
+			err = processBatch(gormDB)
+		} else {
+			err = gormDB.Transaction(processBatch)
+		}
+		if err != nil {
+			return fmt.Errorf("Batched migration %v failed after key %v. %v", cursor.Name, cursor.LastKey, err)
+		}
+		cursor = next
+
+		if reportRowsProcessed != nil {
+			reportRowsProcessed(cursor.RowsProcessed)
+		}
+	}
+
+	//A later run of the same migration(e.g. after a destructive clean up) has to start over
+	if err = gormDB.Table(cursorTable).Where("name = ?", cursor.Name).Delete(GoRoomBatchCursor{}).Error; err != nil {
+		return err
+	}
+
+	//Cursors of other batched migrations in progress keep the table
+	var remaining int
+	if err = gormDB.Table(cursorTable).Count(&remaining).Error; err != nil || remaining > 0 {
+		return err
+	}
+	return gormDB.DropTable(cursorTable).Error
+}
+
+func isInTransaction(db *gorm.DB) bool {
+	_, ok := db.CommonDB().(*sql.Tx)
+	return ok
+}
+
+func (m *Migration) getName() string {
+	if m.Name == "" {
+		return fmt.Sprintf("%v_%v_%v", m.Table, m.BaseVersion, m.TargetVersion)
+	}
+	return m.Name
+}
+
+func (m *Migration) getCursorTable() string {
+	if m.CursorTable == "" {
+		return DefaultCursorTable
+	}
+	return m.CursorTable
+}
+
+func (m *Migration) getPrimaryKey() string {
+	if m.PrimaryKey == "" {
+		return "id"
+	}
+	return m.PrimaryKey
+}
+
+func (m *Migration) getBatchSize() int {
+	if m.BatchSize < 1 {
+		return DefaultBatchSize
+	}
+	return m.BatchSize
+}
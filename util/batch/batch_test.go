@@ -0,0 +1,192 @@
+package batch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Reading struct {
+	ID      int64 `gorm:"primary_key"`
+	Celsius int
+	Kelvin  int
+}
+
+type BatchTestSuite struct {
+	suite.Suite
+	DB *gorm.DB
+}
+
+func (s *BatchTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+
+	s.DB.CreateTable(Reading{})
+	for i := 1; i <= 10; i++ {
+		s.DB.Create(&Reading{ID: int64(i * 10), Celsius: i})
+	}
+}
+
+func (s *BatchTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+func (s *BatchTestSuite) getMigration(failAfterKey int64) *Migration {
+	return &Migration{
+		BaseVersion:   1,
+		TargetVersion: 2,
+		Table:         "readings",
+		BatchSize:     3,
+		Transform: func(db *gorm.DB, keys []int64) error {
+			if failAfterKey > 0 && keys[0] > failAfterKey {
+				return fmt.Errorf("Device rebooted")
+			}
+			return db.Table("readings").Where("id IN (?)", keys).UpdateColumn("kelvin", gorm.Expr("celsius + 273")).Error
+		},
+	}
+}
+
+func (s *BatchTestSuite) assertBackfilled(expected int) {
+	var count int
+	s.DB.Table("readings").Where("kelvin = celsius + 273").Count(&count)
+	assert.Equal(s.T(), expected, count)
+}
+
+func (s *BatchTestSuite) TestApplyWithProgress() {
+	var reported []int64
+	err := s.getMigration(0).ApplyWithProgress(s.DB, func(rowsProcessed int64) {
+		reported = append(reported, rowsProcessed)
+	})
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int64{3, 6, 9, 10}, reported)
+	s.assertBackfilled(10)
+	assert.False(s.T(), s.DB.HasTable(DefaultCursorTable), "Cursor table should be dropped once done")
+}
+
+func (s *BatchTestSuite) TestCursorTableKeptForOtherMigrations() {
+	s.DB.Table("backfill_cursors").CreateTable(GoRoomBatchCursor{})
+	s.DB.Table("backfill_cursors").Create(&GoRoomBatchCursor{Name: "other", LastKey: 5, RowsProcessed: 1})
+	migration := s.getMigration(0)
+	migration.CursorTable = "backfill_cursors"
+
+	assert.Nil(s.T(), migration.Apply(s.DB))
+	s.assertBackfilled(10)
+	assert.False(s.T(), s.DB.HasTable(DefaultCursorTable))
+
+	var cursors []GoRoomBatchCursor
+	assert.Nil(s.T(), s.DB.Table("backfill_cursors").Find(&cursors).Error)
+	assert.Equal(s.T(), []GoRoomBatchCursor{{Name: "other", LastKey: 5, RowsProcessed: 1}}, cursors)
+}
+
+func (s *BatchTestSuite) TestResumeAfterInterruption() {
+	migration := s.getMigration(30)
+	migration.OutsideTransaction = true
+
+	assert.EqualError(s.T(), migration.Apply(s.DB), "Batched migration readings_1_2 failed after key 30. Device rebooted")
+	s.assertBackfilled(3)
+
+	var cursor GoRoomBatchCursor
+	assert.Nil(s.T(), s.DB.Table(DefaultCursorTable).Where("name = ?", "readings_1_2").First(&cursor).Error)
+	assert.Equal(s.T(), GoRoomBatchCursor{Name: "readings_1_2", LastKey: 30, RowsProcessed: 3}, cursor)
+
+	var reported []int64
+	err := s.getMigration(0).ApplyWithProgress(s.DB, func(rowsProcessed int64) {
+		reported = append(reported, rowsProcessed)
+	})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int64{6, 9, 10}, reported, "Resumed run should continue after the cursor")
+	s.assertBackfilled(10)
+}
+
+func (s *BatchTestSuite) TestFailedBatchIsRolledBackWithItsCursor() {
+	migration := s.getMigration(0)
+	migration.Transform = func(db *gorm.DB, keys []int64) error {
+		if err := db.Table("readings").Where("id IN (?)", keys).UpdateColumn("kelvin", gorm.Expr("celsius + 273")).Error; err != nil {
+			return err
+		}
+		if keys[0] > 30 {
+			return fmt.Errorf("Device rebooted")
+		}
+		return nil
+	}
+
+	assert.EqualError(s.T(), migration.Apply(s.DB), "Batched migration readings_1_2 failed after key 30. Device rebooted")
+	s.assertBackfilled(3)
+
+	var cursor GoRoomBatchCursor
+	assert.Nil(s.T(), s.DB.Table(DefaultCursorTable).Where("name = ?", "readings_1_2").First(&cursor).Error)
+	assert.Equal(s.T(), GoRoomBatchCursor{Name: "readings_1_2", LastKey: 30, RowsProcessed: 3}, cursor)
+}
+
+func (s *BatchTestSuite) TestOutsideTransactionWithRoom() {
+	gormAdapter := adapter.NewGORM(s.DB)
+	identityCalculator := new(adapter.EntityHashConstructor)
+
+	baseDB, _ := room.New([]interface{}{Reading{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	_, err := goroom.InitializeRoom(baseDB, false)
+	assert.Nil(s.T(), err)
+
+	failingMigration := s.getMigration(30)
+	failingMigration.OutsideTransaction = true
+
+	appDB, _ := room.New([]interface{}{Reading{}}, gormAdapter, 2, []orm.Migration{failingMigration}, identityCalculator)
+	_, err = goroom.InitializeRoom(appDB, false)
+	assert.NotNil(s.T(), err)
+	s.assertBackfilled(3)
+	assert.Equal(s.T(), []string{"go_room_batch_cursors", "readings"}, s.getClaimedTables(), "Cursor table should be claimed while in progress")
+
+	migration := s.getMigration(0)
+	migration.OutsideTransaction = true
+	appDB, _ = room.New([]interface{}{Reading{}}, gormAdapter, 2, []orm.Migration{migration}, identityCalculator)
+	result, err := goroom.InitializeRoom(appDB, false)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []room.AppliedMigration{{From: 1, To: 2}}, result.MigrationsApplied)
+	s.assertBackfilled(10)
+
+	_, version, err := gormAdapter.GetLatestSchemaIdentityHashAndVersion()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, version)
+	assert.False(s.T(), s.DB.HasTable(DefaultCursorTable))
+	assert.Equal(s.T(), []string{"readings"}, s.getClaimedTables())
+}
+
+func (s *BatchTestSuite) TestCleanUpDropsCursorOfInterruptedMigration() {
+	gormAdapter := adapter.NewGORM(s.DB)
+	identityCalculator := new(adapter.EntityHashConstructor)
+
+	baseDB, _ := room.New([]interface{}{Reading{}}, gormAdapter, 1, []orm.Migration{}, identityCalculator)
+	_, err := goroom.InitializeRoom(baseDB, false)
+	assert.Nil(s.T(), err)
+
+	migration := s.getMigration(30)
+	migration.OutsideTransaction = true
+	appDB, _ := room.New([]interface{}{Reading{}}, gormAdapter, 2, []orm.Migration{migration}, identityCalculator)
+	_, err = goroom.InitializeRoom(appDB, true)
+	assert.Nil(s.T(), err, "Destructive fallback should recover")
+
+	assert.False(s.T(), s.DB.HasTable(DefaultCursorTable))
+	assert.Equal(s.T(), []string{"readings"}, s.getClaimedTables())
+}
+
+func (s *BatchTestSuite) getClaimedTables() []string {
+	var tableNames []string
+	s.DB.Model(room.GoRoomNamespaceTable{}).Order("entity_table").Pluck("entity_table", &tableNames)
+	return tableNames
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
@@ -0,0 +1,166 @@
+package bulk
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	goroom "github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" //SQLite driver used by NewGORMSQLiteOpener
+)
+
+//DefaultConcurrency Number of databases initialized at once when Concurrency is not set
+const DefaultConcurrency = 4
+
+//sidecarSuffixes Files SQLite keeps next to a database which are quarantined along with it
+var sidecarSuffixes = []string{"-wal", "-shm", "-journal"}
+
+//Opener Opens the database at a path returning the Room managing it and a function closing the database
+type Opener func(path string) (initializer room.ConfiguredInitializer, close func() error, err error)
+
+//Options Configures how databases are initialized by a Manager
+type Options struct {
+	Concurrency int //Maximum number of databases initialized at once
+
+	//QuarantineDir Databases found unusable by Init, e.g. corrupt ones, are moved here instead of being destroyed by destructive fallback.
+	//Databases failing to open or failing for other reasons e.g. a preflight check are left in place. Destructive fallback as configured
+	//for each Room applies if empty
+	QuarantineDir string
+}
+
+func (options Options) withDefaults() Options {
+	if options.Concurrency < 1 {
+		options.Concurrency = DefaultConcurrency
+	}
+	return options
+}
+
+//Result Outcome of initializing a database
+type Result struct {
+	Path        string
+	Result      *room.InitResult //Nil if the database could not be opened
+	Error       error
+	Quarantined string //Path the database was moved to. Empty if it was not quarantined
+}
+
+//Manager Initializes Room managed databases spread across many files, e.g. one SQLite file per device
+type Manager struct {
+	open    Opener
+	options Options
+}
+
+//NewManager Returns a manager opening each database with the given opener
+func NewManager(open Opener, options Options) *Manager {
+	return &Manager{
+		open:    open,
+		options: options.withDefaults(),
+	}
+}
+
+//NewGORMSQLiteOpener Opener of SQLite files through GORM. The config is used as is for every database except for its DBA.
+//A file which is not a readable database is opened nevertheless, so that Init reports it as unusable
+func NewGORMSQLiteOpener(config room.Config) Opener {
+	return func(path string) (room.ConfiguredInitializer, func() error, error) {
+		sqlDB, err := sql.Open("sqlite3", path)
+		if err != nil {
+			return nil, nil, err
+		}
+		//Error of the ping done by Open is left to Init
+		db, _ := gorm.Open("sqlite3", sqlDB)
+
+		databaseConfig := config
+		databaseConfig.DBA = adapter.NewGORM(db)
+		appDB, errList := room.NewFromConfig(databaseConfig)
+		if len(errList) > 0 {
+			db.Close()
+			return nil, nil, fmt.Errorf("Unable to create Room. %v", errList)
+		}
+		return appDB, db.Close, nil
+	}
+}
+
+//InitializeAll Initializes the databases at the given paths with bounded concurrency. Results are in the order of the paths
+func (m *Manager) InitializeAll(paths []string) ([]Result, error) {
+	results := make([]Result, len(paths))
+	semaphore := make(chan struct{}, m.options.Concurrency)
+	var wait sync.WaitGroup
+
+	for i, path := range paths {
+		wait.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, path string) {
+			defer func() {
+				<-semaphore
+				wait.Done()
+			}()
+			results[i] = m.initialize(path)
+		}(i, path)
+	}
+	wait.Wait()
+
+	var failed []string
+	for _, result := range results {
+		if result.Error != nil {
+			failed = append(failed, result.Path)
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("Initialization of databases %v failed", failed)
+	}
+	return results, nil
+}
+
+func (m *Manager) initialize(path string) (result Result) {
+	result.Path = path
+	initializer, close, err := m.open(path)
+	if err == nil {
+		if m.options.QuarantineDir != "" {
+			result.Result, err = goroom.InitializeRoom(initializer, false)
+		} else {
+			result.Result, err = goroom.Initialize(initializer)
+		}
+
+		if closeErr := close(); err == nil {
+			err = closeErr
+		}
+	}
+	result.Error = err
+
+	//Errors of the opener, the lock or preflight checks say nothing about the database itself
+	if err != nil && m.options.QuarantineDir != "" && result.Result != nil && result.Result.Unusable {
+		quarantined, quarantineErr := m.quarantine(path)
+		if quarantineErr != nil {
+			result.Error = fmt.Errorf("%v. Unable to quarantine the database. %v", err, quarantineErr)
+		}
+		result.Quarantined = quarantined
+	}
+	return
+}
+
+//quarantine Moves a database along with its sidecar files to the quarantine directory under a name that does not collide
+func (m *Manager) quarantine(path string) (string, error) {
+	if err := os.MkdirAll(m.options.QuarantineDir, 0755); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(m.options.QuarantineDir, fmt.Sprintf("%v.%v", filepath.Base(path), time.Now().UnixNano()))
+	if err := os.Rename(path, target); err != nil {
+		return "", err
+	}
+
+	for _, suffix := range sidecarSuffixes {
+		if _, err := os.Stat(path + suffix); err != nil {
+			continue
+		}
+		if err := os.Rename(path+suffix, target+suffix); err != nil {
+			return target, err
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,191 @@
+package bulk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/room/mocks"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Reading struct {
+	gorm.Model
+	Value float64
+}
+
+type BulkTestSuite struct {
+	suite.Suite
+	Dir    string
+	Opener Opener
+}
+
+func (s *BulkTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "goroom_bulk")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+	s.Opener = NewGORMSQLiteOpener(room.Config{
+		Entities:            []interface{}{Reading{}},
+		Version:             1,
+		IdentityCalculator:  new(adapter.EntityHashConstructor),
+		DestructiveFallback: room.DestructiveFallbackToCleanDB,
+	})
+}
+
+func (s *BulkTestSuite) TearDownTest() {
+	os.RemoveAll(s.Dir)
+}
+
+func (s *BulkTestSuite) getPath(name string) string {
+	return filepath.Join(s.Dir, name)
+}
+
+func (s *BulkTestSuite) TestInitializeAll() {
+	paths := []string{s.getPath("sensor_1.db"), s.getPath("sensor_2.db"), s.getPath("sensor_3.db")}
+
+	results, err := NewManager(s.Opener, Options{Concurrency: 2}).InitializeAll(paths)
+
+	assert.Nil(s.T(), err)
+	for i, result := range results {
+		assert.Equal(s.T(), paths[i], result.Path)
+		assert.Nil(s.T(), result.Error)
+		assert.Equal(s.T(), room.ScenarioCreate, result.Result.Scenario)
+	}
+
+	//Databases already at the current version only go through the sanity check
+	results, err = NewManager(s.Opener, Options{}).InitializeAll(paths)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), room.ScenarioSanityCheck, results[0].Result.Scenario)
+}
+
+func (s *BulkTestSuite) TestQuarantineOfFailingDatabase() {
+	corrupt := s.getPath("sensor_2.db")
+	ioutil.WriteFile(corrupt, []byte("flash corruption"), 0644)
+	quarantineDir := s.getPath("quarantine")
+
+	results, err := NewManager(s.Opener, Options{QuarantineDir: quarantineDir}).InitializeAll([]string{s.getPath("sensor_1.db"), corrupt})
+
+	assert.Equal(s.T(), fmt.Errorf("Initialization of databases [%v] failed", corrupt), err)
+	assert.Nil(s.T(), results[0].Error)
+	assert.Empty(s.T(), results[0].Quarantined)
+	assert.NotNil(s.T(), results[1].Error)
+	assert.Equal(s.T(), quarantineDir, filepath.Dir(results[1].Quarantined))
+
+	content, _ := ioutil.ReadFile(results[1].Quarantined)
+	assert.Equal(s.T(), "flash corruption", string(content), "Database should be moved aside as is")
+	_, err = os.Stat(corrupt)
+	assert.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *BulkTestSuite) TestQuarantineMovesSidecarFiles() {
+	path := s.getPath("sensor_1.db")
+	ioutil.WriteFile(path, []byte("database"), 0644)
+	ioutil.WriteFile(path+"-wal", []byte("wal"), 0644)
+	initError := fmt.Errorf("Unable to read the schema master")
+	opener := s.getMockOpener(true, initError)
+
+	results, _ := NewManager(opener, Options{QuarantineDir: s.getPath("quarantine")}).InitializeAll([]string{path})
+
+	assert.Equal(s.T(), initError, results[0].Error)
+	assert.True(s.T(), results[0].Result.Unusable)
+	content, _ := ioutil.ReadFile(results[0].Quarantined + "-wal")
+	assert.Equal(s.T(), "wal", string(content))
+	_, err := os.Stat(path + "-wal")
+	assert.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *BulkTestSuite) TestQuarantineOnlyOfUnusableDatabase() {
+	path := s.getPath("sensor_1.db")
+	ioutil.WriteFile(path, []byte("database"), 0644)
+	failingOpener := func(path string) (room.ConfiguredInitializer, func() error, error) {
+		return nil, nil, fmt.Errorf("Unable to create Room")
+	}
+	preflightError := &room.PreflightError{Check: "disk_space", From: 1, To: 2, Err: fmt.Errorf("Disk full")}
+
+	for _, opener := range []Opener{failingOpener, s.getMockOpener(false, preflightError)} {
+		results, err := NewManager(opener, Options{QuarantineDir: s.getPath("quarantine")}).InitializeAll([]string{path})
+
+		assert.NotNil(s.T(), err)
+		assert.NotNil(s.T(), results[0].Error)
+		assert.Empty(s.T(), results[0].Quarantined)
+		_, err = os.Stat(path)
+		assert.Nil(s.T(), err, "Database should be left in place")
+	}
+}
+
+func (s *BulkTestSuite) TestFailingDatabaseWithoutQuarantine() {
+	corrupt := s.getPath("sensor_1.db")
+	ioutil.WriteFile(corrupt, []byte("flash corruption"), 0644)
+
+	results, err := NewManager(s.Opener, Options{}).InitializeAll([]string{corrupt})
+
+	assert.NotNil(s.T(), err)
+	assert.NotNil(s.T(), results[0].Error)
+	assert.Empty(s.T(), results[0].Quarantined)
+	_, err = os.Stat(corrupt)
+	assert.Nil(s.T(), err, "Database should be left in place")
+}
+
+func (s *BulkTestSuite) TestConcurrencyIsBounded() {
+	mockCtrl := gomock.NewController(s.T())
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	opener := func(path string) (room.ConfiguredInitializer, func() error, error) {
+		initializer := mocks.NewMockConfiguredInitializer(mockCtrl)
+		initializer.EXPECT().GetDestructiveFallbackPolicy().Return(room.NoDestructiveFallback).AnyTimes()
+		initializer.EXPECT().CalculateIdentityHash().DoAndReturn(func() (string, error) {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			return "identity", nil
+		})
+		initializer.EXPECT().Init("identity").DoAndReturn(func(string) (bool, error) {
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+			return false, nil
+		})
+		return initializer, func() error { return nil }, nil
+	}
+
+	var paths []string
+	for i := 0; i < 9; i++ {
+		paths = append(paths, s.getPath(fmt.Sprintf("sensor_%v.db", i)))
+	}
+	_, err := NewManager(opener, Options{Concurrency: 3}).InitializeAll(paths)
+
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), maxRunning <= 3, "At most 3 databases should be initialized at once. Got %v", maxRunning)
+	assert.True(s.T(), maxRunning > 1, "Databases should be initialized concurrently")
+}
+
+//getMockOpener Opener of a Room whose Init fails as given
+func (s *BulkTestSuite) getMockOpener(shouldRetryAfterDestruction bool, initError error) Opener {
+	mockCtrl := gomock.NewController(s.T())
+	return func(path string) (room.ConfiguredInitializer, func() error, error) {
+		initializer := mocks.NewMockConfiguredInitializer(mockCtrl)
+		initializer.EXPECT().CalculateIdentityHash().Return("identity", nil)
+		initializer.EXPECT().Init("identity").Return(shouldRetryAfterDestruction, initError)
+		return initializer, func() error { return nil }, nil
+	}
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(BulkTestSuite))
+}
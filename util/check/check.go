@@ -0,0 +1,155 @@
+package check
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+//Check Reusable data check for migrations. Before is run prior to the migration and what it returns is handed to After
+//once the migration is applied. Checks hold no state of their own so that a migration can run on many DBs at once
+type Check interface {
+	Before(db *gorm.DB) (before interface{}, err error)
+	After(db *gorm.DB, before interface{}) error
+}
+
+//Checks Pre and post conditions of a migration. Embed it in a migration to have Room run the checks inside the migration transaction
+type Checks []Check
+
+//ValidateBefore Runs Before of every check returning what they returned in the same order
+func (checks Checks) ValidateBefore(db interface{}) (interface{}, error) {
+	gormDB, err := getGORMDB(db)
+	if err != nil {
+		return nil, err
+	}
+
+	befores := make([]interface{}, len(checks))
+	for i, check := range checks {
+		if befores[i], err = check.Before(gormDB); err != nil {
+			return nil, err
+		}
+	}
+	return befores, nil
+}
+
+//ValidateAfter Runs After of every check with what its Before returned in this run
+func (checks Checks) ValidateAfter(db interface{}, before interface{}) error {
+	gormDB, err := getGORMDB(db)
+	if err != nil {
+		return err
+	}
+
+	befores, ok := before.([]interface{})
+	if !ok || len(befores) != len(checks) {
+		return fmt.Errorf("Data checks were not run before the migration")
+	}
+
+	for i, check := range checks {
+		if err = check.After(gormDB, befores[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getGORMDB(db interface{}) (*gorm.DB, error) {
+	gormDB, ok := db.(*gorm.DB)
+	if !ok {
+		return nil, fmt.Errorf("Data checks work only with GORM. Got %T", db)
+	}
+	return gormDB, nil
+}
+
+type rowCountPreserved struct {
+	table string
+}
+
+//RowCountPreserved Fails if the number of rows in table changes during the migration
+func RowCountPreserved(table string) Check {
+	return &rowCountPreserved{
+		table: table,
+	}
+}
+
+func (c *rowCountPreserved) Before(db *gorm.DB) (interface{}, error) {
+	var count int
+	err := db.Table(c.table).Count(&count).Error
+	return count, err
+}
+
+func (c *rowCountPreserved) After(db *gorm.DB, before interface{}) error {
+	var count int
+	if err := db.Table(c.table).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != before.(int) {
+		return fmt.Errorf("Table %v had %v rows before migration and has %v after", c.table, before, count)
+	}
+	return nil
+}
+
+type noNulls struct {
+	table  string
+	column string
+}
+
+//NoNulls Fails if column of table has NULL values after the migration
+func NoNulls(table string, column string) Check {
+	return &noNulls{
+		table:  table,
+		column: column,
+	}
+}
+
+func (c *noNulls) Before(db *gorm.DB) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *noNulls) After(db *gorm.DB, before interface{}) error {
+	var count int
+	err := db.Table(c.table).Where(fmt.Sprintf("%v IS NULL", db.Dialect().Quote(c.column))).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("Column %v of %v has %v NULL values", c.column, c.table, count)
+	}
+	return nil
+}
+
+type referentialIntegrity struct {
+	table           string
+	column          string
+	referenceTable  string
+	referenceColumn string
+}
+
+//ReferentialIntegrity Fails if non NULL values of column in table are missing from referenceColumn of referenceTable after the migration
+func ReferentialIntegrity(table string, column string, referenceTable string, referenceColumn string) Check {
+	return &referentialIntegrity{
+		table:           table,
+		column:          column,
+		referenceTable:  referenceTable,
+		referenceColumn: referenceColumn,
+	}
+}
+
+func (c *referentialIntegrity) Before(db *gorm.DB) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *referentialIntegrity) After(db *gorm.DB, before interface{}) error {
+	dialect := db.Dialect()
+	column := dialect.Quote(c.column)
+	condition := fmt.Sprintf("%v IS NOT NULL AND %v NOT IN (SELECT %v FROM %v)",
+		column, column, dialect.Quote(c.referenceColumn), dialect.Quote(c.referenceTable))
+
+	var count int
+	if err := db.Table(c.table).Where(condition).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%v rows of %v reference missing %v.%v", count, c.table, c.referenceTable, c.referenceColumn)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckTestSuite struct {
+	suite.Suite
+	DB *gorm.DB
+}
+
+func (s *CheckTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+
+	s.DB.Exec("CREATE TABLE users (id integer primary key, name varchar(255))")
+	s.DB.Exec("CREATE TABLE profiles (id integer primary key, user_id integer)")
+	s.DB.Exec("INSERT INTO users (id, name) VALUES (1, 'Alice'), (2, 'Bob')")
+	s.DB.Exec("INSERT INTO profiles (id, user_id) VALUES (1, 1), (2, 2)")
+}
+
+func (s *CheckTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+func (s *CheckTestSuite) validate(checks Checks, migrate func()) error {
+	before, err := checks.ValidateBefore(s.DB)
+	if err != nil {
+		return err
+	}
+	migrate()
+	return checks.ValidateAfter(s.DB, before)
+}
+
+func (s *CheckTestSuite) TestRowCountPreserved() {
+	checks := Checks{RowCountPreserved("users")}
+
+	assert.Nil(s.T(), s.validate(checks, func() {
+		s.DB.Exec("UPDATE users SET name = 'Carol' WHERE id = 2")
+	}), "Updates should not affect row count")
+
+	assert.EqualError(s.T(), s.validate(checks, func() {
+		s.DB.Exec("DELETE FROM users WHERE id = 2")
+	}), "Table users had 2 rows before migration and has 1 after")
+}
+
+func (s *CheckTestSuite) TestRowCountPreservedOnManyDBs() {
+	checks := Checks{RowCountPreserved("users")}
+	otherDB, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	defer otherDB.Close()
+	otherDB.DB().SetMaxOpenConns(1)
+	otherDB.Exec("CREATE TABLE users (id integer primary key, name varchar(255))")
+
+	//Runs of the same migration on two DBs interleave as with bulk initialization
+	before, err := checks.ValidateBefore(s.DB)
+	assert.Nil(s.T(), err)
+	otherBefore, err := checks.ValidateBefore(otherDB)
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), checks.ValidateAfter(s.DB, before))
+	assert.Nil(s.T(), checks.ValidateAfter(otherDB, otherBefore))
+}
+
+func (s *CheckTestSuite) TestNoNulls() {
+	checks := Checks{NoNulls("profiles", "user_id")}
+
+	assert.Nil(s.T(), s.validate(checks, func() {}))
+	assert.EqualError(s.T(), s.validate(checks, func() {
+		s.DB.Exec("UPDATE profiles SET user_id = NULL")
+	}), "Column user_id of profiles has 2 NULL values")
+}
+
+func (s *CheckTestSuite) TestReferentialIntegrity() {
+	checks := Checks{ReferentialIntegrity("profiles", "user_id", "users", "id")}
+
+	assert.Nil(s.T(), s.validate(checks, func() {
+		s.DB.Exec("INSERT INTO profiles (id, user_id) VALUES (3, NULL)")
+	}), "NULL references should be allowed")
+	assert.EqualError(s.T(), s.validate(checks, func() {
+		s.DB.Exec("UPDATE profiles SET user_id = 7 WHERE id = 2")
+	}), "1 rows of profiles reference missing users.id")
+}
+
+func (s *CheckTestSuite) TestChecksWithoutGORM() {
+	_, err := Checks{}.ValidateBefore("not a db")
+	assert.EqualError(s.T(), err, "Data checks work only with GORM. Got string")
+	assert.EqualError(s.T(), Checks{RowCountPreserved("users")}.ValidateAfter(s.DB, nil), "Data checks were not run before the migration")
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(CheckTestSuite))
+}
@@ -0,0 +1,55 @@
+package instrument
+
+import (
+	"github.com/adonmo/goroom/orm"
+)
+
+//Outcomes recorded for operations that ended
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+func getOutcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+type combined []orm.Instrumentation
+
+//Combine Returns an instrumentation reporting every operation to all the given ones. E.g. a tracer and metrics
+func Combine(instrumentations ...orm.Instrumentation) orm.Instrumentation {
+	return combined(instrumentations)
+}
+
+func (c combined) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	recorders := make(combinedRecorder, 0, len(c))
+	for _, instrumentation := range c {
+		recorders = append(recorders, instrumentation.StartOperation(operation, labels))
+	}
+	return recorders
+}
+
+type combinedRecorder []orm.OperationRecorder
+
+func (c combinedRecorder) SetLabel(key string, value string) {
+	for _, recorder := range c {
+		recorder.SetLabel(key, value)
+	}
+}
+
+func (c combinedRecorder) End(err error) {
+	for _, recorder := range c {
+		recorder.End(err)
+	}
+}
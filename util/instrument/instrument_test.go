@@ -0,0 +1,142 @@
+package instrument
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Device struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type failingMigration struct{}
+
+func (m *failingMigration) GetBaseVersion() orm.VersionNumber {
+	return 1
+}
+
+func (m *failingMigration) GetTargetVersion() orm.VersionNumber {
+	return 2
+}
+
+func (m *failingMigration) Apply(db interface{}) error {
+	return fmt.Errorf("Disk full")
+}
+
+type InstrumentTestSuite struct {
+	suite.Suite
+	DB       *gorm.DB
+	Exporter *InMemoryExporter
+	Tracer   *Tracer
+	Metrics  *Metrics
+}
+
+func (s *InstrumentTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+
+	s.Exporter = &InMemoryExporter{}
+	s.Tracer = NewTracer(s.Exporter)
+	s.Tracer.now = newClock()
+	s.Metrics = NewMetrics(1, 5)
+	s.Metrics.now = newClock()
+}
+
+//newClock Returns a clock which advances by 2 seconds on every call
+func newClock() func() time.Time {
+	clock := time.Unix(0, 0)
+	return func() time.Time {
+		clock = clock.Add(2 * time.Second)
+		return clock
+	}
+}
+
+func (s *InstrumentTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+func (s *InstrumentTestSuite) initialize(version orm.VersionNumber, migrations []orm.Migration) error {
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            []interface{}{Device{}},
+		DBA:                 adapter.NewGORM(s.DB),
+		Version:             version,
+		Migrations:          migrations,
+		IdentityCalculator:  new(adapter.EntityHashConstructor),
+		Instrumentation:     Combine(s.Tracer, s.Metrics),
+		DestructiveFallback: room.DestructiveFallbackToCleanDB,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	_, err := goroom.Initialize(appDB)
+	return err
+}
+
+func (s *InstrumentTestSuite) TestTracer() {
+	assert.Nil(s.T(), s.initialize(1, []orm.Migration{}))
+
+	var names []string
+	for _, span := range s.Exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	assert.Equal(s.T(), []string{"goroom.calculate_identity_hash", "goroom.init"}, names)
+
+	initSpan := s.Exporter.GetSpans()[1]
+	assert.Equal(s.T(), map[string]string{"to_version": "1", "scenario": "create", "outcome": "success"}, initSpan.Attributes)
+	assert.Equal(s.T(), 2*time.Second, initSpan.EndTime.Sub(initSpan.StartTime))
+}
+
+func (s *InstrumentTestSuite) TestMetricsOfFailedMigrationAndCleanUp() {
+	assert.Nil(s.T(), s.initialize(1, []orm.Migration{}))
+	assert.Nil(s.T(), s.initialize(2, []orm.Migration{&failingMigration{}}), "Destructive fallback should recover")
+
+	assert.Equal(s.T(), float64(1), s.Metrics.GetOperationCount(map[string]string{
+		"operation": "migration", "from_version": "1", "to_version": "2", "outcome": "failure",
+	}))
+	assert.Equal(s.T(), float64(1), s.Metrics.GetOperationCount(map[string]string{
+		"operation": "init", "from_version": "1", "to_version": "2", "scenario": "migration", "outcome": "failure",
+	}))
+	assert.Equal(s.T(), float64(1), s.Metrics.GetOperationCount(map[string]string{
+		"operation": "cleanup", "to_version": "2", "mode": "full", "outcome": "success",
+	}))
+	assert.Equal(s.T(), float64(1), s.Metrics.GetOperationCount(map[string]string{
+		"operation": "init", "to_version": "2", "scenario": "create", "outcome": "success",
+	}), "Init retried after clean up should create the DB")
+
+	var text bytes.Buffer
+	assert.Nil(s.T(), s.Metrics.WriteText(&text))
+	series := `{mode="full",operation="cleanup",outcome="success",to_version="2"}`
+	for _, line := range []string{
+		"# TYPE goroom_operations_total counter",
+		"goroom_operations_total" + series + " 1",
+		"# TYPE goroom_operation_duration_seconds histogram",
+		`goroom_operation_duration_seconds_bucket{mode="full",operation="cleanup",outcome="success",to_version="2",le="1"} 0`,
+		`goroom_operation_duration_seconds_bucket{mode="full",operation="cleanup",outcome="success",to_version="2",le="5"} 1`,
+		`goroom_operation_duration_seconds_bucket{mode="full",operation="cleanup",outcome="success",to_version="2",le="+Inf"} 1`,
+		"goroom_operation_duration_seconds_sum" + series + " 2",
+		"goroom_operation_duration_seconds_count" + series + " 1",
+	} {
+		assert.True(s.T(), strings.Contains(text.String(), line+"\n"), "Missing %v in\n%v", line, text.String())
+	}
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(InstrumentTestSuite))
+}
@@ -0,0 +1,154 @@
+package instrument
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//Names of the metrics recorded for Room operations
+const (
+	OperationsTotalMetric   = "goroom_operations_total"
+	OperationDurationMetric = "goroom_operation_duration_seconds"
+)
+
+//DefaultBuckets Upper bounds in seconds of the duration histogram. Migrations on edge devices can run for minutes
+var DefaultBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+type histogram struct {
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+//Metrics Instrumentation keeping counters and duration histograms of Room operations in process. It is not backed by the Prometheus
+//client. Use WriteText to expose them or the Metrics of package util/instrument/prometheus to register them with a Prometheus registry
+type Metrics struct {
+	mutex      sync.Mutex
+	buckets    []float64
+	counters   map[string]float64
+	histograms map[string]*histogram
+	now        func() time.Time
+}
+
+//NewMetrics Returns metrics using the given histogram buckets or DefaultBuckets if none are given
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) < 1 {
+		buckets = DefaultBuckets
+	}
+	sortedBuckets := append([]float64(nil), buckets...)
+	sort.Float64s(sortedBuckets)
+
+	return &Metrics{
+		buckets:    sortedBuckets,
+		counters:   make(map[string]float64),
+		histograms: make(map[string]*histogram),
+		now:        time.Now,
+	}
+}
+
+//StartOperation Starts timing an operation
+func (m *Metrics) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	recorderLabels := copyLabels(labels)
+	recorderLabels["operation"] = operation
+	return &metricsRecorder{
+		metrics:   m,
+		labels:    recorderLabels,
+		startTime: m.now(),
+	}
+}
+
+//GetOperationCount Number of operations that ended with exactly the given labels(including operation and outcome)
+func (m *Metrics) GetOperationCount(labels map[string]string) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counters[formatLabels(labels)]
+}
+
+//WriteText Writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	//Every series has a counter as well as a histogram
+	allSeries := make([]string, 0, len(m.counters))
+	for series := range m.counters {
+		allSeries = append(allSeries, series)
+	}
+	sort.Strings(allSeries)
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("# TYPE %v counter", OperationsTotalMetric))
+	for _, series := range allSeries {
+		lines = append(lines, fmt.Sprintf("%v{%v} %v", OperationsTotalMetric, series, m.counters[series]))
+	}
+
+	lines = append(lines, fmt.Sprintf("# TYPE %v histogram", OperationDurationMetric))
+	for _, series := range allSeries {
+		h := m.histograms[series]
+		for i, bucket := range m.buckets {
+			lines = append(lines, fmt.Sprintf("%v_bucket{%v,le=\"%v\"} %v", OperationDurationMetric, series, bucket, h.bucketCounts[i]))
+		}
+		lines = append(lines, fmt.Sprintf("%v_bucket{%v,le=\"+Inf\"} %v", OperationDurationMetric, series, h.count))
+		lines = append(lines, fmt.Sprintf("%v_sum{%v} %v", OperationDurationMetric, series, h.sum))
+		lines = append(lines, fmt.Sprintf("%v_count{%v} %v", OperationDurationMetric, series, h.count))
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func (m *Metrics) observe(labels map[string]string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	series := formatLabels(labels)
+	m.counters[series]++
+
+	h, ok := m.histograms[series]
+	if !ok {
+		h = &histogram{
+			bucketCounts: make([]uint64, len(m.buckets)),
+		}
+		m.histograms[series] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, bucket := range m.buckets {
+		if seconds <= bucket {
+			h.bucketCounts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+type metricsRecorder struct {
+	metrics   *Metrics
+	labels    map[string]string
+	startTime time.Time
+}
+
+func (r *metricsRecorder) SetLabel(key string, value string) {
+	r.labels[key] = value
+}
+
+func (r *metricsRecorder) End(err error) {
+	r.labels["outcome"] = getOutcome(err)
+	r.metrics.observe(r.labels, r.metrics.now().Sub(r.startTime))
+}
+
+//formatLabels Labels sorted by name in the Prometheus format. Also serves as the key of a series
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%v=%q", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
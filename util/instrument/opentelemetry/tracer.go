@@ -0,0 +1,61 @@
+package opentelemetry
+
+import (
+	"context"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/util/instrument"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//OutcomeAttribute Attribute recording whether the operation succeeded
+const OutcomeAttribute = "outcome"
+
+//Tracer Instrumentation recording an OpenTelemetry span for each Room operation
+type Tracer struct {
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+//NewTracer Returns an instrumentation starting spans of Room operations with the given tracer. E.g. otel.Tracer("goroom").
+//Spans are children of the span in ctx if there is one, e.g. the span of app boot
+func NewTracer(ctx context.Context, tracer trace.Tracer) *Tracer {
+	return &Tracer{
+		ctx:    ctx,
+		tracer: tracer,
+	}
+}
+
+//StartOperation Starts the span of an operation with its labels as attributes
+func (t *Tracer) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	attributes := make([]attribute.KeyValue, 0, len(labels))
+	for key, value := range labels {
+		attributes = append(attributes, attribute.String(key, value))
+	}
+
+	_, span := t.tracer.Start(t.ctx, instrument.SpanNamePrefix+operation, trace.WithAttributes(attributes...))
+	return &spanRecorder{
+		span: span,
+	}
+}
+
+type spanRecorder struct {
+	span trace.Span
+}
+
+func (r *spanRecorder) SetLabel(key string, value string) {
+	r.span.SetAttributes(attribute.String(key, value))
+}
+
+func (r *spanRecorder) End(err error) {
+	if err != nil {
+		r.span.SetAttributes(attribute.String(OutcomeAttribute, instrument.OutcomeFailure))
+		r.span.RecordError(err)
+		r.span.SetStatus(codes.Error, err.Error())
+	} else {
+		r.span.SetAttributes(attribute.String(OutcomeAttribute, instrument.OutcomeSuccess))
+	}
+	r.span.End()
+}
@@ -0,0 +1,141 @@
+package opentelemetry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type Device struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type failingMigration struct{}
+
+func (m *failingMigration) GetBaseVersion() orm.VersionNumber {
+	return 1
+}
+
+func (m *failingMigration) GetTargetVersion() orm.VersionNumber {
+	return 2
+}
+
+func (m *failingMigration) Apply(db interface{}) error {
+	return fmt.Errorf("Disk full")
+}
+
+type TracerTestSuite struct {
+	suite.Suite
+	DB       *gorm.DB
+	Recorder *tracetest.SpanRecorder
+	Provider *sdktrace.TracerProvider
+}
+
+func (s *TracerTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+
+	s.Recorder = tracetest.NewSpanRecorder()
+	s.Provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.Recorder))
+}
+
+func (s *TracerTestSuite) TearDownTest() {
+	s.DB.Close()
+	s.Provider.Shutdown(context.Background())
+}
+
+func (s *TracerTestSuite) initialize(ctx context.Context, version orm.VersionNumber, migrations []orm.Migration) error {
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            []interface{}{Device{}},
+		DBA:                 adapter.NewGORM(s.DB),
+		Version:             version,
+		Migrations:          migrations,
+		IdentityCalculator:  new(adapter.EntityHashConstructor),
+		Instrumentation:     NewTracer(ctx, s.Provider.Tracer("goroom")),
+		DestructiveFallback: room.DestructiveFallbackToCleanDB,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	_, err := goroom.Initialize(appDB)
+	return err
+}
+
+func (s *TracerTestSuite) getAttributes(span sdktrace.ReadOnlySpan) map[string]string {
+	attributes := make(map[string]string)
+	for _, keyValue := range span.Attributes() {
+		attributes[string(keyValue.Key)] = keyValue.Value.AsString()
+	}
+	return attributes
+}
+
+func (s *TracerTestSuite) TestSpansOfCreation() {
+	assert.Nil(s.T(), s.initialize(context.Background(), 1, []orm.Migration{}))
+
+	spans := s.Recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	assert.Equal(s.T(), []string{"goroom.calculate_identity_hash", "goroom.init"}, names)
+	assert.Equal(s.T(), map[string]string{"to_version": "1", "scenario": "create", "outcome": "success"}, s.getAttributes(spans[1]))
+	assert.Equal(s.T(), codes.Unset, spans[1].Status().Code)
+	assert.False(s.T(), spans[1].Parent().IsValid())
+}
+
+func (s *TracerTestSuite) TestSpanOfFailedMigration() {
+	assert.Nil(s.T(), s.initialize(context.Background(), 1, []orm.Migration{}))
+	assert.Nil(s.T(), s.initialize(context.Background(), 2, []orm.Migration{&failingMigration{}}), "Destructive fallback should recover")
+
+	var migrationSpans []sdktrace.ReadOnlySpan
+	for _, span := range s.Recorder.Ended() {
+		if span.Name() == "goroom.migration" {
+			migrationSpans = append(migrationSpans, span)
+		}
+	}
+	if !assert.Len(s.T(), migrationSpans, 1) {
+		return
+	}
+
+	span := migrationSpans[0]
+	assert.Equal(s.T(), map[string]string{"from_version": "1", "to_version": "2", "outcome": "failure"}, s.getAttributes(span))
+	assert.Equal(s.T(), codes.Error, span.Status().Code)
+	assert.Equal(s.T(), "Disk full", span.Status().Description)
+	if assert.Len(s.T(), span.Events(), 1) {
+		assert.Contains(s.T(), span.Events()[0].Attributes, attribute.String("exception.message", "Disk full"))
+	}
+}
+
+func (s *TracerTestSuite) TestSpansAreChildrenOfSpanInContext() {
+	ctx, boot := s.Provider.Tracer("app").Start(context.Background(), "boot")
+	assert.Nil(s.T(), s.initialize(ctx, 1, []orm.Migration{}))
+	boot.End()
+
+	for _, span := range s.Recorder.Ended() {
+		if span.Name() != "boot" {
+			assert.Equal(s.T(), boot.SpanContext().SpanID(), span.Parent().SpanID(), "Parent of %v", span.Name())
+		}
+	}
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(TracerTestSuite))
+}
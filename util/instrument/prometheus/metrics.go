@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/instrument"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+//Labels of the metrics which are not labels of Room operations
+const (
+	OperationLabel = "operation"
+	OutcomeLabel   = "outcome"
+)
+
+//LabelNames Labels of the metrics. Labels an operation does not carry are left empty
+var LabelNames = []string{OperationLabel, room.LabelFromVersion, room.LabelToVersion, room.LabelScenario, room.LabelCleanUpMode, OutcomeLabel}
+
+//Metrics Instrumentation counting Room operations and observing their durations with the Prometheus client.
+//It is a prometheus.Collector to be registered with the registry of the app
+type Metrics struct {
+	operations *prom.CounterVec
+	durations  *prom.HistogramVec
+	now        func() time.Time
+}
+
+//NewMetrics Returns metrics using the given histogram buckets or instrument.DefaultBuckets if none are given
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) < 1 {
+		buckets = instrument.DefaultBuckets
+	}
+
+	return &Metrics{
+		operations: prom.NewCounterVec(prom.CounterOpts{
+			Name: instrument.OperationsTotalMetric,
+			Help: "Number of Room operations that ended by operation, versions and outcome",
+		}, LabelNames),
+		durations: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    instrument.OperationDurationMetric,
+			Help:    "Duration of Room operations in seconds by operation, versions and outcome",
+			Buckets: buckets,
+		}, LabelNames),
+		now: time.Now,
+	}
+}
+
+//Describe Sends the descriptors of the operation counter and duration histogram
+func (m *Metrics) Describe(descriptors chan<- *prom.Desc) {
+	m.operations.Describe(descriptors)
+	m.durations.Describe(descriptors)
+}
+
+//Collect Sends the operation counters and duration histograms
+func (m *Metrics) Collect(metrics chan<- prom.Metric) {
+	m.operations.Collect(metrics)
+	m.durations.Collect(metrics)
+}
+
+//StartOperation Starts timing an operation
+func (m *Metrics) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	recorderLabels := make(prom.Labels, len(LabelNames))
+	for _, name := range LabelNames {
+		recorderLabels[name] = labels[name]
+	}
+	recorderLabels[OperationLabel] = operation
+
+	return &metricsRecorder{
+		metrics:   m,
+		labels:    recorderLabels,
+		startTime: m.now(),
+	}
+}
+
+type metricsRecorder struct {
+	metrics   *Metrics
+	labels    prom.Labels
+	startTime time.Time
+}
+
+//SetLabel Labels other than LabelNames are dropped as the label names of a metric are fixed
+func (r *metricsRecorder) SetLabel(key string, value string) {
+	if _, ok := r.labels[key]; ok && key != OperationLabel {
+		r.labels[key] = value
+	}
+}
+
+func (r *metricsRecorder) End(err error) {
+	r.labels[OutcomeLabel] = instrument.OutcomeSuccess
+	if err != nil {
+		r.labels[OutcomeLabel] = instrument.OutcomeFailure
+	}
+
+	r.metrics.operations.With(r.labels).Inc()
+	r.metrics.durations.With(r.labels).Observe(r.metrics.now().Sub(r.startTime).Seconds())
+}
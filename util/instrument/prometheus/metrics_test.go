@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adonmo/goroom"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/adonmo/goroom/util/instrument"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Device struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type failingMigration struct{}
+
+func (m *failingMigration) GetBaseVersion() orm.VersionNumber {
+	return 1
+}
+
+func (m *failingMigration) GetTargetVersion() orm.VersionNumber {
+	return 2
+}
+
+func (m *failingMigration) Apply(db interface{}) error {
+	return fmt.Errorf("Disk full")
+}
+
+type MetricsTestSuite struct {
+	suite.Suite
+	DB      *gorm.DB
+	Metrics *Metrics
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+
+	s.Metrics = NewMetrics(1, 5)
+	s.Metrics.now = newClock()
+}
+
+//newClock Returns a clock which advances by 2 seconds on every call
+func newClock() func() time.Time {
+	clock := time.Unix(0, 0)
+	return func() time.Time {
+		clock = clock.Add(2 * time.Second)
+		return clock
+	}
+}
+
+func (s *MetricsTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+func (s *MetricsTestSuite) initialize(version orm.VersionNumber, migrations []orm.Migration) error {
+	appDB, errList := room.NewFromConfig(room.Config{
+		Entities:            []interface{}{Device{}},
+		DBA:                 adapter.NewGORM(s.DB),
+		Version:             version,
+		Migrations:          migrations,
+		IdentityCalculator:  new(adapter.EntityHashConstructor),
+		Instrumentation:     s.Metrics,
+		DestructiveFallback: room.DestructiveFallbackToCleanDB,
+	})
+	if len(errList) > 0 {
+		panic(errList)
+	}
+	_, err := goroom.Initialize(appDB)
+	return err
+}
+
+func (s *MetricsTestSuite) TestOperationCounts() {
+	assert.Nil(s.T(), s.initialize(1, []orm.Migration{}))
+	assert.Nil(s.T(), s.initialize(2, []orm.Migration{&failingMigration{}}), "Destructive fallback should recover")
+
+	count := func(labels prom.Labels) float64 {
+		return testutil.ToFloat64(s.Metrics.operations.With(labels))
+	}
+	assert.Equal(s.T(), float64(1), count(prom.Labels{
+		"operation": "migration", "from_version": "1", "to_version": "2", "scenario": "", "mode": "", "outcome": "failure",
+	}))
+	assert.Equal(s.T(), float64(1), count(prom.Labels{
+		"operation": "init", "from_version": "1", "to_version": "2", "scenario": "migration", "mode": "", "outcome": "failure",
+	}))
+	assert.Equal(s.T(), float64(1), count(prom.Labels{
+		"operation": "cleanup", "from_version": "", "to_version": "2", "scenario": "", "mode": "full", "outcome": "success",
+	}))
+	assert.Equal(s.T(), float64(1), count(prom.Labels{
+		"operation": "init", "from_version": "", "to_version": "2", "scenario": "create", "mode": "", "outcome": "success",
+	}), "Init retried after clean up should create the DB")
+}
+
+func (s *MetricsTestSuite) TestCollect() {
+	recorder := s.Metrics.StartOperation(room.OperationCleanUp, map[string]string{room.LabelToVersion: "2"})
+	recorder.SetLabel(room.LabelCleanUpMode, room.CleanUpModeFull)
+	recorder.SetLabel("unknown", "dropped")
+	recorder.End(nil)
+
+	expected := `
+# HELP goroom_operation_duration_seconds Duration of Room operations in seconds by operation, versions and outcome
+# TYPE goroom_operation_duration_seconds histogram
+goroom_operation_duration_seconds_bucket{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2",le="1"} 0
+goroom_operation_duration_seconds_bucket{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2",le="5"} 1
+goroom_operation_duration_seconds_bucket{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2",le="+Inf"} 1
+goroom_operation_duration_seconds_sum{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2"} 2
+goroom_operation_duration_seconds_count{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2"} 1
+# HELP goroom_operations_total Number of Room operations that ended by operation, versions and outcome
+# TYPE goroom_operations_total counter
+goroom_operations_total{from_version="",mode="full",operation="cleanup",outcome="success",scenario="",to_version="2"} 1
+`
+	assert.Nil(s.T(), testutil.CollectAndCompare(s.Metrics, strings.NewReader(expected)))
+}
+
+func (s *MetricsTestSuite) TestRegister() {
+	registry := prom.NewPedanticRegistry()
+	assert.Nil(s.T(), registry.Register(s.Metrics))
+	assert.Nil(s.T(), s.initialize(1, []orm.Migration{}))
+
+	count, err := testutil.GatherAndCount(registry, instrument.OperationsTotalMetric)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, count, "Identity hash calculation and init")
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
@@ -0,0 +1,87 @@
+package instrument
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//SpanNamePrefix Prefix of the names of spans recorded for Room operations
+const SpanNamePrefix = "goroom."
+
+//Span Finished span of a Room operation. It is not an OpenTelemetry span but carries what is needed to record one
+type Span struct {
+	Name       string
+	Attributes map[string]string //Labels of the operation along with its outcome
+	StartTime  time.Time
+	EndTime    time.Time
+	Error      error
+}
+
+//SpanExporter Receives finished spans. Package util/instrument/opentelemetry records spans with an OpenTelemetry tracer instead
+type SpanExporter interface {
+	ExportSpan(span Span)
+}
+
+//Tracer Instrumentation recording a span for each Room operation
+type Tracer struct {
+	exporter SpanExporter
+	now      func() time.Time
+}
+
+//NewTracer Returns an instrumentation exporting a span per Room operation to the given exporter
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		now:      time.Now,
+	}
+}
+
+//StartOperation Starts the span of an operation
+func (t *Tracer) StartOperation(operation string, labels map[string]string) orm.OperationRecorder {
+	return &spanRecorder{
+		tracer: t,
+		span: Span{
+			Name:       SpanNamePrefix + operation,
+			Attributes: copyLabels(labels),
+			StartTime:  t.now(),
+		},
+	}
+}
+
+type spanRecorder struct {
+	tracer *Tracer
+	span   Span
+}
+
+func (r *spanRecorder) SetLabel(key string, value string) {
+	r.span.Attributes[key] = value
+}
+
+func (r *spanRecorder) End(err error) {
+	r.span.EndTime = r.tracer.now()
+	r.span.Error = err
+	r.span.Attributes["outcome"] = getOutcome(err)
+	r.tracer.exporter.ExportSpan(r.span)
+}
+
+//InMemoryExporter Keeps finished spans in process. Useful in tests
+type InMemoryExporter struct {
+	mutex sync.Mutex
+	spans []Span
+}
+
+//ExportSpan Stores the span
+func (e *InMemoryExporter) ExportSpan(span Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+//GetSpans Spans exported so far in the order they ended
+func (e *InMemoryExporter) GetSpans() []Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]Span(nil), e.spans...)
+}
@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/adonmo/goroom/orm"
+)
+
+//FileLock Lock backed by a lock file next to the database. Meant for SQLite databases shared by processes on the same device
+type FileLock struct {
+	path    string
+	options Options
+	mutex   sync.Mutex
+	file    *os.File
+	token   string //Written to the lock file when it is held without flock
+	lease   *lease //Renews the lock file when it is held without flock
+}
+
+//NewFileLock Returns a lock on the given lock file path
+func NewFileLock(path string, options Options) orm.Locker {
+	return &FileLock{
+		path:    path,
+		options: options.withDefaults(),
+	}
+}
+
+//NewSQLiteFileLock Returns a file lock for the SQLite database file at given path
+func NewSQLiteFileLock(dbFilePath string, options Options) orm.Locker {
+	return NewFileLock(dbFilePath+".lock", options)
+}
+
+//Lock Acquire the lock file waiting for it if held by another process
+func (l *FileLock) Lock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file != nil {
+		return fmt.Errorf("Lock %v is already held by %v", l.path, l.options.Owner)
+	}
+
+	var file *os.File
+	err := acquire(l.options, func() (acquired bool, holder string, err error) {
+		file, acquired, holder, err = l.tryLockFile()
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	l.file = file
+	return nil
+}
+
+//Unlock Release the lock file
+func (l *FileLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("Lock %v is not held by %v", l.path, l.options.Owner)
+	}
+
+	err := l.unlockFile(l.file)
+	l.file = nil
+	return err
+}
+
+//CheckHeld Fails if a lock file held without flock was taken over or could not be renewed. Locks held through flock can not be lost
+func (l *FileLock) CheckHeld() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("Lock %v is not held by %v", l.path, l.options.Owner)
+	}
+	if l.lease == nil {
+		return nil
+	}
+	return l.lease.check()
+}
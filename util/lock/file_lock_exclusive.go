@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adonmo/goroom/logger"
+)
+
+//tryLockExclusiveFile Without flock the lock is an exclusively created file holding the owner along with a token of this hold.
+//Its modification time is renewed while held and the file is taken over once older than the lease
+func (l *FileLock) tryLockExclusiveFile() (file *os.File, acquired bool, holder string, err error) {
+	file, err = os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		content, _ := ioutil.ReadFile(l.path)
+		if info, statErr := os.Stat(l.path); statErr == nil && time.Since(info.ModTime()) > l.options.Lease {
+			//Left alone if another process took it over in the meanwhile
+			if current, _ := ioutil.ReadFile(l.path); string(current) == string(content) {
+				logger.Warnf("Took over stale lock file %v held by %v", l.path, getLockFileOwner(content))
+				os.Remove(l.path)
+			}
+		}
+		return nil, false, getLockFileOwner(content), nil
+	}
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	token := fmt.Sprintf("%v\n%v", l.options.Owner, time.Now().UnixNano())
+	if _, err = file.Write([]byte(token)); err != nil {
+		file.Close()
+		os.Remove(l.path)
+		return nil, false, "", err
+	}
+
+	l.token = token
+	l.lease = startLease(l.path, l.options.Lease, l.renewExclusiveFile)
+	return file, true, l.options.Owner, nil
+}
+
+//renewExclusiveFile Touches the lock file if it still holds the token of this hold
+func (l *FileLock) renewExclusiveFile() (held bool, err error) {
+	content, err := ioutil.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if string(content) != l.token {
+		return false, nil
+	}
+
+	now := time.Now()
+	return true, os.Chtimes(l.path, now, now)
+}
+
+//unlockExclusiveFile Removes the lock file only if it still holds the token of this hold
+func (l *FileLock) unlockExclusiveFile(file *os.File) error {
+	file.Close()
+	leaseErr := l.lease.stop()
+	token := l.token
+	l.lease = nil
+	l.token = ""
+
+	content, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	if string(content) != token {
+		return fmt.Errorf("Lock file %v was taken over by %v", l.path, getLockFileOwner(content))
+	}
+	if err = os.Remove(l.path); err != nil {
+		return err
+	}
+	return leaseErr
+}
+
+//getLockFileOwner Owner written on the first line of a lock file
+func getLockFileOwner(content []byte) string {
+	return strings.SplitN(string(content), "\n", 2)[0]
+}
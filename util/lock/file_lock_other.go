@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package lock
+
+import (
+	"os"
+)
+
+//tryLockFile Without flock the lock is an exclusively created file whose lease is renewed while held
+func (l *FileLock) tryLockFile() (file *os.File, acquired bool, holder string, err error) {
+	return l.tryLockExclusiveFile()
+}
+
+func (l *FileLock) unlockFile(file *os.File) error {
+	return l.unlockExclusiveFile(file)
+}
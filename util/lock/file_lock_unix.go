@@ -0,0 +1,46 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+)
+
+//tryLockFile Uses flock which the kernel releases when the holding process dies, so stale locks need no takeover
+func (l *FileLock) tryLockFile() (file *os.File, acquired bool, holder string, err error) {
+	file, err = os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		content, _ := ioutil.ReadAll(file)
+		file.Close()
+		return nil, false, string(content), nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, false, "", err
+	}
+
+	if err = file.Truncate(0); err == nil {
+		_, err = file.WriteAt([]byte(l.options.Owner), 0)
+	}
+	if err != nil {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+		return nil, false, "", err
+	}
+
+	return file, true, l.options.Owner, nil
+}
+
+func (l *FileLock) unlockFile(file *os.File) error {
+	defer file.Close()
+	file.Truncate(0)
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adonmo/goroom/logger"
+)
+
+//lease Renews the lease of a held lock in background. Renewals failing till the lease expires, or a renewal finding the lock
+//taken over, mean the lock is lost which CheckHeld of the lock reports so that the guarded operation is aborted
+type lease struct {
+	name     string
+	duration time.Duration
+	renew    func() (held bool, err error)
+
+	mutex    sync.Mutex
+	expiry   time.Time
+	renewErr error //Error of the last failed renewal
+	lost     bool  //Renewal found the lock held by someone else
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+//startLease Starts renewing a lease just acquired every third of its duration
+func startLease(name string, duration time.Duration, renew func() (held bool, err error)) *lease {
+	l := &lease{
+		name:     name,
+		duration: duration,
+		renew:    renew,
+		expiry:   time.Now().Add(duration),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	go l.keepRenewing()
+	return l
+}
+
+func (l *lease) keepRenewing() {
+	defer close(l.doneChan)
+
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopChan:
+			return
+		case <-ticker.C:
+			renewedAt := time.Now()
+			held, err := l.renew()
+
+			l.mutex.Lock()
+			switch {
+			case err != nil:
+				logger.Warnf("Unable to renew lease on lock %v. %v", l.name, err)
+				l.renewErr = err
+			case !held:
+				logger.Errorf("Lock %v was taken over while held", l.name)
+				l.lost = true
+			default:
+				l.expiry = renewedAt.Add(l.duration)
+			}
+			l.mutex.Unlock()
+		}
+	}
+}
+
+//check Fails if the lock is no longer held
+func (l *lease) check() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lost {
+		return fmt.Errorf("Lock %v was taken over by someone else", l.name)
+	}
+	if time.Now().After(l.expiry) {
+		if l.renewErr == nil {
+			return fmt.Errorf("Lease on lock %v expired before it could be renewed", l.name)
+		}
+		return fmt.Errorf("Lease on lock %v expired as it could not be renewed. %v", l.name, l.renewErr)
+	}
+	return nil
+}
+
+//stop Stops renewing the lease and reports whether the lock was held till then
+func (l *lease) stop() error {
+	close(l.stopChan)
+	<-l.doneChan
+	return l.check()
+}
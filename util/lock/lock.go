@@ -0,0 +1,82 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	//DefaultName Name of the lock used for Room initialization
+	DefaultName = "go_room_init"
+	//DefaultWaitTimeout Time to wait for a lock held by someone else
+	DefaultWaitTimeout = 30 * time.Second
+	//DefaultRetryInterval Time between attempts to acquire a lock held by someone else
+	DefaultRetryInterval = 100 * time.Millisecond
+	//DefaultLease Time after which a lock that was not renewed is considered stale and can be taken over
+	DefaultLease = 2 * time.Minute
+)
+
+//Options Configures how a lock is identified and how long to wait for it
+type Options struct {
+	Name          string
+	Owner         string
+	WaitTimeout   time.Duration
+	RetryInterval time.Duration
+	Lease         time.Duration
+}
+
+//TimeoutError Returned when a lock could not be acquired within the wait timeout
+type TimeoutError struct {
+	Name   string
+	Holder string
+	Waited time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("Timed out after %v waiting for lock %v held by %v", e.Waited, e.Name, e.Holder)
+}
+
+func (options Options) withDefaults() Options {
+	if options.Name == "" {
+		options.Name = DefaultName
+	}
+	if options.Owner == "" {
+		hostname, _ := os.Hostname()
+		options.Owner = fmt.Sprintf("%v:%v:%v", hostname, os.Getpid(), time.Now().UnixNano())
+	}
+	if options.WaitTimeout <= 0 {
+		options.WaitTimeout = DefaultWaitTimeout
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = DefaultRetryInterval
+	}
+	if options.Lease <= 0 {
+		options.Lease = DefaultLease
+	}
+	return options
+}
+
+//acquire Retries tryLock till it succeeds, fails or the wait timeout runs out. tryLock reports the current holder when the lock is taken
+func acquire(options Options, tryLock func() (acquired bool, holder string, err error)) error {
+	start := time.Now()
+	for {
+		acquired, holder, err := tryLock()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		waited := time.Since(start)
+		if waited >= options.WaitTimeout {
+			return &TimeoutError{
+				Name:   options.Name,
+				Holder: holder,
+				Waited: waited,
+			}
+		}
+		time.Sleep(options.RetryInterval)
+	}
+}
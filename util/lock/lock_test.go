@@ -0,0 +1,198 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LockTestSuite struct {
+	suite.Suite
+	Dir string
+	DB  *gorm.DB
+}
+
+func (s *LockTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "goroom_lock")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		panic(err)
+	}
+	s.DB = db
+}
+
+func (s *LockTestSuite) TearDownTest() {
+	s.DB.Close()
+	os.RemoveAll(s.Dir)
+}
+
+func (s *LockTestSuite) options(owner string) Options {
+	return Options{
+		Owner:         owner,
+		WaitTimeout:   50 * time.Millisecond,
+		RetryInterval: 5 * time.Millisecond,
+	}
+}
+
+func (s *LockTestSuite) TestTableLock() {
+	first := NewTableLock(s.DB, s.options("first"))
+	second := NewTableLock(s.DB, s.options("second"))
+
+	assert.Nil(s.T(), first.Lock())
+
+	err := second.Lock()
+	timeoutErr, ok := err.(*TimeoutError)
+	assert.True(s.T(), ok, "Expected a timeout error. Got %v", err)
+	assert.Equal(s.T(), "first", timeoutErr.Holder)
+	assert.Equal(s.T(), DefaultName, timeoutErr.Name)
+
+	assert.Nil(s.T(), first.Unlock())
+	assert.Nil(s.T(), second.Lock())
+	assert.Nil(s.T(), second.Unlock())
+}
+
+func (s *LockTestSuite) TestTableLockTakesOverStaleLock() {
+	assert.Nil(s.T(), s.DB.CreateTable(GoRoomLock{}).Error)
+	assert.Nil(s.T(), s.DB.Create(&GoRoomLock{
+		Name:        DefaultName,
+		Owner:       "crashed",
+		LeaseExpiry: toMillis(time.Now().Add(-time.Second)),
+	}).Error)
+
+	l := NewTableLock(s.DB, s.options("survivor"))
+	assert.Nil(s.T(), l.Lock())
+
+	var record GoRoomLock
+	assert.Nil(s.T(), s.DB.Where("name = ?", DefaultName).First(&record).Error)
+	assert.Equal(s.T(), "survivor", record.Owner)
+	assert.Nil(s.T(), l.Unlock())
+}
+
+func (s *LockTestSuite) TestTableLockRenewsLease() {
+	options := s.options("first")
+	options.Lease = 30 * time.Millisecond
+	first := NewTableLock(s.DB, options)
+	assert.Nil(s.T(), first.Lock())
+
+	//Lease would have expired by now had it not been renewed
+	time.Sleep(60 * time.Millisecond)
+	_, ok := NewTableLock(s.DB, s.options("second")).Lock().(*TimeoutError)
+	assert.True(s.T(), ok, "Lock with renewed lease should not be taken over")
+
+	assert.Nil(s.T(), first.Unlock())
+}
+
+func (s *LockTestSuite) TestTableLockTakenOverWhileHeld() {
+	options := s.options("first")
+	options.Lease = 30 * time.Millisecond
+	first := NewTableLock(s.DB, options).(*TableLock)
+	assert.Nil(s.T(), first.Lock())
+	assert.Nil(s.T(), first.CheckHeld())
+
+	s.DB.Model(&GoRoomLock{}).Where("name = ?", DefaultName).Update("owner", "second")
+	time.Sleep(40 * time.Millisecond)
+
+	expectedError := "Lock go_room_init was taken over by someone else"
+	assert.EqualError(s.T(), first.CheckHeld(), expectedError)
+	assert.EqualError(s.T(), first.Unlock(), expectedError)
+	var record GoRoomLock
+	assert.Nil(s.T(), s.DB.Where("name = ?", DefaultName).First(&record).Error)
+	assert.Equal(s.T(), "second", record.Owner, "Lock of the new owner must not be released")
+}
+
+func (s *LockTestSuite) TestTableLockWithFailingRenewal() {
+	options := s.options("first")
+	options.Lease = 30 * time.Millisecond
+	first := NewTableLock(s.DB, options).(*TableLock)
+	assert.Nil(s.T(), first.Lock())
+
+	s.DB.Close()
+	time.Sleep(40 * time.Millisecond)
+
+	assert.EqualError(s.T(), first.CheckHeld(), "Lease on lock go_room_init expired as it could not be renewed. sql: database is closed")
+	assert.NotNil(s.T(), first.Unlock())
+}
+
+func (s *LockTestSuite) TestTableLockMisuse() {
+	l := NewTableLock(s.DB, s.options("first"))
+
+	assert.NotNil(s.T(), l.Unlock(), "Unlock without Lock should fail")
+	assert.Nil(s.T(), l.Lock())
+	assert.NotNil(s.T(), l.Lock(), "Lock should not be reentrant")
+	assert.Nil(s.T(), l.Unlock())
+}
+
+func (s *LockTestSuite) TestFileLock() {
+	dbFilePath := filepath.Join(s.Dir, "test.db")
+	first := NewSQLiteFileLock(dbFilePath, s.options("first"))
+	second := NewSQLiteFileLock(dbFilePath, s.options("second"))
+
+	assert.Nil(s.T(), first.Lock())
+
+	err := second.Lock()
+	timeoutErr, ok := err.(*TimeoutError)
+	assert.True(s.T(), ok, "Expected a timeout error. Got %v", err)
+	assert.Equal(s.T(), "first", timeoutErr.Holder)
+
+	assert.Nil(s.T(), first.Unlock())
+	assert.Nil(s.T(), second.Lock())
+	assert.Nil(s.T(), second.Unlock())
+}
+
+func (s *LockTestSuite) TestExclusiveFileLock() {
+	options := s.options("first")
+	options.Lease = 30 * time.Millisecond
+	path := filepath.Join(s.Dir, "test.db.lock")
+	first := NewFileLock(path, options).(*FileLock)
+	second := NewFileLock(path, s.options("second")).(*FileLock)
+
+	file, acquired, _, err := first.tryLockExclusiveFile()
+	assert.True(s.T(), acquired && err == nil)
+	first.file = file
+
+	//Lock file would be stale by now had it not been renewed
+	time.Sleep(60 * time.Millisecond)
+	_, acquired, holder, err := second.tryLockExclusiveFile()
+	assert.False(s.T(), acquired, "Renewed lock file should not be taken over")
+	assert.Equal(s.T(), "first", holder)
+	assert.Nil(s.T(), first.CheckHeld())
+
+	assert.Nil(s.T(), first.unlockExclusiveFile(file))
+	_, err = os.Stat(path)
+	assert.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *LockTestSuite) TestExclusiveFileLockTakenOverWhileHeld() {
+	options := s.options("first")
+	options.Lease = 30 * time.Millisecond
+	path := filepath.Join(s.Dir, "test.db.lock")
+	first := NewFileLock(path, options).(*FileLock)
+
+	file, acquired, _, err := first.tryLockExclusiveFile()
+	assert.True(s.T(), acquired && err == nil)
+	first.file = file
+
+	ioutil.WriteFile(path, []byte("second\n1"), 0644)
+	time.Sleep(40 * time.Millisecond)
+
+	assert.EqualError(s.T(), first.CheckHeld(), "Lock "+path+" was taken over by someone else")
+	assert.EqualError(s.T(), first.unlockExclusiveFile(file), "Lock file "+path+" was taken over by second")
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(s.T(), "second\n1", string(content), "Lock file of the new owner must not be removed")
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(LockTestSuite))
+}
@@ -0,0 +1,91 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/jinzhu/gorm"
+)
+
+//PostgresAdvisoryLock Lock backed by a Postgres session level advisory lock.
+//The lock is bound to a dedicated connection so it is released by the server if the owning process dies.
+type PostgresAdvisoryLock struct {
+	db      *gorm.DB
+	options Options
+	key     int64
+	mutex   sync.Mutex
+	conn    *sql.Conn
+}
+
+//NewPostgresAdvisoryLock Returns an advisory lock whose key is derived from the lock name
+func NewPostgresAdvisoryLock(db *gorm.DB, options Options) orm.Locker {
+	options = options.withDefaults()
+	return &PostgresAdvisoryLock{
+		db:      db,
+		options: options,
+		key:     getAdvisoryLockKey(options.Name),
+	}
+}
+
+//Lock Acquire the advisory lock waiting for it if held by another session
+func (l *PostgresAdvisoryLock) Lock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.conn != nil {
+		return fmt.Errorf("Lock %v is already held by %v", l.options.Name, l.options.Owner)
+	}
+
+	conn, err := l.db.DB().Conn(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = acquire(l.options, func() (bool, string, error) {
+		var acquired bool
+		err := conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired)
+		return acquired, fmt.Sprintf("another session(key %v)", l.key), err
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	l.conn = conn
+	return nil
+}
+
+//Unlock Release the advisory lock and the connection holding it
+func (l *PostgresAdvisoryLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.conn == nil {
+		return fmt.Errorf("Lock %v is not held by %v", l.options.Name, l.options.Owner)
+	}
+
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+
+	var released bool
+	if err := l.conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key).Scan(&released); err != nil {
+		return err
+	}
+	if !released {
+		return fmt.Errorf("Advisory lock %v was not held by this session", l.options.Name)
+	}
+
+	return nil
+}
+
+func getAdvisoryLockKey(name string) int64 {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(name))
+	return int64(hasher.Sum64())
+}
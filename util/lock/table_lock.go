@@ -0,0 +1,135 @@
+package lock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adonmo/goroom/logger"
+	"github.com/adonmo/goroom/orm"
+	"github.com/jinzhu/gorm"
+)
+
+//GoRoomLock Lease based lock record used by TableLock
+type GoRoomLock struct {
+	Name        string `gorm:"primary_key"`
+	Owner       string
+	LeaseExpiry int64 //Unix time in milliseconds after which the lock is considered stale
+}
+
+//TableLock Lock backed by a lease record in the database. Works with any SQL database supported by GORM except SQLite.
+//The lease is renewed through a separate connection, which SQLite blocks with SQLITE_BUSY while a long migration holds
+//the write lock, so the lease could expire mid migration. Use the flock based FileLock of NewSQLiteFileLock for SQLite
+type TableLock struct {
+	db      *gorm.DB
+	options Options
+	mutex   sync.Mutex
+	lease   *lease
+}
+
+//NewTableLock Returns a lock stored as a row in the lock table of given DB
+func NewTableLock(db *gorm.DB, options Options) orm.Locker {
+	return &TableLock{
+		db:      db,
+		options: options.withDefaults(),
+	}
+}
+
+//Lock Acquire the lock taking it over if its lease has expired. The lease is renewed in background till Unlock
+func (l *TableLock) Lock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lease != nil {
+		return fmt.Errorf("Lock %v is already held by %v", l.options.Name, l.options.Owner)
+	}
+
+	if !l.db.HasTable(GoRoomLock{}) {
+		//Another process could have created it in the meanwhile hence existence is checked again on failure
+		if err := l.db.CreateTable(GoRoomLock{}).Error; err != nil && !l.db.HasTable(GoRoomLock{}) {
+			return err
+		}
+	}
+
+	err := acquire(l.options, l.tryLock)
+	if err != nil {
+		return err
+	}
+
+	l.lease = startLease(l.options.Name, l.options.Lease, l.renewLease)
+	return nil
+}
+
+//Unlock Release the lock if it is still held by this owner. Fails if the lock was lost while held
+func (l *TableLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lease == nil {
+		return fmt.Errorf("Lock %v is not held by %v", l.options.Name, l.options.Owner)
+	}
+
+	leaseErr := l.lease.stop()
+	l.lease = nil
+
+	if err := l.db.Where("name = ? AND owner = ?", l.options.Name, l.options.Owner).Delete(GoRoomLock{}).Error; err != nil {
+		return err
+	}
+	return leaseErr
+}
+
+//CheckHeld Fails if the lease could not be renewed in time or the lock was taken over
+func (l *TableLock) CheckHeld() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lease == nil {
+		return fmt.Errorf("Lock %v is not held by %v", l.options.Name, l.options.Owner)
+	}
+	return l.lease.check()
+}
+
+func (l *TableLock) tryLock() (acquired bool, holder string, err error) {
+	now := time.Now()
+
+	//Stale lease left behind by a crashed owner
+	dbExec := l.db.Where("name = ? AND lease_expiry < ?", l.options.Name, toMillis(now)).Delete(GoRoomLock{})
+	if dbExec.Error != nil {
+		return false, "", dbExec.Error
+	}
+	if dbExec.RowsAffected > 0 {
+		logger.Warnf("Took over stale lock %v", l.options.Name)
+	}
+
+	record := GoRoomLock{
+		Name:        l.options.Name,
+		Owner:       l.options.Owner,
+		LeaseExpiry: toMillis(now.Add(l.options.Lease)),
+	}
+	insertErr := l.db.Create(&record).Error
+	if insertErr == nil {
+		return true, l.options.Owner, nil
+	}
+
+	var existing GoRoomLock
+	if err = l.db.Where("name = ?", l.options.Name).First(&existing).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			//Released between our insert and this read
+			return false, "", nil
+		}
+		return false, "", insertErr
+	}
+
+	return false, existing.Owner, nil
+}
+
+//renewLease Extends the lease of the record. No record of this owner means the lock was taken over
+func (l *TableLock) renewLease() (held bool, err error) {
+	dbExec := l.db.Model(&GoRoomLock{}).Where("name = ? AND owner = ?", l.options.Name, l.options.Owner).
+		Update("lease_expiry", toMillis(time.Now().Add(l.options.Lease)))
+	return dbExec.RowsAffected > 0, dbExec.Error
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,161 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adonmo/goroom/logger"
+	"github.com/adonmo/goroom/orm"
+)
+
+const (
+	//DefaultReserve Bytes kept free on the filesystem beyond the estimated space needed
+	DefaultReserve int64 = 16 << 20
+	//DefaultRebuildFactor Multiple of the size of the tables a migration affects assumed needed by it e.g. to copy and rebuild them
+	DefaultRebuildFactor = 2.0
+)
+
+//DiskSpaceOptions Configures the disk space check
+type DiskSpaceOptions struct {
+	Path          string                                            //Path of the DB file. Free space of its filesystem is checked and its size used for estimates
+	Reserve       int64                                             //Bytes that should remain free once the estimated space is used. Defaults to DefaultReserve
+	RebuildFactor float64                                           //Applied to table sizes for migrations without their own estimate. Defaults to DefaultRebuildFactor
+	TableSize     func(db interface{}, table string) (int64, error) //Size of a table in bytes. Without it the size of the whole DB is used for every migration
+	Snapshot      bool                                              //Adds the size of the DB for a snapshot taken before migrating e.g. by sqlite.Safety
+	Logger        logger.Logger                                     //Warned when the check is skipped as free space is unknown on the platform
+}
+
+func (options DiskSpaceOptions) withDefaults() DiskSpaceOptions {
+	if options.Reserve <= 0 {
+		options.Reserve = DefaultReserve
+	}
+	if options.RebuildFactor <= 0 {
+		options.RebuildFactor = DefaultRebuildFactor
+	}
+	if options.Logger == nil {
+		options.Logger = logger.Standard
+	}
+	return options
+}
+
+//errFreeSpaceUnsupported Free space can not be determined on the platform
+var errFreeSpaceUnsupported = errors.New("Free space is not supported on this platform")
+
+//InsufficientSpaceError Returned when the filesystem of the DB does not have the space estimated for the migrations
+type InsufficientSpaceError struct {
+	Path      string
+	Needed    int64 //Estimated space needed including the reserve
+	Available int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("Insufficient space for %v. %v bytes needed while %v bytes are available", e.Path, e.Needed, e.Available)
+}
+
+//DiskSpace Checks that the filesystem of a DB has the space migrations are estimated to need. Migrations implementing
+//orm.SpaceEstimatingMigration give their own estimate. For others the sizes of the tables declared by an
+//orm.TableScopedMigration, or the size of the DB, are multiplied by the rebuild factor
+type DiskSpace struct {
+	options   DiskSpaceOptions
+	freeSpace func(path string) (int64, error)
+}
+
+//NewDiskSpace Returns a disk space check for the DB file in the options
+func NewDiskSpace(options DiskSpaceOptions) *DiskSpace {
+	return &DiskSpace{
+		options:   options.withDefaults(),
+		freeSpace: getFreeSpace,
+	}
+}
+
+//GetName Name of the check
+func (c *DiskSpace) GetName() string {
+	return "disk-space"
+}
+
+//Check Fails with *InsufficientSpaceError if the estimated space and the reserve exceed the free space. Passes with a warning
+//on platforms where free space can not be determined
+func (c *DiskSpace) Check(db orm.ORM, from orm.VersionNumber, to orm.VersionNumber, migrations []orm.Migration) error {
+	estimate, err := c.EstimateSpaceNeeded(db, migrations)
+	if err != nil {
+		return err
+	}
+
+	available, err := c.freeSpace(filepath.Dir(c.options.Path))
+	if err == errFreeSpaceUnsupported {
+		c.options.Logger.Warnf("Skipping disk space check for %v. %v", c.options.Path, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to determine free space for %v. %v", c.options.Path, err)
+	}
+
+	needed := estimate + c.options.Reserve
+	if needed > available {
+		return &InsufficientSpaceError{Path: c.options.Path, Needed: needed, Available: available}
+	}
+	return nil
+}
+
+//EstimateSpaceNeeded Estimated bytes needed by the given migrations. Migrations are applied one after the other hence the
+//largest estimate is taken rather than their sum
+func (c *DiskSpace) EstimateSpaceNeeded(db orm.ORM, migrations []orm.Migration) (int64, error) {
+	dbSize, err := c.getDBSize()
+	if err != nil {
+		return 0, err
+	}
+
+	var largest int64
+	for _, migration := range migrations {
+		estimate, err := c.estimateMigration(db, migration, dbSize)
+		if err != nil {
+			return 0, err
+		}
+		if estimate > largest {
+			largest = estimate
+		}
+	}
+
+	if c.options.Snapshot {
+		largest += dbSize
+	}
+	return largest, nil
+}
+
+func (c *DiskSpace) estimateMigration(db orm.ORM, migration orm.Migration, dbSize int64) (int64, error) {
+	if estimatingMigration, ok := migration.(orm.SpaceEstimatingMigration); ok {
+		return estimatingMigration.EstimateSpaceNeeded(db.GetUnderlyingORM())
+	}
+
+	size := dbSize
+	if scopedMigration, ok := migration.(orm.TableScopedMigration); ok && c.options.TableSize != nil {
+		size = 0
+		for _, table := range scopedMigration.GetAffectedTables() {
+			tableSize, err := c.options.TableSize(db.GetUnderlyingORM(), table)
+			if err != nil {
+				return 0, fmt.Errorf("Unable to determine size of table %v. %v", table, err)
+			}
+			size += tableSize
+		}
+	}
+
+	return int64(float64(size) * c.options.RebuildFactor), nil
+}
+
+//getDBSize Size of the DB file along with its write ahead log if any
+func (c *DiskSpace) getDBSize() (int64, error) {
+	var size int64
+	for _, path := range []string{c.options.Path, c.options.Path + "-wal"} {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) && path != c.options.Path {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
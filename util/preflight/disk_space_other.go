@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!dragonfly,!windows
+
+package preflight
+
+//getFreeSpace Free space can not be determined portably on the remaining platforms, so the check is skipped there
+func getFreeSpace(path string) (int64, error) {
+	return 0, errFreeSpaceUnsupported
+}
@@ -0,0 +1,239 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Sensor struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type renameMigration struct {
+	applied bool
+}
+
+func (m *renameMigration) GetBaseVersion() orm.VersionNumber   { return 1 }
+func (m *renameMigration) GetTargetVersion() orm.VersionNumber { return 2 }
+func (m *renameMigration) Apply(db interface{}) error {
+	m.applied = true
+	return db.(*gorm.DB).Exec("UPDATE sensors SET name = 'renamed'").Error
+}
+
+type estimatingMigration struct {
+	renameMigration
+	estimate int64
+}
+
+func (m *estimatingMigration) EstimateSpaceNeeded(db interface{}) (int64, error) {
+	return m.estimate, nil
+}
+
+type scopedMigration struct {
+	renameMigration
+}
+
+func (m *scopedMigration) GetAffectedTables() []string {
+	return []string{"sensors", "readings"}
+}
+
+type warningLogger struct {
+	warnings []string
+}
+
+func (l *warningLogger) Debugf(format string, v ...interface{}) {}
+func (l *warningLogger) Infof(format string, v ...interface{})  {}
+func (l *warningLogger) Errorf(format string, v ...interface{}) {}
+func (l *warningLogger) Warnf(format string, v ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+type DiskSpaceTestSuite struct {
+	suite.Suite
+	Dir    string
+	Path   string
+	DB     *gorm.DB
+	DBSize int64
+}
+
+func (s *DiskSpaceTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "goroom_preflight")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+	s.Path = filepath.Join(dir, "app.db")
+
+	s.DB, err = gorm.Open("sqlite3", s.Path)
+	if err != nil {
+		panic(err)
+	}
+	s.DB.CreateTable(Sensor{})
+	s.DB.Create(&Sensor{ID: 1, Name: "thermometer"})
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		panic(err)
+	}
+	s.DBSize = info.Size()
+}
+
+func (s *DiskSpaceTestSuite) TearDownTest() {
+	s.DB.Close()
+	os.RemoveAll(s.Dir)
+}
+
+func (s *DiskSpaceTestSuite) newCheck(options DiskSpaceOptions, available int64) *DiskSpace {
+	options.Path = s.Path
+	check := NewDiskSpace(options)
+	check.freeSpace = func(path string) (int64, error) {
+		assert.Equal(s.T(), s.Dir, path)
+		return available, nil
+	}
+	return check
+}
+
+func (s *DiskSpaceTestSuite) TestEstimateSpaceNeeded() {
+	dba := adapter.NewGORM(s.DB)
+	tableSize := func(db interface{}, table string) (int64, error) {
+		assert.Equal(s.T(), s.DB, db)
+		return map[string]int64{"sensors": 100, "readings": 50}[table], nil
+	}
+
+	testCases := []struct {
+		options    DiskSpaceOptions
+		migrations []orm.Migration
+		expected   int64
+	}{
+		{DiskSpaceOptions{}, []orm.Migration{&renameMigration{}}, 2 * s.DBSize},
+		{DiskSpaceOptions{RebuildFactor: 3}, []orm.Migration{&renameMigration{}}, 3 * s.DBSize},
+		{DiskSpaceOptions{Snapshot: true}, []orm.Migration{&renameMigration{}}, 3 * s.DBSize},
+		{DiskSpaceOptions{}, []orm.Migration{&estimatingMigration{estimate: 10}}, 10},
+		{DiskSpaceOptions{}, []orm.Migration{&estimatingMigration{estimate: 10}, &estimatingMigration{estimate: 30}}, 30},
+		{DiskSpaceOptions{TableSize: tableSize}, []orm.Migration{&scopedMigration{}}, 300},
+		{DiskSpaceOptions{TableSize: tableSize}, []orm.Migration{&renameMigration{}}, 2 * s.DBSize},
+		{DiskSpaceOptions{}, []orm.Migration{&scopedMigration{}}, 2 * s.DBSize},
+	}
+
+	for i, testCase := range testCases {
+		estimate, err := s.newCheck(testCase.options, 0).EstimateSpaceNeeded(dba, testCase.migrations)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), testCase.expected, estimate, "Test case %v", i)
+	}
+}
+
+func (s *DiskSpaceTestSuite) TestEstimateSpaceNeededWithFailingTableSize() {
+	check := s.newCheck(DiskSpaceOptions{TableSize: func(db interface{}, table string) (int64, error) {
+		return 0, fmt.Errorf("No such table")
+	}}, 0)
+
+	_, err := check.EstimateSpaceNeeded(adapter.NewGORM(s.DB), []orm.Migration{&scopedMigration{}})
+	assert.Equal(s.T(), fmt.Errorf("Unable to determine size of table sensors. No such table"), err)
+}
+
+func (s *DiskSpaceTestSuite) TestCheck() {
+	dba := adapter.NewGORM(s.DB)
+	migrations := []orm.Migration{&estimatingMigration{estimate: 1000}}
+
+	assert.Nil(s.T(), s.newCheck(DiskSpaceOptions{Reserve: 24}, 1024).Check(dba, 1, 2, migrations))
+	assert.Equal(s.T(), &InsufficientSpaceError{Path: s.Path, Needed: 1025, Available: 1024},
+		s.newCheck(DiskSpaceOptions{Reserve: 25}, 1024).Check(dba, 1, 2, migrations))
+	assert.Equal(s.T(), &InsufficientSpaceError{Path: s.Path, Needed: 1000 + DefaultReserve, Available: 1024},
+		s.newCheck(DiskSpaceOptions{}, 1024).Check(dba, 1, 2, migrations))
+}
+
+func (s *DiskSpaceTestSuite) TestCheckWithoutFreeSpace() {
+	check := s.newCheck(DiskSpaceOptions{}, 0)
+	check.freeSpace = func(path string) (int64, error) {
+		return 0, fmt.Errorf("Not supported")
+	}
+
+	err := check.Check(adapter.NewGORM(s.DB), 1, 2, []orm.Migration{&renameMigration{}})
+	assert.Equal(s.T(), fmt.Errorf("Unable to determine free space for %v. Not supported", s.Path), err)
+}
+
+func (s *DiskSpaceTestSuite) TestCheckSkippedWhereFreeSpaceIsUnsupported() {
+	warnings := &warningLogger{}
+	check := s.newCheck(DiskSpaceOptions{Logger: warnings}, 0)
+	check.freeSpace = func(path string) (int64, error) {
+		return 0, errFreeSpaceUnsupported
+	}
+
+	err := check.Check(adapter.NewGORM(s.DB), 1, 2, []orm.Migration{&renameMigration{}})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{fmt.Sprintf("Skipping disk space check for %v. Free space is not supported on this platform", s.Path)}, warnings.warnings)
+}
+
+func (s *DiskSpaceTestSuite) TestFreeSpaceOfFilesystem() {
+	available, err := getFreeSpace(s.Dir)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), available > 0)
+}
+
+func (s *DiskSpaceTestSuite) TestInitAbortedWithoutChangingDB() {
+	config := room.Config{
+		Entities:           []interface{}{Sensor{}},
+		DBA:                adapter.NewGORM(s.DB),
+		Version:            1,
+		IdentityCalculator: new(adapter.EntityHashConstructor),
+	}
+	roomDB, errs := room.NewFromConfig(config)
+	assert.Empty(s.T(), errs)
+	identityHash, _ := roomDB.CalculateIdentityHash()
+	_, err := roomDB.Init(identityHash)
+	assert.Nil(s.T(), err)
+
+	migration := &renameMigration{}
+	migrationHookCalled := false
+	config.Version = 2
+	config.Migrations = []orm.Migration{migration}
+	config.Hooks.BeforeMigration = func(from orm.VersionNumber, to orm.VersionNumber) error {
+		migrationHookCalled = true
+		return nil
+	}
+	config.PreflightChecks = []orm.PreflightCheck{s.newCheck(DiskSpaceOptions{}, 1024)}
+	roomDB, _ = room.NewFromConfig(config)
+
+	shouldRetry, err := roomDB.Init(identityHash)
+	assert.False(s.T(), shouldRetry)
+	var preflightErr *room.PreflightError
+	assert.True(s.T(), errors.As(err, &preflightErr))
+	assert.Equal(s.T(), "disk-space", preflightErr.Check)
+	var spaceErr *InsufficientSpaceError
+	assert.True(s.T(), errors.As(err, &spaceErr))
+	assert.False(s.T(), migration.applied)
+	assert.False(s.T(), migrationHookCalled)
+
+	_, version, _ := adapter.NewGORM(s.DB).GetLatestSchemaIdentityHashAndVersion()
+	assert.Equal(s.T(), 1, version)
+	var sensor Sensor
+	s.DB.First(&sensor)
+	assert.Equal(s.T(), "thermometer", sensor.Name)
+}
+
+func (s *DiskSpaceTestSuite) TestFunc() {
+	checkError := fmt.Errorf("Device not charging")
+	check := NewFunc("charging", func(db orm.ORM, from orm.VersionNumber, to orm.VersionNumber, migrations []orm.Migration) error {
+		return checkError
+	})
+
+	assert.Equal(s.T(), "charging", check.GetName())
+	assert.Equal(s.T(), checkError, check.Check(nil, 1, 2, nil))
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(DiskSpaceTestSuite))
+}
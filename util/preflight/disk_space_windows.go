@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package preflight
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+//getFreeSpace Bytes available to the user running the process on the volume holding the path
+func getFreeSpace(path string) (int64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available, total, free uint64
+	result, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&available)), uintptr(unsafe.Pointer(&total)), uintptr(unsafe.Pointer(&free)))
+	if result == 0 {
+		return 0, err
+	}
+	return int64(available), nil
+}
@@ -0,0 +1,23 @@
+package preflight
+
+import "github.com/adonmo/goroom/orm"
+
+type funcCheck struct {
+	name  string
+	check func(db orm.ORM, from orm.VersionNumber, to orm.VersionNumber, migrations []orm.Migration) error
+}
+
+//NewFunc Returns a preflight check with the given name backed by a function e.g. to require a charging device
+func NewFunc(name string, check func(db orm.ORM, from orm.VersionNumber, to orm.VersionNumber, migrations []orm.Migration) error) orm.PreflightCheck {
+	return &funcCheck{name: name, check: check}
+}
+
+//GetName Name of the check
+func (c *funcCheck) GetName() string {
+	return c.name
+}
+
+//Check Runs the function
+func (c *funcCheck) Check(db orm.ORM, from orm.VersionNumber, to orm.VersionNumber, migrations []orm.Migration) error {
+	return c.check(db, from, to, migrations)
+}
@@ -0,0 +1,314 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adonmo/goroom/logger"
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/jinzhu/gorm"
+	"github.com/mattn/go-sqlite3"
+)
+
+//CheckLevel Integrity check run on the DB before migrating it
+type CheckLevel int
+
+const (
+	//NoCheck DB is migrated without checking it
+	NoCheck CheckLevel = iota
+	//QuickCheck PRAGMA quick_check which skips verifying that indexes match their tables
+	QuickCheck
+	//FullCheck PRAGMA integrity_check
+	FullCheck
+)
+
+//Options Configures the safeguards of a SQLite DB
+type Options struct {
+	Check         CheckLevel
+	SnapshotDir   string             //Directory snapshots are written to. Defaults to the directory of the DB
+	KeepSnapshots bool               //Snapshots taken before migrations are deleted once done unless set. Ones taken before clean up are always kept
+	Verifier      orm.SchemaVerifier //Run by VerifySchema after checking foreign keys
+	Logger        logger.Logger
+}
+
+//IntegrityError Problems reported by SQLite on checking the integrity of the DB. The DB is not migrated then
+type IntegrityError struct {
+	Problems []string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("Database failed integrity check. %v", strings.Join(e.Problems, "; "))
+}
+
+//Safety Safeguards migrations and destructive clean up of a SQLite DB managed by Room. It checks the DB and snapshots it
+//with VACUUM INTO before migrating, turns off foreign keys while tables are rebuilt and restores the snapshot if a failed migration
+//may have changed the DB. SQLite applies pragmas per connection hence the pool of the DB has to be limited to one connection
+//i.e. db.DB().SetMaxOpenConns(1). Restore refuses other pools
+type Safety struct {
+	db                 *gorm.DB
+	options            Options
+	snapshot           string //Taken before the ongoing migration
+	restoreForeignKeys bool   //Foreign keys were enforced before the ongoing migration
+}
+
+//New Returns safeguards for the given SQLite DB. Use them through Hooks and as the SchemaVerifier of the Room
+func New(db *gorm.DB, options Options) *Safety {
+	return &Safety{
+		db:      db,
+		options: options,
+	}
+}
+
+func (s *Safety) log() logger.Logger {
+	if s.options.Logger == nil {
+		return logger.Standard
+	}
+	return s.options.Logger
+}
+
+//Hooks Room hooks applying the safeguards around migrations and clean up. The given hooks are called before the safeguards
+//on the way in and after them on the way out
+func (s *Safety) Hooks(hooks room.Hooks) room.Hooks {
+	wrapped := hooks
+	wrapped.BeforeMigration = func(from orm.VersionNumber, to orm.VersionNumber) error {
+		if hooks.BeforeMigration != nil {
+			if err := hooks.BeforeMigration(from, to); err != nil {
+				return err
+			}
+		}
+		return s.beforeMigration(from, to)
+	}
+	wrapped.AfterMigration = func(from orm.VersionNumber, to orm.VersionNumber, err error) {
+		s.afterMigration(err)
+		if hooks.AfterMigration != nil {
+			hooks.AfterMigration(from, to, err)
+		}
+	}
+	wrapped.BeforeCleanUp = func() error {
+		if hooks.BeforeCleanUp != nil {
+			if err := hooks.BeforeCleanUp(); err != nil {
+				return err
+			}
+		}
+		snapshot, err := s.Snapshot("before_clean_up")
+		if err == nil {
+			s.log().Infof("Snapshot of the DB taken before clean up at %v", snapshot)
+		}
+		return err
+	}
+	return wrapped
+}
+
+func (s *Safety) beforeMigration(from orm.VersionNumber, to orm.VersionNumber) (err error) {
+	if err = s.CheckIntegrity(); err != nil {
+		return err
+	}
+
+	//Foreign keys can not be toggled inside the migration transaction, so they are turned off before it
+	var enforced bool
+	if err = s.db.Raw("PRAGMA foreign_keys").Row().Scan(&enforced); err != nil {
+		return err
+	}
+	if enforced {
+		if err = s.db.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+	}
+
+	//Snapshot is taken last so that no step failing after it leaves it behind
+	if s.snapshot, err = s.Snapshot(fmt.Sprintf("before_v%v_to_v%v", from, to)); err != nil {
+		if enforced {
+			if fkErr := s.db.Exec("PRAGMA foreign_keys = ON").Error; fkErr != nil {
+				s.log().Errorf("Unable to turn foreign keys back on. %v", fkErr)
+			}
+		}
+		return err
+	}
+	s.restoreForeignKeys = enforced
+	return nil
+}
+
+func (s *Safety) afterMigration(migrationErr error) {
+	snapshot := s.snapshot
+	s.snapshot = ""
+
+	//Migrations failing otherwise were rolled back and left the DB as it was snapshotted
+	var partialErr *room.PartialMigrationError
+	if errors.As(migrationErr, &partialErr) && snapshot != "" {
+		if err := s.Restore(snapshot); err != nil {
+			s.log().Errorf("Unable to restore snapshot %v after failed migration. %v", snapshot, err)
+			snapshot = ""
+		} else {
+			s.log().Warnf("Restored snapshot %v after failed migration", snapshot)
+		}
+	}
+
+	if s.restoreForeignKeys {
+		if err := s.db.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+			s.log().Errorf("Unable to turn foreign keys back on. %v", err)
+		}
+		s.restoreForeignKeys = false
+	}
+
+	if snapshot != "" && !s.options.KeepSnapshots {
+		os.Remove(snapshot)
+	}
+}
+
+//CheckIntegrity Runs the configured integrity check returning an IntegrityError if SQLite finds problems
+func (s *Safety) CheckIntegrity() error {
+	var pragma string
+	switch s.options.Check {
+	case QuickCheck:
+		pragma = "PRAGMA quick_check"
+	case FullCheck:
+		pragma = "PRAGMA integrity_check"
+	default:
+		return nil
+	}
+
+	rows, err := s.db.Raw(pragma).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err = rows.Scan(&problem); err != nil {
+			return err
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return &IntegrityError{Problems: problems}
+	}
+	return nil
+}
+
+//Snapshot Writes a consistent copy of the DB next to it, or in the snapshot directory, with VACUUM INTO. Returns the path of the copy
+func (s *Safety) Snapshot(label string) (string, error) {
+	var seq int
+	var name, file string
+	if err := s.db.Raw("PRAGMA database_list").Row().Scan(&seq, &name, &file); err != nil {
+		return "", err
+	}
+
+	dir := s.options.SnapshotDir
+	if dir == "" {
+		if file == "" {
+			return "", fmt.Errorf("Snapshot directory is needed for an in memory DB")
+		}
+		dir = filepath.Dir(file)
+	}
+	base := "memory"
+	if file != "" {
+		base = filepath.Base(file)
+	}
+
+	snapshot := filepath.Join(dir, fmt.Sprintf("%v.%v.%v", base, label, time.Now().UnixNano()))
+	if err := s.db.Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		return "", fmt.Errorf("Unable to snapshot the DB to %v. %v", snapshot, err)
+	}
+	return snapshot, nil
+}
+
+//Restore Replaces the contents of the DB with a snapshot using the SQLite backup API. The pool of the DB has to be limited to
+//one connection, since the snapshot is written through a single connection. Other connections of an in memory DB would keep
+//their own contents, and ones of a file could hold it busy
+func (s *Safety) Restore(snapshot string) error {
+	if maxOpenConns := s.db.DB().Stats().MaxOpenConnections; maxOpenConns != 1 {
+		return fmt.Errorf("Restoring a snapshot needs the pool of the DB limited to one connection. Got a limit of %v", maxOpenConns)
+	}
+	if _, err := os.Stat(snapshot); err != nil {
+		return err
+	}
+
+	source, err := sql.Open("sqlite3", snapshot)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	ctx := context.Background()
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sourceConn.Close()
+	destConn, err := s.db.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(dest interface{}) error {
+		return sourceConn.Raw(func(src interface{}) error {
+			destSQLite, ok := dest.(*sqlite3.SQLiteConn)
+			srcSQLite, srcOK := src.(*sqlite3.SQLiteConn)
+			if !ok || !srcOK {
+				return fmt.Errorf("Snapshots can only be restored into SQLite")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err = backup.Step(-1); err != nil {
+				backup.Close()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+//VerifySchema Fails the migration transaction if rebuilt tables left rows violating foreign keys, then runs the configured verifier
+func (s *Safety) VerifySchema(dba orm.ORM, entities []interface{}) error {
+	db, ok := dba.GetUnderlyingORM().(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("SQLite safety works only with GORM")
+	}
+
+	rows, err := db.Raw("PRAGMA foreign_key_check").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var violations []string
+	for rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var foreignKeyID int
+		if err = rows.Scan(&table, &rowID, &parent, &foreignKeyID); err != nil {
+			return err
+		}
+		violations = append(violations, fmt.Sprintf("%v(rowid %v) references missing row of %v", table, rowID.Int64, parent))
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("Foreign key violations found after migration. %v", strings.Join(violations, "; "))
+	}
+
+	if s.options.Verifier != nil {
+		return s.options.Verifier.VerifySchema(dba, entities)
+	}
+	return nil
+}
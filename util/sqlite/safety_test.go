@@ -0,0 +1,216 @@
+package sqlite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Device struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type Reading struct {
+	ID       int `gorm:"primary_key"`
+	DeviceID int `sql:"type:integer REFERENCES devices(id)"`
+}
+
+type SafetyTestSuite struct {
+	suite.Suite
+	Dir string
+	DB  *gorm.DB
+}
+
+func (s *SafetyTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "goroom_sqlite")
+	if err != nil {
+		panic(err)
+	}
+	s.Dir = dir
+
+	s.DB, err = gorm.Open("sqlite3", filepath.Join(dir, "app.db"))
+	if err != nil {
+		panic(err)
+	}
+	s.DB.DB().SetMaxOpenConns(1)
+	s.DB.CreateTable(Device{}, Reading{})
+	s.DB.Create(&Device{ID: 1, Name: "gateway"})
+	s.DB.Exec("PRAGMA foreign_keys = ON")
+}
+
+func (s *SafetyTestSuite) TearDownTest() {
+	s.DB.Close()
+	os.RemoveAll(s.Dir)
+}
+
+func (s *SafetyTestSuite) getSnapshots() []string {
+	snapshots, _ := filepath.Glob(filepath.Join(s.Dir, "app.db.*"))
+	return snapshots
+}
+
+func (s *SafetyTestSuite) getForeignKeys() (enforced bool) {
+	s.DB.Raw("PRAGMA foreign_keys").Row().Scan(&enforced)
+	return
+}
+
+func (s *SafetyTestSuite) TestCheckIntegrity() {
+	for _, level := range []CheckLevel{NoCheck, QuickCheck, FullCheck} {
+		assert.Nil(s.T(), New(s.DB, Options{Check: level}).CheckIntegrity())
+	}
+
+	assert.Equal(s.T(), "Database failed integrity check. row 2 missing from index; wrong # of entries in index",
+		(&IntegrityError{Problems: []string{"row 2 missing from index", "wrong # of entries in index"}}).Error())
+}
+
+func (s *SafetyTestSuite) TestSnapshotAndRestore() {
+	safety := New(s.DB, Options{})
+
+	snapshot, err := safety.Snapshot("manual")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{snapshot}, s.getSnapshots())
+
+	s.DB.Exec("DELETE FROM devices")
+	s.DB.Exec("DROP TABLE readings")
+
+	assert.Nil(s.T(), safety.Restore(snapshot))
+	var count int
+	s.DB.Model(&Device{}).Count(&count)
+	assert.Equal(s.T(), 1, count, "Rows deleted after the snapshot should be restored")
+	assert.True(s.T(), s.DB.HasTable(Reading{}), "Tables dropped after the snapshot should be restored")
+}
+
+func (s *SafetyTestSuite) TestSnapshotDir() {
+	snapshotDir := filepath.Join(s.Dir, "snapshots")
+	os.Mkdir(snapshotDir, 0755)
+
+	snapshot, err := New(s.DB, Options{SnapshotDir: snapshotDir}).Snapshot("manual")
+
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), snapshotDir, filepath.Dir(snapshot))
+}
+
+func (s *SafetyTestSuite) TestFailedMigrationIsRestored() {
+	var calls []string
+	hooks := New(s.DB, Options{Check: QuickCheck}).Hooks(room.Hooks{
+		BeforeMigration: func(from orm.VersionNumber, to orm.VersionNumber) error {
+			calls = append(calls, "before")
+			return nil
+		},
+		AfterMigration: func(from orm.VersionNumber, to orm.VersionNumber, err error) {
+			calls = append(calls, "after")
+		},
+	})
+
+	assert.Nil(s.T(), hooks.BeforeMigration(1, 2))
+	assert.False(s.T(), s.getForeignKeys(), "Foreign keys should be off while migrating")
+	assert.Len(s.T(), s.getSnapshots(), 1)
+
+	//Migration committed partially before failing
+	s.DB.Exec("UPDATE devices SET name = 'broken'")
+	hooks.AfterMigration(1, 2, &room.PartialMigrationError{Err: fmt.Errorf("Commit failed")})
+
+	var device Device
+	s.DB.First(&device)
+	assert.Equal(s.T(), "gateway", device.Name)
+	assert.True(s.T(), s.getForeignKeys(), "Foreign keys should be back on once done")
+	assert.Empty(s.T(), s.getSnapshots(), "Restored snapshot should be removed")
+	assert.Equal(s.T(), []string{"before", "after"}, calls)
+}
+
+func (s *SafetyTestSuite) TestRolledBackMigrationIsNotRestored() {
+	hooks := New(s.DB, Options{}).Hooks(room.Hooks{})
+
+	assert.Nil(s.T(), hooks.BeforeMigration(1, 2))
+	//Stands in for a change made after the snapshot which a restore would undo
+	s.DB.Exec("UPDATE devices SET name = 'renamed'")
+	hooks.AfterMigration(1, 2, fmt.Errorf("Migration from 1 to 2 failed"))
+
+	var device Device
+	s.DB.First(&device)
+	assert.Equal(s.T(), "renamed", device.Name, "Snapshot should not be restored after a rollback")
+	assert.True(s.T(), s.getForeignKeys())
+	assert.Empty(s.T(), s.getSnapshots())
+}
+
+func (s *SafetyTestSuite) TestRestoreNeedsSingleConnection() {
+	safety := New(s.DB, Options{})
+	snapshot, _ := safety.Snapshot("manual")
+	s.DB.DB().SetMaxOpenConns(2)
+
+	err := safety.Restore(snapshot)
+
+	assert.Equal(s.T(), fmt.Errorf("Restoring a snapshot needs the pool of the DB limited to one connection. Got a limit of 2"), err)
+}
+
+func (s *SafetyTestSuite) TestSuccessfulMigration() {
+	hooks := New(s.DB, Options{}).Hooks(room.Hooks{})
+
+	assert.Nil(s.T(), hooks.BeforeMigration(1, 2))
+	s.DB.Exec("UPDATE devices SET name = 'renamed'")
+	hooks.AfterMigration(1, 2, nil)
+
+	var device Device
+	s.DB.First(&device)
+	assert.Equal(s.T(), "renamed", device.Name)
+	assert.Empty(s.T(), s.getSnapshots())
+
+	hooks = New(s.DB, Options{KeepSnapshots: true}).Hooks(room.Hooks{})
+	assert.Nil(s.T(), hooks.BeforeMigration(2, 3))
+	hooks.AfterMigration(2, 3, nil)
+	assert.Len(s.T(), s.getSnapshots(), 1, "Snapshot should be kept when asked to")
+}
+
+func (s *SafetyTestSuite) TestMigrationAbortedByHook() {
+	expectedError := fmt.Errorf("Not now")
+	hooks := New(s.DB, Options{}).Hooks(room.Hooks{
+		BeforeMigration: func(from orm.VersionNumber, to orm.VersionNumber) error {
+			return expectedError
+		},
+	})
+
+	assert.Equal(s.T(), expectedError, hooks.BeforeMigration(1, 2))
+	assert.Empty(s.T(), s.getSnapshots())
+	assert.True(s.T(), s.getForeignKeys())
+}
+
+func (s *SafetyTestSuite) TestMigrationWithFailingSnapshot() {
+	hooks := New(s.DB, Options{SnapshotDir: filepath.Join(s.Dir, "missing")}).Hooks(room.Hooks{})
+
+	assert.NotNil(s.T(), hooks.BeforeMigration(1, 2))
+	assert.Empty(s.T(), s.getSnapshots())
+	assert.True(s.T(), s.getForeignKeys(), "Foreign keys should be turned back on when the migration is not run")
+}
+
+func (s *SafetyTestSuite) TestSnapshotBeforeCleanUp() {
+	hooks := New(s.DB, Options{}).Hooks(room.Hooks{})
+
+	assert.Nil(s.T(), hooks.BeforeCleanUp())
+	assert.Len(s.T(), s.getSnapshots(), 1)
+}
+
+func (s *SafetyTestSuite) TestVerifySchema() {
+	safety := New(s.DB, Options{})
+	dba := adapter.NewGORM(s.DB)
+	assert.Nil(s.T(), safety.VerifySchema(dba, nil))
+
+	s.DB.Exec("PRAGMA foreign_keys = OFF")
+	s.DB.Create(&Reading{ID: 7, DeviceID: 2})
+
+	assert.Equal(s.T(), fmt.Errorf("Foreign key violations found after migration. readings(rowid 7) references missing row of devices"),
+		safety.VerifySchema(dba, nil))
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(SafetyTestSuite))
+}
@@ -0,0 +1,215 @@
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+)
+
+//SQLiteColumn Column of a SQLite table as reported by PRAGMA table_info
+type SQLiteColumn struct {
+	Name         string
+	Type         string
+	NotNull      bool
+	DefaultValue *string
+	PrimaryKey   bool
+}
+
+func (c SQLiteColumn) String() string {
+	defaultValue := "NULL"
+	if c.DefaultValue != nil {
+		defaultValue = *c.DefaultValue
+	}
+	return fmt.Sprintf("%v %v(notnull=%v, default=%v, pk=%v)", c.Name, c.Type, c.NotNull, defaultValue, c.PrimaryKey)
+}
+
+//SQLiteIndex Explicitly created index of a SQLite table
+type SQLiteIndex struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+func (i SQLiteIndex) String() string {
+	return fmt.Sprintf("%v(unique=%v, columns=%v)", i.Name, i.Unique, strings.Join(i.Columns, ","))
+}
+
+//SQLiteTable Columns and indexes of a SQLite table ordered by name
+type SQLiteTable struct {
+	Columns []SQLiteColumn
+	Indexes []SQLiteIndex
+}
+
+//SQLiteSchema Catalog metadata of a SQLite DB keyed by table name
+type SQLiteSchema map[string]SQLiteTable
+
+//DumpSQLiteSchema Reads the catalog metadata of the given tables through the introspection of the GORM adapter.
+//All tables are read if none are given
+func DumpSQLiteSchema(db *gorm.DB, tableNames ...string) (SQLiteSchema, error) {
+	introspector, ok := adapter.NewGORM(db).(orm.SchemaIntrospector)
+	if !ok {
+		return nil, fmt.Errorf("GORM adapter can not introspect the schema")
+	}
+
+	existingTables, err := introspector.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tableNames) > 0 {
+		requested := make(map[string]bool)
+		for _, tableName := range tableNames {
+			requested[tableName] = true
+		}
+
+		var filtered []string
+		for _, tableName := range existingTables {
+			if requested[tableName] {
+				filtered = append(filtered, tableName)
+			}
+		}
+		existingTables = filtered
+	}
+
+	schema := make(SQLiteSchema)
+	for _, tableName := range existingTables {
+		columns, err := dumpSQLiteColumns(introspector, tableName)
+		if err != nil {
+			return nil, err
+		}
+		indexes, err := dumpSQLiteIndexes(introspector, tableName)
+		if err != nil {
+			return nil, err
+		}
+		schema[tableName] = SQLiteTable{
+			Columns: columns,
+			Indexes: indexes,
+		}
+	}
+
+	return schema, nil
+}
+
+func dumpSQLiteColumns(introspector orm.SchemaIntrospector, tableName string) ([]SQLiteColumn, error) {
+	columnInfos, err := introspector.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]SQLiteColumn, 0, len(columnInfos))
+	for _, column := range columnInfos {
+		columns = append(columns, SQLiteColumn{
+			Name:         column.Name,
+			Type:         column.Type,
+			NotNull:      !column.Nullable,
+			DefaultValue: column.DefaultValue,
+			PrimaryKey:   column.PrimaryKey,
+		})
+	}
+
+	//Columns added by migrations are appended at the end so ordering is not considered
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Name < columns[j].Name
+	})
+
+	return columns, nil
+}
+
+func dumpSQLiteIndexes(introspector orm.SchemaIntrospector, tableName string) ([]SQLiteIndex, error) {
+	definitions, err := introspector.GetIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]SQLiteIndex, 0, len(definitions))
+	for _, definition := range definitions {
+		indexes = append(indexes, SQLiteIndex{
+			Name:    definition.Name,
+			Unique:  definition.Unique,
+			Columns: definition.Columns,
+		})
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].Name < indexes[j].Name
+	})
+
+	return indexes, nil
+}
+
+//Diff Lists the differences of actual schema against the expected one
+func (expected SQLiteSchema) Diff(actual SQLiteSchema) (differences []string) {
+	for tableName, expectedTable := range expected {
+		actualTable, ok := actual[tableName]
+		if !ok {
+			differences = append(differences, fmt.Sprintf("Table %v is missing", tableName))
+			continue
+		}
+
+		differences = append(differences, diffColumns(tableName, expectedTable.Columns, actualTable.Columns)...)
+		differences = append(differences, diffIndexes(tableName, expectedTable.Indexes, actualTable.Indexes)...)
+	}
+
+	for tableName := range actual {
+		if _, ok := expected[tableName]; !ok {
+			differences = append(differences, fmt.Sprintf("Table %v is not expected", tableName))
+		}
+	}
+
+	sort.Strings(differences)
+	return
+}
+
+func diffColumns(tableName string, expectedColumns []SQLiteColumn, actualColumns []SQLiteColumn) (differences []string) {
+	expectedByName := make(map[string]SQLiteColumn)
+	for _, column := range expectedColumns {
+		expectedByName[column.Name] = column
+	}
+
+	for _, column := range actualColumns {
+		expectedColumn, ok := expectedByName[column.Name]
+		if !ok {
+			differences = append(differences, fmt.Sprintf("Table %v has unexpected column %v", tableName, column))
+			continue
+		}
+		if expectedColumn.String() != column.String() {
+			differences = append(differences, fmt.Sprintf("Table %v has column %v instead of %v", tableName, column, expectedColumn))
+		}
+		delete(expectedByName, column.Name)
+	}
+
+	for _, column := range expectedByName {
+		differences = append(differences, fmt.Sprintf("Table %v is missing column %v", tableName, column))
+	}
+
+	return
+}
+
+func diffIndexes(tableName string, expectedIndexes []SQLiteIndex, actualIndexes []SQLiteIndex) (differences []string) {
+	expectedByName := make(map[string]SQLiteIndex)
+	for _, index := range expectedIndexes {
+		expectedByName[index.Name] = index
+	}
+
+	for _, index := range actualIndexes {
+		expectedIndex, ok := expectedByName[index.Name]
+		if !ok {
+			differences = append(differences, fmt.Sprintf("Table %v has unexpected index %v", tableName, index))
+			continue
+		}
+		if expectedIndex.String() != index.String() {
+			differences = append(differences, fmt.Sprintf("Table %v has index %v instead of %v", tableName, index, expectedIndex))
+		}
+		delete(expectedByName, index.Name)
+	}
+
+	for _, index := range expectedByName {
+		differences = append(differences, fmt.Sprintf("Table %v is missing index %v", tableName, index))
+	}
+
+	return
+}
@@ -0,0 +1,83 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adonmo/goroom/orm"
+	"github.com/adonmo/goroom/room"
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+
+	//SQLite dialect used for the scratch database of fresh installs
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+//SQLiteSchemaVerifier Compares the tables of a migrated SQLite DB with a fresh install of the entities in an in-memory SQLite DB.
+//It only works with SQLite as column types and defaults of other dialects would never match the fresh install
+type SQLiteSchemaVerifier struct{}
+
+//singularTableProbe Entity without a table name of its own. Its table name tells whether GORM is set to singular table names
+type singularTableProbe struct{}
+
+//NewSQLiteSchemaVerifier Returns a verifier for Rooms backed by SQLite through GORM. Verification fails for other dialects
+func NewSQLiteSchemaVerifier() orm.SchemaVerifier {
+	return &SQLiteSchemaVerifier{}
+}
+
+//VerifySchema Fails with the differences if the entity tables of db do not match a fresh install
+func (v *SQLiteSchemaVerifier) VerifySchema(dba orm.ORM, entities []interface{}) error {
+	db, ok := dba.GetUnderlyingORM().(*gorm.DB)
+	if !ok {
+		return fmt.Errorf("SQLite schema verifier works only with GORM")
+	}
+	if dialect := db.Dialect().GetName(); dialect != "sqlite3" {
+		return fmt.Errorf("SQLite schema verifier can not verify a DB of dialect %v", dialect)
+	}
+
+	scratchDB, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		return err
+	}
+	defer scratchDB.Close()
+
+	//Every new connection to :memory: is a new database hence the pool is restricted to a single connection
+	scratchDB.DB().SetMaxOpenConns(1)
+	scratchDB.SingularTable(!strings.HasSuffix(db.NewScope(singularTableProbe{}).TableName(), "probes"))
+
+	//Fresh install is created the way Room creates it so that foreign keys are part of it
+	scratchDBA := adapter.NewGORM(scratchDB)
+	ordered, err := room.GetCreationOrder(scratchDBA, entities)
+	if err != nil {
+		return err
+	}
+	for _, entity := range ordered {
+		if err = scratchDBA.CreateTable(entity).Error; err != nil {
+			return fmt.Errorf("Unable to create fresh install of %T. %v", entity, err)
+		}
+	}
+
+	tableNames := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		tableName := db.NewScope(entity).TableName()
+		if scratchName := scratchDB.NewScope(entity).TableName(); scratchName != tableName {
+			return fmt.Errorf("Unable to create fresh install of %T as table %v. It was named %v", entity, tableName, scratchName)
+		}
+		tableNames = append(tableNames, tableName)
+	}
+
+	expected, err := DumpSQLiteSchema(scratchDB, tableNames...)
+	if err != nil {
+		return err
+	}
+	actual, err := DumpSQLiteSchema(db, tableNames...)
+	if err != nil {
+		return err
+	}
+
+	if differences := expected.Diff(actual); len(differences) > 0 {
+		return fmt.Errorf("Migrated schema differs from a fresh install:\n%v", strings.Join(differences, "\n"))
+	}
+
+	return nil
+}
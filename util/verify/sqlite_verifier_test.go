@@ -0,0 +1,93 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adonmo/goroom/util/adapter"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type Account struct {
+	ID      int    `gorm:"primary_key"`
+	Email   string `gorm:"unique_index"`
+	Balance int    `gorm:"not null"`
+}
+
+type Device struct {
+	ID   int `gorm:"primary_key"`
+	Name string
+}
+
+type SchemaVerifierTestSuite struct {
+	suite.Suite
+	DB *gorm.DB
+}
+
+func (s *SchemaVerifierTestSuite) SetupTest() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	s.DB = db
+}
+
+func (s *SchemaVerifierTestSuite) TearDownTest() {
+	s.DB.Close()
+}
+
+func (s *SchemaVerifierTestSuite) TestVerifySchemaOfFreshInstall() {
+	s.DB.CreateTable(Account{})
+	s.DB.Exec("CREATE TABLE unrelated_tables (id integer)")
+
+	err := NewSQLiteSchemaVerifier().VerifySchema(adapter.NewGORM(s.DB), []interface{}{Account{}})
+	assert.Nil(s.T(), err, "Tables other than the entities should not be compared")
+}
+
+func (s *SchemaVerifierTestSuite) TestVerifySchemaWithDifferences() {
+	//Columns added by a migration that forgot the constraint and the index
+	s.DB.Exec("CREATE TABLE accounts (id integer primary key autoincrement, email varchar(255))")
+	s.DB.Exec("ALTER TABLE accounts ADD COLUMN balance integer")
+	s.DB.Exec("CREATE INDEX idx_accounts_balance ON accounts(balance)")
+
+	err := NewSQLiteSchemaVerifier().VerifySchema(adapter.NewGORM(s.DB), []interface{}{Account{}})
+	assert.NotNil(s.T(), err)
+
+	expectedDifferences := []string{
+		"Table accounts has column balance integer(notnull=false, default=NULL, pk=false) instead of balance integer(notnull=true, default=NULL, pk=false)",
+		"Table accounts has unexpected index idx_accounts_balance(unique=false, columns=balance)",
+		"Table accounts is missing index uix_accounts_email(unique=true, columns=email)",
+	}
+	assert.Equal(s.T(), "Migrated schema differs from a fresh install:\n"+strings.Join(expectedDifferences, "\n"), err.Error())
+}
+
+func (s *SchemaVerifierTestSuite) TestVerifySchemaWithMissingTable() {
+	err := NewSQLiteSchemaVerifier().VerifySchema(adapter.NewGORM(s.DB), []interface{}{Account{}})
+	assert.EqualError(s.T(), err, "Migrated schema differs from a fresh install:\nTable accounts is missing")
+}
+
+func (s *SchemaVerifierTestSuite) TestVerifySchemaWithSingularTables() {
+	s.DB.SingularTable(true)
+	dba := adapter.NewGORM(s.DB)
+	dba.CreateTable(Device{})
+
+	err := NewSQLiteSchemaVerifier().VerifySchema(dba, []interface{}{Device{}})
+	assert.Nil(s.T(), err, "Fresh install should follow the table naming of the DB")
+}
+
+func (s *SchemaVerifierTestSuite) TestVerifySchemaOfOtherDialect() {
+	//Dialects without a registered GORM dialect run in compatibility mode named common
+	otherDB, err := gorm.Open("other", s.DB.DB())
+	assert.Nil(s.T(), err)
+
+	err = NewSQLiteSchemaVerifier().VerifySchema(adapter.NewGORM(otherDB), []interface{}{Account{}})
+	assert.Equal(s.T(), fmt.Errorf("SQLite schema verifier can not verify a DB of dialect common"), err)
+}
+
+func TestMain(t *testing.T) {
+	suite.Run(t, new(SchemaVerifierTestSuite))
+}